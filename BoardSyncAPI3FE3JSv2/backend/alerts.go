@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Alerting: new findings alerts, repeated sync failures and auto runs
+// stopping are sent to the tenant's own channels, set in its record
+// (generic webhook, Slack-compatible incoming webhook, SMTP e-mail). The
+// channels in the alerts section of config.yaml only get service-level
+// events such as a rejected config reload. An alert is sent once per quiet
+// period of its kind; the same key within the quiet period is dropped.
+
+// Alert kinds
+const (
+	alertFindings     = "findings"
+	alertSyncFailure  = "sync_failure"
+	alertAutoStopped  = "auto_stopped"
+	alertConfigReload = "config_reload"
+)
+
+// AlertsConfig is the alerts section of config.yaml
+type AlertsConfig struct {
+	Channels         AlertChannels // service-level events only
+	FailureThreshold int           // consecutive failures before a sync_failure alert
+	QuietPeriods     map[string]time.Duration
+}
+
+// AlertChannels is where alerts go. Each tenant has its own in its record.
+type AlertChannels struct {
+	WebhookURL      string      `json:"webhook_url,omitempty"`
+	SlackWebhookURL string      `json:"slack_webhook_url,omitempty"`
+	Email           EmailConfig `json:"email"`
+}
+
+// EmailConfig is where e-mail alerts go; disabled when To is empty
+type EmailConfig struct {
+	SMTPHost string   `json:"smtp_host,omitempty"`
+	SMTPPort int      `json:"smtp_port,omitempty"` // 587 when zero
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+func (c AlertsConfig) validate() error {
+	problems := c.Channels.problems()
+	if c.FailureThreshold < 1 {
+		problems = append(problems, fmt.Sprintf("failure_threshold must be at least 1, got %d", c.FailureThreshold))
+	}
+	for kind, d := range c.QuietPeriods {
+		switch kind {
+		case alertFindings, alertSyncFailure, alertAutoStopped, alertConfigReload:
+		default:
+			problems = append(problems, fmt.Sprintf("quiet_periods.%s: unknown alert kind; use findings, sync_failure, auto_stopped or config_reload", kind))
+		}
+		if d < 0 {
+			problems = append(problems, fmt.Sprintf("quiet_periods.%s cannot be negative, got %s", kind, d))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (c AlertChannels) validate() error {
+	if problems := c.problems(); len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (c AlertChannels) problems() []string {
+	var problems []string
+	for _, u := range []struct{ name, value string }{{"webhook_url", c.WebhookURL}, {"slack_webhook_url", c.SlackWebhookURL}} {
+		if u.value == "" {
+			continue
+		}
+		parsed, err := url.Parse(u.value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s must be an http(s) URL", u.name))
+		}
+	}
+	if len(c.Email.To) > 0 {
+		if c.Email.SMTPHost == "" || c.Email.From == "" {
+			problems = append(problems, "email.smtp_host and email.from are required when email.to is set")
+		}
+		if c.Email.SMTPPort < 0 || c.Email.SMTPPort > 65535 {
+			problems = append(problems, fmt.Sprintf("email.smtp_port must be a port number, got %d", c.Email.SMTPPort))
+		}
+	}
+	return problems
+}
+
+// secrets are the settings masked in logs, /admin/config and the tenant
+// API. Incoming webhook URLs carry their token in the path.
+func (c AlertChannels) secrets() []string {
+	return []string{c.WebhookURL, c.SlackWebhookURL, c.Email.Password}
+}
+
+func (c AlertsConfig) secrets() []string {
+	return c.Channels.secrets()
+}
+
+func (c AlertsConfig) redacted() AlertsConfig {
+	c.Channels = c.Channels.redacted()
+	return c
+}
+
+func (c AlertChannels) redacted() AlertChannels {
+	mask := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return redactedText
+	}
+	c.WebhookURL = mask(c.WebhookURL)
+	c.SlackWebhookURL = mask(c.SlackWebhookURL)
+	c.Email.Password = mask(c.Email.Password)
+	return c
+}
+
+// Alert is one notification. Key identifies it for deduplication, within
+// its tenant and pair.
+type Alert struct {
+	Kind     string    `json:"kind"`
+	Key      string    `json:"key"`
+	Severity string    `json:"severity"` // critical or warning
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Tenant   string    `json:"tenant,omitempty"`
+	Pair     string    `json:"pair,omitempty"`
+	Time     time.Time `json:"time"`
+	Channels []string  `json:"channels"` // where it was delivered
+}
+
+// alertChannel delivers alerts somewhere
+type alertChannel interface {
+	Name() string
+	Send(ctx context.Context, a Alert) error
+}
+
+// alertChannels are the channels configured in c
+func alertChannels(c AlertChannels) []alertChannel {
+	var channels []alertChannel
+	if c.WebhookURL != "" {
+		channels = append(channels, webhookChannel{url: c.WebhookURL})
+	}
+	if c.SlackWebhookURL != "" {
+		channels = append(channels, slackChannel{url: c.SlackWebhookURL})
+	}
+	if len(c.Email.To) > 0 {
+		channels = append(channels, emailChannel{c.Email})
+	}
+	return channels
+}
+
+var alertHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func postJSON(ctx context.Context, target string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := alertHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookChannel posts the alert as JSON
+type webhookChannel struct {
+	url string
+}
+
+func (c webhookChannel) Name() string { return "webhook" }
+
+func (c webhookChannel) Send(ctx context.Context, a Alert) error {
+	return postJSON(ctx, c.url, a)
+}
+
+// slackChannel posts to a Slack (or Mattermost, Rocket.Chat, ...) incoming
+// webhook
+type slackChannel struct {
+	url string
+}
+
+func (c slackChannel) Name() string { return "slack" }
+
+func (c slackChannel) Send(ctx context.Context, a Alert) error {
+	icon := ":warning:"
+	if a.Severity == "critical" {
+		icon = ":rotating_light:"
+	}
+	return postJSON(ctx, c.url, map[string]interface{}{
+		"text": fmt.Sprintf("%s *%s*\n%s\n_%s_", icon, a.Title, a.Message, alertScope(a)),
+	})
+}
+
+// emailChannel sends a plain text mail, with STARTTLS when the server
+// offers it
+type emailChannel struct {
+	EmailConfig
+}
+
+func (c emailChannel) Name() string { return "email" }
+
+func (c emailChannel) Send(ctx context.Context, a Alert) error {
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.SMTPHost)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [%s] %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n\r\n%s\r\n",
+		c.From, strings.Join(c.To, ", "), a.Severity, a.Title, a.Message, alertScope(a))
+
+	// net/smtp has no context; give up waiting when ctx ends
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(c.SMTPHost, strconv.Itoa(c.port())), auth, c.From, c.To, []byte(msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c EmailConfig) port() int {
+	if c.SMTPPort == 0 {
+		return 587
+	}
+	return c.SMTPPort
+}
+
+func alertScope(a Alert) string {
+	if a.Tenant == "" {
+		return "service, " + a.Time.Format(time.RFC3339)
+	}
+	scope := "tenant " + a.Tenant
+	if a.Pair != "" {
+		scope += ", pair " + a.Pair
+	}
+	return scope + ", " + a.Time.Format(time.RFC3339)
+}
+
+// maxRecentAlerts is how many sent alerts /alerts lists
+const maxRecentAlerts = 100
+
+// Notifier deduplicates alerts and hands them to the channels. Each tenant
+// has one; serviceAlerts handles events outside any tenant.
+type Notifier struct {
+	channels func() AlertChannels
+
+	mu       sync.Mutex
+	quietTil map[string]time.Time // scoped key -> end of its quiet period
+	failures map[string]int       // scoped key -> consecutive failures
+	recent   []Alert              // newest last
+	wg       sync.WaitGroup       // deliveries in flight
+}
+
+func NewNotifier(channels func() AlertChannels) *Notifier {
+	return &Notifier{channels: channels, quietTil: make(map[string]time.Time), failures: make(map[string]int)}
+}
+
+var serviceAlerts = NewNotifier(func() AlertChannels { return currentConfig().Alerts.Channels })
+
+// notifierFrom returns the notifier of the tenant in ctx, or serviceAlerts
+// outside a tenant.
+func notifierFrom(ctx context.Context) *Notifier {
+	if t := tenantFrom(ctx); t != nil && t.alerts != nil {
+		return t.alerts
+	}
+	return serviceAlerts
+}
+
+// scopedKey prefixes key with the tenant and pair of ctx
+func scopedKey(ctx context.Context, kind, key string) string {
+	tenant, pair := metricScope(ctx)
+	return strings.Join([]string{tenant, pair, kind, key}, "/")
+}
+
+// Notify sends a unless an alert with the same kind and key was sent within
+// the kind's quiet period. Delivery runs in the background. It returns
+// whether the alert was sent.
+func (n *Notifier) Notify(ctx context.Context, a Alert) bool {
+	cfg := currentConfig().Alerts
+	channels := alertChannels(n.channels())
+	if len(channels) == 0 {
+		return false
+	}
+
+	a.Tenant, a.Pair = metricScope(ctx)
+	a.Time = time.Now().UTC()
+	a.Message = redactPII(redactSecrets(a.Message))
+	key := scopedKey(ctx, a.Kind, a.Key)
+
+	n.mu.Lock()
+	for k, until := range n.quietTil {
+		if a.Time.After(until) {
+			delete(n.quietTil, k)
+		}
+	}
+	if _, quiet := n.quietTil[key]; quiet {
+		n.mu.Unlock()
+		alertsSent.WithLabelValues(a.Tenant, a.Kind, "", "suppressed").Inc()
+		slog.DebugContext(ctx, "Alert suppressed in quiet period", "kind", a.Kind, "key", a.Key)
+		return false
+	}
+	n.quietTil[key] = a.Time.Add(cfg.QuietPeriods[a.Kind])
+	for _, ch := range channels {
+		a.Channels = append(a.Channels, ch.Name())
+	}
+	n.recent = append(n.recent, a)
+	if len(n.recent) > maxRecentAlerts {
+		n.recent = n.recent[len(n.recent)-maxRecentAlerts:]
+	}
+	n.wg.Add(1)
+	n.mu.Unlock()
+
+	slog.InfoContext(ctx, "Alert", "kind", a.Kind, "key", a.Key, "title", a.Title, "channels", strings.Join(a.Channels, ", "))
+	go func() {
+		defer n.wg.Done()
+		n.deliver(context.WithoutCancel(ctx), channels, a)
+	}()
+	return true
+}
+
+func (n *Notifier) deliver(ctx context.Context, channels []alertChannel, a Alert) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	for _, ch := range channels {
+		if err := ch.Send(ctx, a); err != nil {
+			alertsSent.WithLabelValues(a.Tenant, a.Kind, ch.Name(), "failed").Inc()
+			slog.WarnContext(ctx, "Could not send alert", "channel", ch.Name(), "kind", a.Kind, "key", a.Key, "error", err)
+			continue
+		}
+		alertsSent.WithLabelValues(a.Tenant, a.Kind, ch.Name(), "sent").Inc()
+	}
+}
+
+// Failed counts a failure of key; from the failure_threshold-th failure in
+// a row on it alerts, once per quiet period.
+func (n *Notifier) Failed(ctx context.Context, key, what string, err error) {
+	scoped := scopedKey(ctx, alertSyncFailure, key)
+	n.mu.Lock()
+	n.failures[scoped]++
+	count := n.failures[scoped]
+	n.mu.Unlock()
+
+	if count < currentConfig().Alerts.FailureThreshold {
+		return
+	}
+	n.Notify(ctx, Alert{
+		Kind:     alertSyncFailure,
+		Key:      key,
+		Severity: "warning",
+		Title:    what + " keeps failing",
+		Message:  fmt.Sprintf("%s failed %d times in a row. Last error: %v", what, count, err),
+	})
+}
+
+// Succeeded resets the failure count of key. The next failure streak may
+// alert again straight away.
+func (n *Notifier) Succeeded(ctx context.Context, key string) {
+	scoped := scopedKey(ctx, alertSyncFailure, key)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.failures[scoped]; ok {
+		delete(n.failures, scoped)
+		delete(n.quietTil, scoped)
+	}
+}
+
+// Recent returns the alerts sent, newest first.
+func (n *Notifier) Recent() []Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	list := make([]Alert, 0, len(n.recent))
+	for i := len(n.recent) - 1; i >= 0; i-- {
+		list = append(list, n.recent[i])
+	}
+	return list
+}
+
+// Wait blocks until deliveries in flight are done
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// notifyFindingsAlerts alerts on tickets in Findings that are still active
+// in YouTrack. Each ticket alerts once per findings quiet period.
+func notifyFindingsAlerts(ctx context.Context, findings []FindingsAlert) {
+	n := notifierFrom(ctx)
+	for _, f := range findings {
+		n.Notify(ctx, Alert{
+			Kind:     alertFindings,
+			Key:      f.AsanaTask.GID,
+			Severity: "critical",
+			Title:    fmt.Sprintf("%s is in Findings but still active in YouTrack", f.YouTrackIssue.ID),
+			Message:  f.AlertMessage,
+		})
+	}
+}
+
+// notifyAutoStopped alerts that an auto run of the pair in ctx stopped
+func notifyAutoStopped(ctx context.Context, name, reason string) {
+	notifierFrom(ctx).Notify(ctx, Alert{
+		Kind:     alertAutoStopped,
+		Key:      name,
+		Severity: "warning",
+		Title:    name + " stopped",
+		Message:  fmt.Sprintf("%s stopped: %s. Tickets are no longer updated automatically until it is started again.", name, reason),
+	})
+}
+
+// notifyConfigReloadFailed alerts the service-level channels that a reload
+// was rejected
+func notifyConfigReloadFailed(r ConfigReload) {
+	serviceAlerts.Notify(context.Background(), Alert{
+		Kind:     alertConfigReload,
+		Key:      "rejected",
+		Severity: "warning",
+		Title:    "Config reload rejected",
+		Message:  fmt.Sprintf("Reload triggered by %s was rejected; the running config is unchanged. Problems: %s", r.Trigger, strings.ReplaceAll(r.Error, "\n", "; ")),
+	})
+}
+
+// Alerts handler: GET /alerts lists recently sent alerts of the tenant;
+// POST /alerts sends a test alert to every channel of the tenant.
+func (s *Server) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	t := tenantFrom(r.Context())
+	cfg := currentConfig().Alerts
+	tenantChannels := t.Record().Alerts
+	channels := alertChannelNames(tenantChannels)
+
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"channels":          channels,
+			"failure_threshold": cfg.FailureThreshold,
+			"quiet_periods":     quietPeriodStrings(cfg.QuietPeriods),
+			"alerts":            t.alerts.Recent(),
+		})
+
+	case "POST":
+		if len(channels) == 0 {
+			writeJSONError(w, http.StatusConflict, "no_channels",
+				fmt.Sprintf("Tenant %q has no alert channels. Set alerts.webhook_url, alerts.slack_webhook_url or alerts.email with PUT /admin/tenants/%s.", t.ID(), t.ID()))
+			return
+		}
+		a := Alert{
+			Kind:     "test",
+			Key:      newRequestID(),
+			Severity: "warning",
+			Title:    "Test alert",
+			Message:  fmt.Sprintf("Test alert sent by %s.", actorName(r.Context())),
+			Time:     time.Now().UTC(),
+		}
+		a.Tenant, a.Pair = metricScope(r.Context())
+		a.Channels = channels
+
+		results := map[string]string{}
+		for _, ch := range alertChannels(tenantChannels) {
+			if err := ch.Send(r.Context(), a); err != nil {
+				results[ch.Name()] = redactSecrets(err.Error())
+			} else {
+				results[ch.Name()] = "sent"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "success",
+			"channels": results,
+		})
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use GET or POST.")
+	}
+}
+
+func alertChannelNames(c AlertChannels) []string {
+	names := []string{}
+	for _, ch := range alertChannels(c) {
+		names = append(names, ch.Name())
+	}
+	return names
+}
+
+func quietPeriodStrings(periods map[string]time.Duration) map[string]string {
+	out := make(map[string]string, len(periods))
+	for kind, d := range periods {
+		out[kind] = d.String()
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// alertSink is a webhook channel that keeps what it receives
+type alertSink struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func newAlertSink(t *testing.T) (*alertSink, string) {
+	sink := &alertSink{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sink.mu.Lock()
+		sink.alerts = append(sink.alerts, a)
+		sink.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return sink, srv.URL
+}
+
+func (s *alertSink) received() []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Alert(nil), s.alerts...)
+}
+
+// alertTestTenant starts a tenant whose alerts go to a sink, with the
+// service-level channel pointing at another sink.
+func alertTestTenant(t *testing.T) (ctx context.Context, tenant, service *alertSink) {
+	t.Helper()
+	cfg := useTestConfig(t)
+	service, serviceURL := newAlertSink(t)
+	cfg.Alerts.Channels = AlertChannels{WebhookURL: serviceURL}
+	setConfig(cfg)
+
+	tenant, tenantURL := newAlertSink(t)
+	tn, err := newTenant(TenantRecord{ID: "acme", DataDir: t.TempDir(), Alerts: AlertChannels{WebhookURL: tenantURL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tn.Stop)
+	return withTenant(context.Background(), tn), tenant, service
+}
+
+func TestTenantAlertsGoToTenantChannels(t *testing.T) {
+	ctx, tenant, service := alertTestTenant(t)
+
+	notifyAutoStopped(ctx, "Auto-sync", "stopped by alice")
+	notifierFrom(ctx).Wait()
+
+	got := tenant.received()
+	if len(got) != 1 || got[0].Kind != alertAutoStopped || got[0].Tenant != "acme" {
+		t.Fatalf("tenant channel got %+v, want one auto_stopped alert of acme", got)
+	}
+	if n := len(service.received()); n != 0 {
+		t.Fatalf("service channel got %d tenant alerts, want none", n)
+	}
+	if recent := notifierFrom(ctx).Recent(); len(recent) != 1 {
+		t.Fatalf("Recent() = %d alerts, want 1", len(recent))
+	}
+}
+
+func TestServiceAlertsGoToConfigChannels(t *testing.T) {
+	_, tenant, service := alertTestTenant(t)
+	previous := serviceAlerts
+	serviceAlerts = NewNotifier(previous.channels)
+	t.Cleanup(func() { serviceAlerts = previous })
+
+	notifyConfigReloadFailed(ConfigReload{Trigger: "sighup", Error: "retry.max_attempts: must be at least 1"})
+	serviceAlerts.Wait()
+
+	got := service.received()
+	if len(got) != 1 || got[0].Kind != alertConfigReload || got[0].Tenant != "" {
+		t.Fatalf("service channel got %+v, want one config_reload alert", got)
+	}
+	if n := len(tenant.received()); n != 0 {
+		t.Fatalf("tenant channel got %d service alerts, want none", n)
+	}
+}
+
+func TestNotifierQuietPeriodUnderConcurrency(t *testing.T) {
+	ctx, tenant, _ := alertTestTenant(t)
+	n := notifierFrom(ctx)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sent := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if n.Notify(ctx, Alert{Kind: alertFindings, Key: "task-1", Severity: "critical", Title: "t"}) {
+				mu.Lock()
+				sent++
+				mu.Unlock()
+			}
+			n.Recent()
+		}()
+	}
+	wg.Wait()
+	n.Wait()
+
+	if sent != 1 || len(tenant.received()) != 1 {
+		t.Fatalf("sent %d, delivered %d; want one alert per quiet period", sent, len(tenant.received()))
+	}
+}
+
+func TestNotifierFailedAlertsAtThreshold(t *testing.T) {
+	ctx, tenant, _ := alertTestTenant(t)
+	n := notifierFrom(ctx)
+	threshold := currentConfig().Alerts.FailureThreshold
+
+	for i := 1; i < threshold; i++ {
+		n.Failed(ctx, "sync/1", "Syncing 1", errors.New("boom"))
+	}
+	n.Wait()
+	if got := len(tenant.received()); got != 0 {
+		t.Fatalf("alerted after %d failures, threshold is %d", threshold-1, threshold)
+	}
+
+	n.Failed(ctx, "sync/1", "Syncing 1", errors.New("boom"))
+	n.Wait()
+	if got := tenant.received(); len(got) != 1 || got[0].Kind != alertSyncFailure {
+		t.Fatalf("got %+v, want one sync_failure alert", got)
+	}
+
+	// A success ends the streak and its quiet period
+	n.Succeeded(ctx, "sync/1")
+	for i := 0; i < threshold; i++ {
+		n.Failed(ctx, "sync/1", "Syncing 1", errors.New("boom"))
+	}
+	n.Wait()
+	if got := len(tenant.received()); got != 2 {
+		t.Fatalf("delivered %d alerts, want a second one for the new streak", got)
+	}
+}
+
+func TestTenantRequestKeepsMaskedAlertSecrets(t *testing.T) {
+	rec := TenantRecord{Alerts: AlertChannels{WebhookURL: "https://hooks.example/secret", Email: EmailConfig{Password: "pw"}}}
+	masked := rec.View().Alerts
+	masked.SlackWebhookURL = "https://slack.example/new"
+
+	tenantRequest{Alerts: &masked}.apply(&rec)
+
+	want := AlertChannels{WebhookURL: "https://hooks.example/secret", SlackWebhookURL: "https://slack.example/new", Email: EmailConfig{Password: "pw"}}
+	if rec.Alerts.WebhookURL != want.WebhookURL || rec.Alerts.SlackWebhookURL != want.SlackWebhookURL || rec.Alerts.Email.Password != want.Email.Password {
+		t.Fatalf("alerts = %+v, want %+v", rec.Alerts, want)
+	}
+}
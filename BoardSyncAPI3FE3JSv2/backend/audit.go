@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audit actions
+const (
+	auditCreate   = "create"
+	auditSync     = "sync"
+	auditDelete   = "delete"
+	auditIgnore   = "ignore"
+	auditUnignore = "unignore"
+	auditRestore  = "restore"
+
+	auditRotateSecret = "rotate_secret"
+	auditProvision    = "provision"
+)
+
+// AuditEntry records one mutation of a tracker or of the ignore list
+type AuditEntry struct {
+	ID         string                 `json:"id"`
+	Time       time.Time              `json:"time"`
+	Actor      string                 `json:"actor"`
+	Trigger    string                 `json:"trigger"` // manual, auto-sync, auto-create
+	Endpoint   string                 `json:"endpoint,omitempty"`
+	JobID      string                 `json:"job_id,omitempty"`
+	Pair       string                 `json:"pair,omitempty"`
+	Action     string                 `json:"action"`
+	AsanaID    string                 `json:"asana_id,omitempty"`
+	YouTrackID string                 `json:"youtrack_id,omitempty"`
+	Before     map[string]interface{} `json:"before,omitempty"`
+	After      map[string]interface{} `json:"after,omitempty"`
+	Outcome    string                 `json:"outcome"` // success, failed, partial
+	Error      string                 `json:"error,omitempty"`
+}
+
+// AuditContext carries who and what caused the work on a context, so the
+// code that calls the trackers can record it without extra parameters.
+type AuditContext struct {
+	Actor    string
+	Trigger  string
+	Endpoint string
+	JobID    string
+}
+
+type auditContextKey struct{}
+
+func withAuditContext(ctx context.Context, ac AuditContext) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, ac)
+}
+
+func auditContextFrom(ctx context.Context) AuditContext {
+	ac, _ := ctx.Value(auditContextKey{}).(AuditContext)
+	if ac.Actor == "" {
+		ac.Actor = "system"
+	}
+	if ac.Trigger == "" {
+		ac.Trigger = triggerManual
+	}
+	return ac
+}
+
+// AuditLog is an append-only JSON Lines file. Entries are never rewritten;
+// queries scan the file newest first.
+type AuditLog struct {
+	file string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func NewAuditLog(file string) (*AuditLog, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: file, f: f}, nil
+}
+
+// recordAudit fills in the actor and trigger from ctx and appends the entry
+// to the audit log of the tenant in ctx.
+func recordAudit(ctx context.Context, entry AuditEntry) {
+	t := tenantFrom(ctx)
+	if t == nil {
+		return
+	}
+
+	ac := auditContextFrom(ctx)
+	entry.ID = newJobID()
+	entry.Time = time.Now().UTC()
+	entry.Actor = ac.Actor
+	entry.Trigger = ac.Trigger
+	entry.Endpoint = ac.Endpoint
+	entry.JobID = ac.JobID
+	if e, ok := ctx.Value(pairContextKey{}).(*SyncEngine); ok {
+		entry.Pair = e.pair.Name
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = "success"
+	}
+
+	if err := t.audit.Append(entry); err != nil {
+		slog.ErrorContext(ctx, "Could not write audit entry", "action", entry.Action, "asana_id", entry.AsanaID, "youtrack_id", entry.YouTrackID, "error", err)
+	}
+}
+
+// auditOutcome returns the outcome and error text for err
+func auditOutcome(err error) (string, string) {
+	if err != nil {
+		return "failed", err.Error()
+	}
+	return "success", ""
+}
+
+func (l *AuditLog) Append(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.f.Write(line); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// AuditFilter selects entries; empty fields match everything
+type AuditFilter struct {
+	Actor    string
+	Trigger  string
+	Action   string
+	Outcome  string
+	Endpoint string
+	TicketID string // matches either side
+	JobID    string
+	Pair     string
+	Since    time.Time
+	Until    time.Time
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	switch {
+	case f.Actor != "" && !strings.EqualFold(f.Actor, e.Actor):
+		return false
+	case f.Trigger != "" && f.Trigger != e.Trigger:
+		return false
+	case f.Action != "" && f.Action != e.Action:
+		return false
+	case f.Outcome != "" && f.Outcome != e.Outcome:
+		return false
+	case f.Endpoint != "" && f.Endpoint != e.Endpoint:
+		return false
+	case f.TicketID != "" && f.TicketID != e.AsanaID && f.TicketID != e.YouTrackID:
+		return false
+	case f.JobID != "" && f.JobID != e.JobID:
+		return false
+	case f.Pair != "" && f.Pair != e.Pair:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// AuditPage is one page of a query, newest entry first
+type AuditPage struct {
+	Entries []AuditEntry
+	More    bool // further matches follow the page
+	Total   int  // all matches; -1 unless the query counted them
+}
+
+// Query returns matching entries newest first, skipping offset and
+// returning at most limit (all when limit <= 0).
+//
+// The file is read backwards from the end and the scan stops once the page
+// is full, so recent pages stay cheap however long the log grows. Counting
+// every match means reading the whole file, so it only happens when
+// countTotal is set. Appends only ever add whole lines, so the scan starts
+// at the size the file had when the query started and holds no lock while
+// it reads.
+func (l *AuditLog) Query(filter AuditFilter, offset, limit int, countTotal bool) (AuditPage, error) {
+	l.mu.Lock()
+	info, err := l.f.Stat()
+	l.mu.Unlock()
+	if err != nil {
+		return AuditPage{}, err
+	}
+
+	f, err := os.Open(l.file)
+	if err != nil {
+		return AuditPage{}, err
+	}
+	defer f.Close()
+
+	page := AuditPage{Entries: []AuditEntry{}, Total: -1}
+	matched := 0
+	err = scanLinesBackward(f, info.Size(), func(line []byte) bool {
+		var entry AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil || !filter.matches(entry) {
+			return true
+		}
+		matched++
+		switch {
+		case matched <= offset:
+		case limit <= 0 || len(page.Entries) < limit:
+			page.Entries = append(page.Entries, entry)
+		default:
+			page.More = true
+			return countTotal
+		}
+		return true
+	})
+	if err != nil {
+		return AuditPage{}, err
+	}
+	if countTotal {
+		page.Total = matched
+	}
+	return page, nil
+}
+
+const (
+	auditReadChunk   = 64 * 1024
+	maxAuditLineSize = 4 * 1024 * 1024
+)
+
+// scanLinesBackward calls fn with each non-empty line of the first size
+// bytes of f, last line first, until fn returns false.
+func scanLinesBackward(f io.ReaderAt, size int64, fn func(line []byte) bool) error {
+	var carry []byte // start of the line that straddles the previous chunk
+	for pos := size; pos > 0; {
+		n := int64(auditReadChunk)
+		if n > pos {
+			n = pos
+		}
+		pos -= n
+
+		buf := make([]byte, int(n)+len(carry))
+		if _, err := f.ReadAt(buf[:n], pos); err != nil {
+			return err
+		}
+		copy(buf[n:], carry)
+
+		end := len(buf)
+		for {
+			i := bytes.LastIndexByte(buf[:end], '\n')
+			if i < 0 {
+				break
+			}
+			if line := buf[i+1 : end]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+			end = i
+		}
+		carry = buf[:end]
+		if len(carry) > maxAuditLineSize {
+			return bufio.ErrTooLong
+		}
+	}
+	if len(carry) > 0 {
+		fn(carry)
+	}
+	return nil
+}
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// Audit log handler: GET /audit with filters, pagination and export
+func (s *Server) auditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := AuditFilter{
+		Actor:    q.Get("actor"),
+		Trigger:  q.Get("trigger"),
+		Action:   q.Get("action"),
+		Outcome:  q.Get("outcome"),
+		Endpoint: q.Get("endpoint"),
+		TicketID: q.Get("ticket_id"),
+		JobID:    q.Get("job_id"),
+		Pair:     q.Get("pair"),
+	}
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := q.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("%s must be an RFC3339 time, got %q", name, value))
+				return
+			}
+			*target = t
+		}
+	}
+
+	format := q.Get("format")
+	if format == "jsonl" || format == "csv" {
+		page, err := tenantFrom(r.Context()).audit.Query(filter, 0, 0, false)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read audit log: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeAuditExport(w, format, page.Entries)
+		return
+	}
+
+	limit := defaultAuditPageSize
+	if value := q.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+	offset := 0
+	if value := q.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "offset must be a non-negative integer")
+			return
+		}
+		offset = n
+	}
+
+	// Counting every match reads the whole log, so it is opt-in
+	countTotal := q.Get("total") == "true"
+
+	page, err := tenantFrom(r.Context()).audit.Query(filter, offset, limit, countTotal)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read audit log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"entries": page.Entries,
+		"count":   len(page.Entries),
+		"limit":   limit,
+		"offset":  offset,
+	}
+	if countTotal {
+		response["total"] = page.Total
+	}
+	if page.More {
+		response["next_offset"] = offset + len(page.Entries)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+var auditCSVHeader = []string{
+	"id", "time", "actor", "trigger", "endpoint", "job_id", "pair", "action",
+	"asana_id", "youtrack_id", "outcome", "error", "before", "after",
+}
+
+func writeAuditExport(w http.ResponseWriter, format string, entries []AuditEntry) {
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, entry := range entries {
+			enc.Encode(entry)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write(auditCSVHeader)
+	for _, e := range entries {
+		cw.Write([]string{
+			e.ID, e.Time.Format(time.RFC3339), e.Actor, e.Trigger, e.Endpoint, e.JobID, e.Pair, e.Action,
+			e.AsanaID, e.YouTrackID, e.Outcome, e.Error, auditJSON(e.Before), auditJSON(e.After),
+		})
+	}
+	cw.Flush()
+}
+
+func auditJSON(values map[string]interface{}) string {
+	if len(values) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAuditLogQueryWhileAppending(t *testing.T) {
+	log, err := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { log.Close() })
+
+	for i := 0; i < 10; i++ {
+		if err := log.Append(AuditEntry{Action: auditCreate}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if err := log.Append(AuditEntry{Action: auditDelete}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		page, err := log.Query(AuditFilter{}, 0, 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The scan starts at a line boundary and always includes the oldest entries
+		entries := page.Entries
+		if page.Total < 10 || len(entries) != page.Total || entries[len(entries)-1].Action != auditCreate {
+			t.Fatalf("query returned %d of %d entries, oldest %q", len(entries), page.Total, entries[len(entries)-1].Action)
+		}
+	}
+	wg.Wait()
+
+	if page, _ := log.Query(AuditFilter{}, 0, 0, true); page.Total != 210 {
+		t.Fatalf("total = %d after appends, want 210", page.Total)
+	}
+}
+
+func TestAuditLogQueryPages(t *testing.T) {
+	log, err := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { log.Close() })
+
+	// Enough entries to span several read chunks; every third is a delete
+	for i := 0; i < 3000; i++ {
+		action := auditCreate
+		if i%3 == 0 {
+			action = auditDelete
+		}
+		if err := log.Append(AuditEntry{Action: action, AsanaID: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		filter        AuditFilter
+		offset, limit int
+		first, last   string // asana ids of the first and last entry returned
+		count         int
+		more          bool
+	}{
+		{"newest page", AuditFilter{}, 0, 50, "2999", "2950", 50, true},
+		{"deep page", AuditFilter{}, 2900, 50, "99", "50", 50, true},
+		{"last page", AuditFilter{}, 2980, 50, "19", "0", 20, false},
+		{"past the end", AuditFilter{}, 3000, 50, "", "", 0, false},
+		{"filtered", AuditFilter{Action: auditDelete}, 10, 5, "2967", "2955", 5, true},
+	}
+	for _, tt := range tests {
+		page, err := log.Query(tt.filter, tt.offset, tt.limit, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := page.Entries
+		if len(got) != tt.count || page.More != tt.more || page.Total != -1 {
+			t.Errorf("%s: %d entries, more %v, total %d; want %d, more %v and no total", tt.name, len(got), page.More, page.Total, tt.count, tt.more)
+			continue
+		}
+		if len(got) > 0 && (got[0].AsanaID != tt.first || got[len(got)-1].AsanaID != tt.last) {
+			t.Errorf("%s: entries %s..%s, want %s..%s", tt.name, got[0].AsanaID, got[len(got)-1].AsanaID, tt.first, tt.last)
+		}
+	}
+
+	if page, _ := log.Query(AuditFilter{Action: auditDelete}, 0, 5, true); page.Total != 1000 || !page.More {
+		t.Errorf("counted query: total %d, more %v; want 1000 and more", page.Total, page.More)
+	}
+}
+
+// countingReaderAt counts the bytes read through it
+type countingReaderAt struct {
+	r    io.ReaderAt
+	read int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.read += int64(n)
+	return n, err
+}
+
+func TestScanLinesBackwardStopsEarly(t *testing.T) {
+	var data bytes.Buffer
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&data, "line %d\n", i)
+	}
+	data.WriteString("torn")
+	size := int64(data.Len())
+
+	r := &countingReaderAt{r: bytes.NewReader(data.Bytes())}
+	var lines []string
+	err := scanLinesBackward(r, size, func(line []byte) bool {
+		lines = append(lines, string(line))
+		return len(lines) < 3
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(lines, ",") != "torn,line 99999,line 99998" {
+		t.Errorf("lines = %q", lines)
+	}
+	if r.read > auditReadChunk {
+		t.Errorf("read %d of %d bytes for the last three lines", r.read, size)
+	}
+
+	// A full scan sees every line once, including those split across chunks
+	var count int
+	first := ""
+	scanLinesBackward(bytes.NewReader(data.Bytes()), size, func(line []byte) bool {
+		count++
+		first = string(line)
+		return true
+	})
+	if count != 100001 || first != "line 0" {
+		t.Errorf("full scan saw %d lines ending with %q", count, first)
+	}
+}
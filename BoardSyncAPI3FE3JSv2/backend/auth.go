@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKey is a named credential for the HTTP API. Only a SHA-256 hash of the
+// key is stored; the plaintext is returned once, when the key is created.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"hash,omitempty"`
+	Role      Role       `json:"role"`
+	Tenant    string     `json:"tenant,omitempty"` // empty for service-level keys
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// bootstrapKeyID identifies the key configured through SYNC_SERVICE_API_KEY
+const bootstrapKeyID = "bootstrap"
+
+// KeyStore holds API keys, persisted to a JSON file. The bootstrap key from
+// the environment always authenticates as an admin and cannot be revoked.
+type KeyStore struct {
+	file         string
+	bootstrapKey string
+
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+// NewKeyStore loads the keys in file. A missing file holds no keys; an
+// unreadable one is an error rather than a store that silently forgets them.
+func NewKeyStore(file, bootstrapKey string) (*KeyStore, error) {
+	ks := &KeyStore{
+		file:         file,
+		bootstrapKey: bootstrapKey,
+		keys:         make(map[string]*APIKey),
+	}
+	if err := ks.load(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Configured reports whether any key can authenticate at all.
+func (ks *KeyStore) Configured() bool {
+	if ks.bootstrapKey != "" {
+		return true
+	}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, key := range ks.keys {
+		if key.RevokedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate returns the active key matching the given plaintext.
+func (ks *KeyStore) Authenticate(plaintext string) (*APIKey, bool) {
+	if plaintext == "" {
+		return nil, false
+	}
+
+	if ks.bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(plaintext), []byte(ks.bootstrapKey)) == 1 {
+		return &APIKey{ID: bootstrapKeyID, Name: "SYNC_SERVICE_API_KEY", Role: roleAdmin}, true
+	}
+
+	hash := hashAPIKey(plaintext)
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, key := range ks.keys {
+		if key.RevokedAt == nil && subtle.ConstantTimeCompare([]byte(hash), []byte(key.Hash)) == 1 {
+			found := *key
+			return &found, true
+		}
+	}
+	return nil, false
+}
+
+// Create stores a new key and returns it with its plaintext value. A key
+// with a tenant can only reach that tenant.
+func (ks *KeyStore) Create(name string, role Role, tenant string) (APIKey, string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return APIKey{}, "", err
+	}
+	plaintext := "bsk_" + hex.EncodeToString(secret)
+
+	key := &APIKey{
+		ID:        newJobID(),
+		Name:      name,
+		Prefix:    plaintext[:10],
+		Hash:      hashAPIKey(plaintext),
+		Role:      role,
+		Tenant:    tenant,
+		CreatedAt: time.Now(),
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[key.ID] = key
+	if err := ks.saveLocked(); err != nil {
+		delete(ks.keys, key.ID)
+		return APIKey{}, "", err
+	}
+	return redactKey(*key), plaintext, nil
+}
+
+// Revoke disables a key. Revoked keys stay listed for reference.
+func (ks *KeyStore) Revoke(id string) (APIKey, error) {
+	if id == bootstrapKeyID {
+		return APIKey{}, fmt.Errorf("the bootstrap key is configured via SYNC_SERVICE_API_KEY and cannot be revoked")
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, ok := ks.keys[id]
+	if !ok {
+		return APIKey{}, fmt.Errorf("api key %s not found", id)
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := ks.saveLocked(); err != nil {
+			return APIKey{}, err
+		}
+	}
+	return redactKey(*key), nil
+}
+
+// Get returns a stored key without its hash.
+func (ks *KeyStore) Get(id string) (APIKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.keys[id]
+	if !ok {
+		return APIKey{}, false
+	}
+	return redactKey(*key), true
+}
+
+// RevokeTenant revokes every active key bound to tenant and returns how many
+// it revoked.
+func (ks *KeyStore) RevokeTenant(tenant string) (int, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := time.Now()
+	revoked := 0
+	for _, key := range ks.keys {
+		if key.Tenant == tenant && key.RevokedAt == nil {
+			key.RevokedAt = &now
+			revoked++
+		}
+	}
+	if revoked == 0 {
+		return 0, nil
+	}
+	return revoked, ks.saveLocked()
+}
+
+// List returns all stored keys without their hashes, oldest first.
+func (ks *KeyStore) List() []APIKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, redactKey(*key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+func (ks *KeyStore) load() error {
+	data, err := os.ReadFile(ks.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read API key store: %w", err)
+	}
+
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("API key store %s is corrupt: %w", ks.file, err)
+	}
+	for i := range keys {
+		key := keys[i]
+		if key.ID == "" || key.Hash == "" || !key.Role.Valid() {
+			return fmt.Errorf("API key store %s: key %d (%q) needs an id, a hash and a valid role", ks.file, i+1, key.Name)
+		}
+		ks.keys[key.ID] = &key
+	}
+	return nil
+}
+
+func (ks *KeyStore) saveLocked() error {
+	keys := make([]*APIKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ks.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.file)
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func redactKey(key APIKey) APIKey {
+	key.Hash = ""
+	return key
+}
+
+// Request authentication
+
+type principalKey struct{}
+
+// Principal is the authenticated caller of a request, from an API key or
+// an OIDC bearer token
+type Principal struct {
+	KeyID      string     `json:"key_id"`
+	Name       string     `json:"name"`
+	Role       Role       `json:"role"`
+	Tenant     string     `json:"tenant,omitempty"` // empty for service-level callers
+	AuthMethod string     `json:"auth_method"`
+	Subject    string     `json:"subject,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+const (
+	authMethodAPIKey = "api_key"
+	authMethodOIDC   = "oidc"
+)
+
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func principalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// actorName names the caller for log lines; "anonymous" if unauthenticated.
+func actorName(ctx context.Context) string {
+	if p := principalFrom(ctx); p != nil {
+		return p.Name
+	}
+	return "anonymous"
+}
+
+// apiKeyFromRequest reads the key from X-API-Key or "Authorization: ApiKey <key>".
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, value, ok := strings.Cut(auth, " ")
+		if ok && strings.EqualFold(scheme, "ApiKey") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// authenticate resolves the caller from an OIDC bearer token or an API key,
+// writing a 401 and returning nil on failure.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) *Principal {
+	if token := bearerToken(r); token != "" && s.oidc != nil {
+		principal, err := s.oidc.Verify(r.Context(), token)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Rejected bearer token", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Invalid bearer token: "+err.Error())
+			return nil
+		}
+		return principal
+	}
+
+	key, ok := s.keys.Authenticate(apiKeyFromRequest(r))
+	if !ok {
+		message := "Missing credentials. Send an API key in X-API-Key or an OIDC token as Authorization: Bearer."
+		if apiKeyFromRequest(r) != "" {
+			message = "Invalid or revoked API key."
+		}
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized", message)
+		return nil
+	}
+	return &Principal{KeyID: key.ID, Name: key.Name, Role: key.Role, Tenant: key.Tenant, AuthMethod: authMethodAPIKey}
+}
+
+// writeJSONError writes the error body shared by all auth failures
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   code,
+		"message": message,
+		"status":  status,
+	})
+}
+
+// API key administration handler. Admins bound to a tenant only see and
+// manage that tenant's keys; service-level admins see all and may filter
+// with ?tenant=.
+func (s *Server) apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/api-keys"), "/")
+
+	callerTenant := ""
+	if p := principalFrom(r.Context()); p != nil {
+		callerTenant = p.Tenant
+	}
+
+	switch {
+	case r.Method == "GET" && keyID == "":
+		filter := r.URL.Query().Get("tenant")
+		if callerTenant != "" {
+			if filter != "" && filter != callerTenant {
+				writeJSONError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("This key is bound to tenant %q.", callerTenant))
+				return
+			}
+			filter = callerTenant
+		}
+
+		keys := []APIKey{}
+		for _, key := range s.keys.List() {
+			if filter == "" || key.Tenant == filter {
+				keys = append(keys, key)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"keys":   keys,
+			"count":  len(keys),
+		})
+
+	case r.Method == "POST" && keyID == "":
+		var req struct {
+			Name   string `json:"name"`
+			Role   Role   `json:"role"`
+			Tenant string `json:"tenant"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", `Body must be an object like {"name":"dashboard","role":"viewer","tenant":"acme"}`)
+			return
+		}
+		if req.Role == "" {
+			req.Role = roleViewer
+		}
+		if !req.Role.Valid() {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Unknown role %q. Valid roles: %s", req.Role, strings.Join(roleNames(), ", ")))
+			return
+		}
+
+		// Tenant-bound admins can only mint keys for their own tenant;
+		// service-level keys need a service-level caller.
+		req.Tenant = strings.TrimSpace(req.Tenant)
+		if callerTenant != "" {
+			if req.Tenant != "" && req.Tenant != callerTenant {
+				writeJSONError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("This key is bound to tenant %q.", callerTenant))
+				return
+			}
+			req.Tenant = callerTenant
+		}
+		if req.Tenant != "" {
+			if _, ok := s.tenants.Get(req.Tenant); !ok {
+				writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Unknown tenant %q.", req.Tenant))
+				return
+			}
+		}
+
+		key, plaintext, err := s.keys.Create(strings.TrimSpace(req.Name), req.Role, req.Tenant)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Failed to create key: %v", err))
+			return
+		}
+
+		slog.InfoContext(r.Context(), "API key created", "key_id", key.ID, "name", key.Name, "role", key.Role, "key_tenant", key.Tenant, "actor", actorName(r.Context()))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "created",
+			"key":     key,
+			"api_key": plaintext,
+			"note":    "Store this key now; it cannot be retrieved again.",
+		})
+
+	case r.Method == "DELETE" && keyID != "":
+		// Keys of other tenants look like they do not exist
+		if callerTenant != "" {
+			if key, ok := s.keys.Get(keyID); !ok || key.Tenant != callerTenant {
+				writeJSONError(w, http.StatusNotFound, "invalid_request", fmt.Sprintf("api key %s not found", keyID))
+				return
+			}
+		}
+
+		key, err := s.keys.Revoke(keyID)
+		if err != nil {
+			status := http.StatusNotFound
+			if keyID == bootstrapKeyID {
+				status = http.StatusBadRequest
+			}
+			writeJSONError(w, status, "invalid_request", err.Error())
+			return
+		}
+
+		slog.InfoContext(r.Context(), "API key revoked", "key_id", key.ID, "name", key.Name, "actor", actorName(r.Context()))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "revoked",
+			"key":    key,
+		})
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use GET /admin/api-keys, POST /admin/api-keys or DELETE /admin/api-keys/{id}.")
+	}
+}
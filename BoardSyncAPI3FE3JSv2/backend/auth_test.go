@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKeyStore(t *testing.T) *KeyStore {
+	t.Helper()
+	ks, err := NewKeyStore(filepath.Join(t.TempDir(), "api_keys.json"), "bootstrap-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func TestKeyStoreCreateAndRevoke(t *testing.T) {
+	ks := testKeyStore(t)
+
+	key, plaintext, err := ks.Create("ci", roleOperator, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Hash != "" || !strings.HasPrefix(plaintext, key.Prefix) {
+		t.Errorf("created key = %+v; want no hash and a prefix of the plaintext", key)
+	}
+	if got, ok := ks.Authenticate(plaintext); !ok || got.ID != key.ID || got.Role != roleOperator || got.Tenant != "acme" {
+		t.Fatalf("Authenticate = %+v, %v; want the operator key of acme", got, ok)
+	}
+	if _, ok := ks.Authenticate(plaintext + "x"); ok {
+		t.Error("a wrong key authenticated")
+	}
+
+	// Only the hash is stored, readable by the service user alone
+	data, err := os.ReadFile(ks.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), plaintext) {
+		t.Error("the key store holds the plaintext key")
+	}
+	if info, _ := os.Stat(ks.file); info.Mode().Perm() != 0600 {
+		t.Errorf("key store mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	reloaded, err := NewKeyStore(ks.file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Authenticate(plaintext); !ok {
+		t.Fatal("key did not survive a restart")
+	}
+
+	if _, err := reloaded.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Authenticate(plaintext); ok {
+		t.Error("revoked key still authenticates")
+	}
+	if listed := reloaded.List(); len(listed) != 1 || listed[0].RevokedAt == nil {
+		t.Errorf("listed keys = %+v, want the one key marked revoked", listed)
+	}
+	if _, err := reloaded.Revoke("missing"); err == nil {
+		t.Error("revoking an unknown key succeeded")
+	}
+}
+
+func TestKeyStoreBootstrapKey(t *testing.T) {
+	ks := testKeyStore(t)
+	key, ok := ks.Authenticate("bootstrap-secret")
+	if !ok || key.ID != bootstrapKeyID || key.Role != roleAdmin || key.Tenant != "" {
+		t.Fatalf("bootstrap key = %+v, %v; want a service-level admin", key, ok)
+	}
+	if _, err := ks.Revoke(bootstrapKeyID); err == nil {
+		t.Error("the bootstrap key was revoked")
+	}
+	if _, ok := ks.Authenticate(""); ok {
+		t.Error("an empty key authenticated")
+	}
+}
+
+func TestNewKeyStoreRejectsBadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"corrupt", `[{"id": "k1", "hash": "ab`},
+		{"not a list", `{"id": "k1"}`},
+		{"no hash", `[{"id": "k1", "name": "old", "role": "admin"}]`},
+		{"no role", `[{"id": "k1", "name": "old", "hash": "ab", "admin": true}]`},
+		{"unknown role", `[{"id": "k1", "name": "old", "hash": "ab", "role": "root"}]`},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "api_keys.json")
+		if err := os.WriteFile(file, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewKeyStore(file, ""); err == nil {
+			t.Errorf("%s: NewKeyStore succeeded", tt.name)
+		}
+	}
+
+	if ks, err := NewKeyStore(filepath.Join(t.TempDir(), "missing.json"), ""); err != nil || ks.Configured() {
+		t.Errorf("missing file: %v, configured %v; want an empty store", err, ks != nil && ks.Configured())
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	ks := testKeyStore(t)
+	s := &Server{keys: ks}
+	_, active, _ := ks.Create("dashboard", roleViewer, "")
+	revokedKey, revoked, _ := ks.Create("old", roleAdmin, "")
+	ks.Revoke(revokedKey.ID)
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		role    Role // empty when the request must be rejected
+		message string
+	}{
+		{"X-API-Key", "X-API-Key", active, roleViewer, ""},
+		{"Authorization ApiKey", "Authorization", "ApiKey " + active, roleViewer, ""},
+		{"bootstrap key", "X-API-Key", "bootstrap-secret", roleAdmin, ""},
+		{"no credentials", "", "", "", "Missing credentials"},
+		{"wrong key", "X-API-Key", "bsk_nope", "", "Invalid or revoked"},
+		{"revoked key", "X-API-Key", revoked, "", "Invalid or revoked"},
+		{"bearer without OIDC", "Authorization", "Bearer " + active, "", "Missing credentials"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/sync", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+		p := s.authenticate(rec, req)
+
+		if tt.role != "" {
+			if p == nil || p.Role != tt.role || p.AuthMethod != authMethodAPIKey {
+				t.Errorf("%s: principal = %+v, want role %s", tt.name, p, tt.role)
+			}
+			continue
+		}
+		if p != nil || rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), tt.message) {
+			t.Errorf("%s: principal %+v, status %d, body %s; want a 401 saying %q", tt.name, p, rec.Code, rec.Body, tt.message)
+		}
+	}
+}
+
+func TestAPIKeysHandlerCreatesAndRevokesKeys(t *testing.T) {
+	s := &Server{keys: testKeyStore(t)}
+	handler := s.guard("/admin/api-keys", s.apiKeysHandler)
+	_, operator, _ := s.keys.Create("ci", roleOperator, "")
+
+	call := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := call("GET", "/admin/api-keys", operator, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("listing keys with an operator key: status %d, want 403", rec.Code)
+	}
+
+	rec := call("POST", "/admin/api-keys", "bootstrap-secret", `{"name":"dashboard","role":"viewer"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Key    APIKey `json:"key"`
+		APIKey string `json:"api_key"`
+	}
+	json.NewDecoder(rec.Body).Decode(&created)
+	if key, ok := s.keys.Authenticate(created.APIKey); !ok || key.Role != roleViewer {
+		t.Fatal("the returned key does not authenticate as a viewer")
+	}
+
+	if rec := call("POST", "/admin/api-keys", "bootstrap-secret", `{"name":"x","role":"root"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("creating a key with an unknown role: status %d, want 400", rec.Code)
+	}
+	if rec := call("DELETE", "/admin/api-keys/"+bootstrapKeyID, "bootstrap-secret", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("revoking the bootstrap key: status %d, want 400", rec.Code)
+	}
+	if rec := call("DELETE", "/admin/api-keys/"+created.Key.ID, "bootstrap-secret", ""); rec.Code != http.StatusOK {
+		t.Errorf("revoke: status %d, body %s", rec.Code, rec.Body)
+	}
+	if _, ok := s.keys.Authenticate(created.APIKey); ok {
+		t.Error("key still authenticates after DELETE")
+	}
+}
+
+func TestAPIKeysHandlerKeepsTenantAdminsToTheirTenant(t *testing.T) {
+	reg, _ := testTenantRegistry(t)
+	s := &Server{tenants: reg, keys: testKeyStore(t)}
+	other, _, _ := s.keys.Create("b-ci", roleOperator, "b")
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(withPrincipal(req.Context(), &Principal{Name: "a-admin", Role: roleAdmin, Tenant: "a"}))
+		rec := httptest.NewRecorder()
+		s.apiKeysHandler(rec, req)
+		return rec
+	}
+
+	rec := call("POST", "/admin/api-keys", `{"name":"a-ci","role":"operator"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Key    APIKey `json:"key"`
+		APIKey string `json:"api_key"`
+	}
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.Key.Tenant != "a" {
+		t.Errorf("key created by a tenant admin is bound to %q, want a", created.Key.Tenant)
+	}
+	if key, ok := s.keys.Authenticate(created.APIKey); !ok || key.Tenant != "a" {
+		t.Error("the returned key does not authenticate for tenant a")
+	}
+
+	if rec := call("POST", "/admin/api-keys", `{"name":"x","tenant":"b"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("creating a key for another tenant: status %d, want 403", rec.Code)
+	}
+	if rec := call("DELETE", "/admin/api-keys/"+other.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("revoking another tenant's key: status %d, want 404", rec.Code)
+	}
+	if key, _ := s.keys.Get(other.ID); key.RevokedAt != nil {
+		t.Error("another tenant's key was revoked")
+	}
+
+	var listed struct {
+		Keys []APIKey `json:"keys"`
+	}
+	json.NewDecoder(call("GET", "/admin/api-keys", "").Body).Decode(&listed)
+	if len(listed.Keys) != 1 || listed.Keys[0].ID != created.Key.ID {
+		t.Errorf("tenant admin lists %+v, want only its own key", listed.Keys)
+	}
+
+	if rec := call("DELETE", "/admin/api-keys/"+created.Key.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("revoking own key: status %d, body %s", rec.Code, rec.Body)
+	}
+	if _, ok := s.keys.Authenticate(created.APIKey); ok {
+		t.Error("key still authenticates after DELETE")
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// cacheClockSkew is subtracted from the last fetch time when asking a
+// tracker for changes, so edits made while a fetch was in flight are not lost.
+const cacheClockSkew = time.Minute
+
+// TrackerCache holds the latest copy of one pair's Asana tasks and
+// YouTrack issues so analysis does not refetch both trackers on
+// every call. Reads within the TTL are served from memory. After that only
+// what changed since the last fetch is requested, and every fullEvery a full
+// fetch reconciles deletions that the incremental queries cannot see.
+//
+// Writes through the tracker clients and incoming webhooks mark a side dirty,
+// which makes the next read refresh it regardless of the TTL.
+type TrackerCache struct {
+	ttl       time.Duration
+	fullEvery time.Duration
+
+	asana    cacheSide
+	youTrack cacheSide
+
+	asanaTasks     map[string]AsanaTask
+	youTrackIssues map[string]YouTrackIssue
+}
+
+// cacheSide is the bookkeeping for one tracker. mu is held for the whole
+// refresh, so concurrent readers wait for one fetch instead of each starting
+// their own.
+type cacheSide struct {
+	mu        sync.Mutex
+	order     []string // IDs in the order the tracker returned them
+	loaded    bool
+	fetchedAt time.Time // start of the last successful fetch
+	fullAt    time.Time // start of the last successful full fetch
+	dirty     bool
+	needFull  bool
+
+	hits        int
+	incremental int
+	full        int
+	lastError   string
+}
+
+// CacheSideStats is reported under "cache" in /status
+type CacheSideStats struct {
+	Loaded      bool      `json:"loaded"`
+	Count       int       `json:"count"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	FullAt      time.Time `json:"full_at"`
+	Dirty       bool      `json:"dirty"`
+	Hits        int       `json:"hits"`
+	Incremental int       `json:"incremental_fetches"`
+	Full        int       `json:"full_fetches"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+func NewTrackerCache(ttl, fullEvery time.Duration) *TrackerCache {
+	return &TrackerCache{
+		ttl:            ttl,
+		fullEvery:      fullEvery,
+		asanaTasks:     make(map[string]AsanaTask),
+		youTrackIssues: make(map[string]YouTrackIssue),
+	}
+}
+
+// plan decides what a read needs: nothing, an incremental or a full fetch.
+func (s *cacheSide) plan(now time.Time, ttl, fullEvery time.Duration) (refresh, full bool) {
+	switch {
+	case !s.loaded || s.needFull || now.Sub(s.fullAt) >= fullEvery:
+		return true, true
+	case s.dirty || now.Sub(s.fetchedAt) >= ttl:
+		return true, false
+	}
+	return false, false
+}
+
+// forgetLocked removes id from the order, so a later incremental fetch that
+// brings it back appends it once; s.mu must be held.
+func (s *cacheSide) forgetLocked(id string) {
+	for i, existing := range s.order {
+		if existing == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *cacheSide) stats(count int) CacheSideStats {
+	return CacheSideStats{
+		Loaded:      s.loaded,
+		Count:       count,
+		FetchedAt:   s.fetchedAt,
+		FullAt:      s.fullAt,
+		Dirty:       s.dirty,
+		Hits:        s.hits,
+		Incremental: s.incremental,
+		Full:        s.full,
+		LastError:   s.lastError,
+	}
+}
+
+// AsanaTasks returns the project's tasks, refreshing them if needed.
+func (c *TrackerCache) AsanaTasks(ctx context.Context) ([]AsanaTask, error) {
+	if c == nil {
+		return fetchAsanaTasks(ctx)
+	}
+
+	s := &c.asana
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	started := time.Now()
+	refresh, full := s.plan(started, c.ttl, c.fullEvery)
+	if !refresh {
+		s.hits++
+		return c.asanaListLocked(), nil
+	}
+
+	if !full {
+		changed, err := asanaFrom(ctx).TasksModifiedSince(ctx, pairFrom(ctx).AsanaProjectID, s.fetchedAt.Add(-cacheClockSkew))
+		if err == nil {
+			for _, task := range changed {
+				if _, ok := c.asanaTasks[task.GID]; !ok {
+					s.order = append(s.order, task.GID)
+				}
+				c.asanaTasks[task.GID] = task
+			}
+			s.incremental++
+			s.fetchedAt, s.dirty, s.lastError = started, false, ""
+			return c.asanaListLocked(), nil
+		}
+		slog.WarnContext(ctx, "Incremental Asana refresh failed, doing a full fetch", "error", err)
+	}
+
+	tasks, err := fetchAsanaTasks(ctx)
+	if err != nil {
+		s.lastError = err.Error()
+		return nil, err
+	}
+	c.asanaTasks = make(map[string]AsanaTask, len(tasks))
+	s.order = s.order[:0]
+	for _, task := range tasks {
+		c.asanaTasks[task.GID] = task
+		s.order = append(s.order, task.GID)
+	}
+	s.full++
+	s.loaded, s.needFull, s.dirty, s.lastError = true, false, false, ""
+	s.fetchedAt, s.fullAt = started, started
+	return c.asanaListLocked(), nil
+}
+
+func (c *TrackerCache) asanaListLocked() []AsanaTask {
+	tasks := make([]AsanaTask, 0, len(c.asanaTasks))
+	for _, gid := range c.asana.order {
+		if task, ok := c.asanaTasks[gid]; ok {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// YouTrackIssues returns the project's issues, refreshing them if needed.
+func (c *TrackerCache) YouTrackIssues(ctx context.Context) ([]YouTrackIssue, error) {
+	if c == nil {
+		return fetchYouTrackIssues(ctx)
+	}
+
+	s := &c.youTrack
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	started := time.Now()
+	refresh, full := s.plan(started, c.ttl, c.fullEvery)
+	if !refresh {
+		s.hits++
+		return c.youTrackListLocked(), nil
+	}
+
+	if !full {
+		changed, err := fetchYouTrackIssuesUpdatedSince(ctx, s.fetchedAt.Add(-cacheClockSkew))
+		if err == nil {
+			for _, issue := range changed {
+				if _, ok := c.youTrackIssues[issue.ID]; !ok {
+					s.order = append(s.order, issue.ID)
+				}
+				c.youTrackIssues[issue.ID] = issue
+			}
+			s.incremental++
+			s.fetchedAt, s.dirty, s.lastError = started, false, ""
+			return c.youTrackListLocked(), nil
+		}
+		slog.WarnContext(ctx, "Incremental YouTrack refresh failed, doing a full fetch", "error", err)
+	}
+
+	issues, err := fetchYouTrackIssues(ctx)
+	if err != nil {
+		s.lastError = err.Error()
+		return nil, err
+	}
+	c.youTrackIssues = make(map[string]YouTrackIssue, len(issues))
+	s.order = s.order[:0]
+	for _, issue := range issues {
+		c.youTrackIssues[issue.ID] = issue
+		s.order = append(s.order, issue.ID)
+	}
+	s.full++
+	s.loaded, s.needFull, s.dirty, s.lastError = true, false, false, ""
+	s.fetchedAt, s.fullAt = started, started
+	return c.youTrackListLocked(), nil
+}
+
+func (c *TrackerCache) youTrackListLocked() []YouTrackIssue {
+	issues := make([]YouTrackIssue, 0, len(c.youTrackIssues))
+	for _, id := range c.youTrack.order {
+		if issue, ok := c.youTrackIssues[id]; ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// MarkDirty makes the next read of tracker refresh it.
+func (c *TrackerCache) MarkDirty(tracker string) {
+	if c == nil {
+		return
+	}
+	s := c.side(tracker)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.dirty = true
+	s.mu.Unlock()
+}
+
+// RequestFullRefresh makes the next read of tracker do a full fetch, which
+// is how deletions reported by webhooks are picked up.
+func (c *TrackerCache) RequestFullRefresh(tracker string) {
+	if c == nil {
+		return
+	}
+	s := c.side(tracker)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.needFull = true
+	s.mu.Unlock()
+}
+
+// ForgetAsanaTask drops a task that was deleted or left the project.
+func (c *TrackerCache) ForgetAsanaTask(gid string) {
+	if c == nil {
+		return
+	}
+	c.asana.mu.Lock()
+	defer c.asana.mu.Unlock()
+	delete(c.asanaTasks, gid)
+	c.asana.forgetLocked(gid)
+}
+
+// ForgetYouTrackIssue drops a deleted issue. The cache is keyed by database
+// ID; when id is a readable ID like "PRJ-12" the next read does a full fetch.
+func (c *TrackerCache) ForgetYouTrackIssue(id string) {
+	if c == nil {
+		return
+	}
+	c.youTrack.mu.Lock()
+	defer c.youTrack.mu.Unlock()
+	if _, ok := c.youTrackIssues[id]; ok {
+		delete(c.youTrackIssues, id)
+		c.youTrack.forgetLocked(id)
+		return
+	}
+	c.youTrack.needFull = true
+}
+
+func (c *TrackerCache) side(tracker string) *cacheSide {
+	switch tracker {
+	case trackerAsana:
+		return &c.asana
+	case trackerYouTrack:
+		return &c.youTrack
+	}
+	return nil
+}
+
+func (c *TrackerCache) Stats() map[string]interface{} {
+	if c == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+
+	c.asana.mu.Lock()
+	asana := c.asana.stats(len(c.asanaTasks))
+	c.asana.mu.Unlock()
+	c.youTrack.mu.Lock()
+	youTrack := c.youTrack.stats(len(c.youTrackIssues))
+	c.youTrack.mu.Unlock()
+
+	return map[string]interface{}{
+		"enabled":              true,
+		"ttl_seconds":          int(c.ttl.Seconds()),
+		"full_refresh_minutes": int(c.fullEvery.Minutes()),
+		"asana":                asana,
+		"youtrack":             youTrack,
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeAsana serves a project's tasks; tasks is read on every request
+type fakeAsana struct {
+	mu          sync.Mutex
+	tasks       []AsanaTask
+	full        int32
+	incremental int32
+}
+
+func (f *fakeAsana) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/projects/A1/tasks":
+		atomic.AddInt32(&f.full, 1)
+	case "/tasks":
+		atomic.AddInt32(&f.incremental, 1)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	json.NewEncoder(w).Encode(AsanaResponse{Data: f.tasks})
+}
+
+func (f *fakeAsana) set(gids ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tasks = nil
+	for _, gid := range gids {
+		f.tasks = append(f.tasks, AsanaTask{GID: gid, Name: "Task " + gid})
+	}
+}
+
+// cacheTestContext returns a pair context whose Asana client talks to f
+func cacheTestContext(t *testing.T, f *fakeAsana) (context.Context, *TrackerCache) {
+	t.Helper()
+	useTestConfig(t)
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	tenant := &Tenant{id: "test", asana: &AsanaClient{newTrackerClient(trackerAsana, srv.URL, "token")}}
+	e := NewSyncEngine(ProjectPair{Name: defaultPairName, AsanaProjectID: "A1", YouTrackProjectID: "YT"},
+		filepath.Join(t.TempDir(), "ignored.json"), nil, NewRunCoordinator())
+	e.tenant = tenant
+	e.cache = NewTrackerCache(time.Hour, time.Hour)
+	return withPair(context.Background(), e), e.cache
+}
+
+func taskIDs(tasks []AsanaTask) string {
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.GID
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestTrackerCacheForgottenTaskComesBackOnce(t *testing.T) {
+	f := &fakeAsana{}
+	f.set("1", "2", "3")
+	ctx, cache := cacheTestContext(t, f)
+
+	if _, err := cache.AsanaTasks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cache.ForgetAsanaTask("2")
+
+	// The task is re-added to the project and shows up as modified
+	f.set("2")
+	cache.MarkDirty(trackerAsana)
+	tasks, err := cache.AsanaTasks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := taskIDs(tasks); got != "[1 3 2]" {
+		t.Errorf("tasks = %s, want [1 3 2]", got)
+	}
+	if f.incremental != 1 {
+		t.Errorf("%d incremental fetches, want 1", f.incremental)
+	}
+}
+
+func TestTrackerCacheRequestFullRefreshRefetchesProject(t *testing.T) {
+	f := &fakeAsana{}
+	f.set("1", "2")
+	ctx, cache := cacheTestContext(t, f)
+
+	if _, err := cache.AsanaTasks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	f.set("1")
+	cache.RequestFullRefresh(trackerAsana)
+	tasks, err := cache.AsanaTasks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := taskIDs(tasks); got != "[1]" || f.full != 2 {
+		t.Errorf("tasks = %s after %d full fetches, want [1] after 2", got, f.full)
+	}
+}
+
+func TestTrackerCacheConcurrentReadsShareOneFetch(t *testing.T) {
+	f := &fakeAsana{}
+	f.set("1", "2", "3")
+	ctx, cache := cacheTestContext(t, f)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			switch i % 4 {
+			case 0:
+				cache.MarkDirty(trackerAsana)
+			case 1:
+				cache.ForgetAsanaTask("3")
+			case 2:
+				cache.Stats()
+			}
+			tasks, err := cache.AsanaTasks(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			seen := make(map[string]bool)
+			for _, task := range tasks {
+				if seen[task.GID] {
+					t.Errorf("task %s listed twice in %s", task.GID, taskIDs(tasks))
+				}
+				seen[task.GID] = true
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if f.full != 1 {
+		t.Errorf("%d full fetches, want 1", f.full)
+	}
+}
+
+func TestFetchYouTrackIssuesUpdatedSincePagesToTheEnd(t *testing.T) {
+	useTestConfig(t)
+	const total = 450
+	var pages int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pages, 1)
+		skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+		top, _ := strconv.Atoi(r.URL.Query().Get("$top"))
+		issues := []YouTrackIssue{}
+		for i := skip; i < total && i < skip+top; i++ {
+			issues = append(issues, YouTrackIssue{ID: fmt.Sprint(i)})
+		}
+		json.NewEncoder(w).Encode(issues)
+	}))
+	t.Cleanup(srv.Close)
+	ctx := youTrackTestContext(t, srv.URL)
+
+	issues, err := fetchYouTrackIssuesUpdatedSince(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != total || issues[total-1].ID != fmt.Sprint(total-1) || pages != 3 {
+		t.Fatalf("got %d issues in %d pages, want %d in 3", len(issues), pages, total)
+	}
+}
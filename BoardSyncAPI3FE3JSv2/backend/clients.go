@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const asanaBaseURL = "https://app.asana.com/api/1.0"
+
+// Typed tracker errors. Use errors.Is(err, ErrNotFound) etc. on anything
+// returned by the clients.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrValidation   = errors.New("validation failed")
+)
+
+// APIError is returned for any non-2xx tracker response
+type APIError struct {
+	Tracker    string
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+	Kind       error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error: %d - %s", e.Tracker, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+func newAPIError(tracker string, req *http.Request, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	bodyStr := string(body)
+	if len(bodyStr) > 500 {
+		bodyStr = bodyStr[:500] + "..."
+	}
+
+	var kind error
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		kind = ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		kind = ErrUnauthorized
+	case resp.StatusCode == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity:
+		kind = ErrValidation
+	}
+
+	return &APIError{
+		Tracker:    tracker,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		StatusCode: resp.StatusCode,
+		Body:       redactSecrets(bodyStr),
+		Kind:       kind,
+	}
+}
+
+// trackerClient holds what every tracker API client shares: one pooled
+// http.Client, the base URL and bearer token, and JSON request/response
+// handling.
+type trackerClient struct {
+	name    string
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newTrackerClient(name, baseURL, token string) trackerClient {
+	return trackerClient{
+		name:    name,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConns:        20,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+// do sends a JSON request and decodes a JSON response into out (if non-nil).
+// Idempotent requests are retried by trackerDo; see retry.go.
+func (c *trackerClient) do(ctx context.Context, method, path string, query url.Values, body, out interface{}, idempotent bool) (err error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode %s request: %w", c.name, err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + encodeQuery(query)
+	}
+
+	ctx, span := startSpan(ctx, c.name+" "+method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(method), semconv.URLPath(path)))
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("build %s request: %w", c.name, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id := requestIDFrom(ctx); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+
+	start := time.Now()
+	resp, err := trackerDo(c.name, c.http, req, idempotent)
+	if err != nil {
+		return fmt.Errorf("%s %s %s: %w", c.name, method, path, err)
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	slog.DebugContext(ctx, "Tracker call", "tracker", c.name, "method", method, "path", path,
+		"status", resp.StatusCode, "duration_ms", time.Since(start).Milliseconds())
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(c.name, req, resp)
+	}
+	if t := tenantFrom(ctx); t != nil && method != http.MethodGet {
+		t.Pairs().MarkDirty(c.name)
+	}
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s response from %s: %w", c.name, path, err)
+	}
+	return nil
+}
+
+// encodeQuery is url.Values.Encode with spaces as %20, which YouTrack's
+// query parser expects.
+func encodeQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+// AsanaClient talks to the Asana REST API
+type AsanaClient struct {
+	trackerClient
+}
+
+func NewAsanaClient(token string) *AsanaClient {
+	return &AsanaClient{newTrackerClient(trackerAsana, asanaBaseURL, token)}
+}
+
+const asanaTaskFields = "gid,name,notes,completed_at,created_at,modified_at,memberships.section.gid,memberships.section.name,tags.gid,tags.name"
+
+func (c *AsanaClient) ProjectTasks(ctx context.Context, projectID string) ([]AsanaTask, error) {
+	var resp AsanaResponse
+	query := url.Values{"opt_fields": {asanaTaskFields}}
+	if err := c.do(ctx, http.MethodGet, "/projects/"+projectID+"/tasks", query, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// TasksModifiedSince returns the project's tasks changed after since. Tasks
+// deleted or moved out of the project are not reported.
+func (c *AsanaClient) TasksModifiedSince(ctx context.Context, projectID string, since time.Time) ([]AsanaTask, error) {
+	var resp AsanaResponse
+	query := url.Values{
+		"project":        {projectID},
+		"modified_since": {since.UTC().Format(time.RFC3339)},
+		"opt_fields":     {asanaTaskFields},
+	}
+	if err := c.do(ctx, http.MethodGet, "/tasks", query, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (c *AsanaClient) DeleteTask(ctx context.Context, taskID string) error {
+	return c.do(ctx, http.MethodDelete, "/tasks/"+taskID, nil, nil, nil, true)
+}
+
+// asanaSnapshotFields is everything needed to recreate a deleted task
+const asanaSnapshotFields = "gid,name,notes,completed,due_on,assignee.gid,memberships.project.gid,memberships.section.gid,memberships.section.name,tags.gid,tags.name,custom_fields.gid,custom_fields.name,custom_fields.resource_subtype,custom_fields.text_value,custom_fields.number_value,custom_fields.enum_value.gid"
+
+// Task returns the full task as raw JSON
+func (c *AsanaClient) Task(ctx context.Context, taskID string) (json.RawMessage, error) {
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	query := url.Values{"opt_fields": {asanaSnapshotFields}}
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+taskID, query, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// AsanaStory is a task story; comments have type "comment"
+type AsanaStory struct {
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at"`
+	CreatedBy struct {
+		Name string `json:"name"`
+	} `json:"created_by"`
+}
+
+func (c *AsanaClient) TaskStories(ctx context.Context, taskID string) ([]AsanaStory, error) {
+	var resp struct {
+		Data []AsanaStory `json:"data"`
+	}
+	query := url.Values{"opt_fields": {"type,text,created_at,created_by.name"}}
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+taskID+"/stories", query, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// CreateTask creates a task and returns its GID. Not retried on 5xx.
+func (c *AsanaClient) CreateTask(ctx context.Context, data map[string]interface{}) (string, error) {
+	var resp struct {
+		Data struct {
+			GID string `json:"gid"`
+		} `json:"data"`
+	}
+	body := map[string]interface{}{"data": data}
+	if err := c.do(ctx, http.MethodPost, "/tasks", nil, body, &resp, false); err != nil {
+		return "", err
+	}
+	return resp.Data.GID, nil
+}
+
+// CreateWebhook registers target for events on resource. Asana sends the
+// handshake to target before this returns.
+func (c *AsanaClient) CreateWebhook(ctx context.Context, resource, target string) (string, error) {
+	var resp struct {
+		Data struct {
+			GID string `json:"gid"`
+		} `json:"data"`
+	}
+	body := map[string]interface{}{"data": map[string]string{"resource": resource, "target": target}}
+	if err := c.do(ctx, http.MethodPost, "/webhooks", nil, body, &resp, false); err != nil {
+		return "", err
+	}
+	return resp.Data.GID, nil
+}
+
+// AddTaskToSection moves a task into a section; repeating it is harmless.
+func (c *AsanaClient) AddTaskToSection(ctx context.Context, sectionID, taskID string) error {
+	body := map[string]interface{}{"data": map[string]string{"task": taskID}}
+	return c.do(ctx, http.MethodPost, "/sections/"+sectionID+"/addTask", nil, body, nil, true)
+}
+
+// UpdateTask sets fields on a task; repeating it is harmless.
+func (c *AsanaClient) UpdateTask(ctx context.Context, taskID string, data map[string]interface{}) error {
+	body := map[string]interface{}{"data": data}
+	return c.do(ctx, http.MethodPut, "/tasks/"+taskID, nil, body, nil, true)
+}
+
+func (c *AsanaClient) AddComment(ctx context.Context, taskID, text string) error {
+	body := map[string]interface{}{"data": map[string]string{"text": text}}
+	return c.do(ctx, http.MethodPost, "/tasks/"+taskID+"/stories", nil, body, nil, false)
+}
+
+// AsanaResource is the gid and name of any Asana object
+type AsanaResource struct {
+	GID  string `json:"gid"`
+	Name string `json:"name"`
+}
+
+// Me returns the user the token belongs to
+func (c *AsanaClient) Me(ctx context.Context) (AsanaResource, error) {
+	var resp struct {
+		Data AsanaResource `json:"data"`
+	}
+	query := url.Values{"opt_fields": {"gid,name"}}
+	err := c.do(ctx, http.MethodGet, "/users/me", query, nil, &resp, true)
+	return resp.Data, err
+}
+
+func (c *AsanaClient) Project(ctx context.Context, projectID string) (AsanaResource, error) {
+	var resp struct {
+		Data AsanaResource `json:"data"`
+	}
+	query := url.Values{"opt_fields": {"gid,name"}}
+	err := c.do(ctx, http.MethodGet, "/projects/"+projectID, query, nil, &resp, true)
+	return resp.Data, err
+}
+
+func (c *AsanaClient) ProjectSections(ctx context.Context, projectID string) ([]AsanaResource, error) {
+	var resp struct {
+		Data []AsanaResource `json:"data"`
+	}
+	query := url.Values{"opt_fields": {"gid,name"}}
+	if err := c.do(ctx, http.MethodGet, "/projects/"+projectID+"/sections", query, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// YouTrackClient talks to the YouTrack REST API
+type YouTrackClient struct {
+	trackerClient
+	schemas fieldSchemaCache
+}
+
+func NewYouTrackClient(baseURL, token string) *YouTrackClient {
+	return &YouTrackClient{trackerClient: newTrackerClient(trackerYouTrack, baseURL, token)}
+}
+
+// YouTrackProject is the subset of project fields the service uses
+type YouTrackProject struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ShortName string `json:"shortName"`
+}
+
+// Issues runs an issue search. An empty query lists every visible issue.
+func (c *YouTrackClient) Issues(ctx context.Context, query, fields string, top int) ([]YouTrackIssue, error) {
+	return c.IssuesPage(ctx, query, fields, 0, top)
+}
+
+// IssuesPage returns up to top issues matching query, after the first skip.
+func (c *YouTrackClient) IssuesPage(ctx context.Context, query, fields string, skip, top int) ([]YouTrackIssue, error) {
+	params := url.Values{"fields": {fields}, "$top": {fmt.Sprint(top)}}
+	if skip > 0 {
+		params.Set("$skip", fmt.Sprint(skip))
+	}
+	if query != "" {
+		params.Set("query", query)
+	}
+
+	var issues []YouTrackIssue
+	if err := c.do(ctx, http.MethodGet, "/api/issues", params, nil, &issues, true); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// ProjectIssuesPage returns up to top issues of the project via the admin
+// API, after the first skip.
+func (c *YouTrackClient) ProjectIssuesPage(ctx context.Context, projectID, fields string, skip, top int) ([]YouTrackIssue, error) {
+	params := url.Values{"fields": {fields}, "$top": {fmt.Sprint(top)}}
+	if skip > 0 {
+		params.Set("$skip", fmt.Sprint(skip))
+	}
+
+	var issues []YouTrackIssue
+	if err := c.do(ctx, http.MethodGet, "/api/admin/projects/"+projectID+"/issues", params, nil, &issues, true); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// AdminProjects lists projects via the admin API
+func (c *YouTrackClient) AdminProjects(ctx context.Context, top int) ([]YouTrackProject, error) {
+	params := url.Values{"fields": {"id,name,shortName"}, "$top": {fmt.Sprint(top)}}
+
+	var projects []YouTrackProject
+	if err := c.do(ctx, http.MethodGet, "/api/admin/projects", params, nil, &projects, true); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// Projects lists projects via the non-admin API
+func (c *YouTrackClient) Projects(ctx context.Context) ([]YouTrackProject, error) {
+	params := url.Values{"fields": {"id,name,shortName"}}
+
+	var projects []YouTrackProject
+	if err := c.do(ctx, http.MethodGet, "/api/projects", params, nil, &projects, true); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// CreateIssue is not retried on 5xx: a retry could create a second issue.
+// It returns the readable ID (e.g. "PROJ-12") of the new issue.
+func (c *YouTrackClient) CreateIssue(ctx context.Context, payload map[string]interface{}) (string, error) {
+	var created struct {
+		ID         string `json:"id"`
+		IDReadable string `json:"idReadable"`
+	}
+	params := url.Values{"fields": {"id,idReadable"}}
+	if err := c.do(ctx, http.MethodPost, "/api/issues", params, payload, &created, false); err != nil {
+		return "", err
+	}
+	if created.IDReadable != "" {
+		return created.IDReadable, nil
+	}
+	return created.ID, nil
+}
+
+// UpdateIssue posts a partial issue; repeating it is harmless, so it is retried.
+func (c *YouTrackClient) UpdateIssue(ctx context.Context, issueID string, payload map[string]interface{}) error {
+	return c.do(ctx, http.MethodPost, "/api/issues/"+issueID, nil, payload, nil, true)
+}
+
+func (c *YouTrackClient) DeleteIssue(ctx context.Context, issueID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/issues/"+issueID, nil, nil, nil, true)
+}
+
+// youTrackSnapshotFields is everything needed to recreate a deleted issue
+const youTrackSnapshotFields = "id,idReadable,summary,description,project(shortName),tags(name),customFields(name,$type,value(name,$type,login,text,minutes,presentation))"
+
+// Issue returns the full issue as raw JSON
+func (c *YouTrackClient) Issue(ctx context.Context, issueID string) (json.RawMessage, error) {
+	var issue json.RawMessage
+	params := url.Values{"fields": {youTrackSnapshotFields}}
+	if err := c.do(ctx, http.MethodGet, "/api/issues/"+issueID, params, nil, &issue, true); err != nil {
+		return nil, err
+	}
+	return issue, nil
+}
+
+// YouTrackComment is an issue comment
+type YouTrackComment struct {
+	Text    string `json:"text"`
+	Created int64  `json:"created"`
+	Author  struct {
+		Login string `json:"login"`
+	} `json:"author"`
+}
+
+func (c *YouTrackClient) IssueComments(ctx context.Context, issueID string) ([]YouTrackComment, error) {
+	var comments []YouTrackComment
+	params := url.Values{"fields": {"text,created,author(login)"}}
+	if err := c.do(ctx, http.MethodGet, "/api/issues/"+issueID+"/comments", params, nil, &comments, true); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (c *YouTrackClient) AddComment(ctx context.Context, issueID, text string) error {
+	body := map[string]interface{}{"text": text}
+	return c.do(ctx, http.MethodPost, "/api/issues/"+issueID+"/comments", nil, body, nil, false)
+}
+
+// FindTagID returns the ID of the tag with the given name, or ErrNotFound.
+func (c *YouTrackClient) FindTagID(ctx context.Context, name string) (string, error) {
+	var tags []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	params := url.Values{"fields": {"id,name"}, "query": {name}}
+	if err := c.do(ctx, http.MethodGet, "/api/tags", params, nil, &tags, true); err != nil {
+		return "", err
+	}
+	for _, tag := range tags {
+		if strings.EqualFold(tag.Name, name) {
+			return tag.ID, nil
+		}
+	}
+	return "", fmt.Errorf("YouTrack tag %q: %w", name, ErrNotFound)
+}
+
+// TagID returns the ID of the tag with the given name, creating it if needed.
+func (c *YouTrackClient) TagID(ctx context.Context, name string) (string, error) {
+	id, err := c.FindTagID(ctx, name)
+	if !errors.Is(err, ErrNotFound) {
+		return id, err
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	body := map[string]interface{}{"name": name}
+	if err := c.do(ctx, http.MethodPost, "/api/tags", url.Values{"fields": {"id"}}, body, &created, false); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// YouTrackUser is the subset of user fields the service uses
+type YouTrackUser struct {
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+// Me returns the user the token belongs to
+func (c *YouTrackClient) Me(ctx context.Context) (YouTrackUser, error) {
+	var user YouTrackUser
+	params := url.Values{"fields": {"login,name"}}
+	err := c.do(ctx, http.MethodGet, "/api/users/me", params, nil, &user, true)
+	return user, err
+}
+
+// YouTrackProjectField is a custom field attached to a project. Bundle is
+// nil for fields without a value set, such as text or period fields.
+type YouTrackProjectField struct {
+	Type  string `json:"$type"`
+	Field struct {
+		Name      string `json:"name"`
+		FieldType struct {
+			ID string `json:"id"`
+		} `json:"fieldType"`
+	} `json:"field"`
+	Bundle *struct {
+		ID     string `json:"id"`
+		Values []struct {
+			Name     string `json:"name"`
+			Archived bool   `json:"archived"`
+		} `json:"values"`
+	} `json:"bundle"`
+}
+
+// ProjectCustomFields lists the custom fields of a project (by database ID)
+// with their bundle values.
+func (c *YouTrackClient) ProjectCustomFields(ctx context.Context, projectID string) ([]YouTrackProjectField, error) {
+	params := url.Values{"fields": {"$type,field(name,fieldType(id)),bundle(id,values(name,archived))"}, "top": {"200"}}
+
+	var fields []YouTrackProjectField
+	if err := c.do(ctx, http.MethodGet, "/api/admin/projects/"+projectID+"/customFields", params, nil, &fields, true); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// AddBundleValue adds a value to a custom field bundle, e.g. bundleType
+// "ownedField". Not retried on 5xx: a retry could add the value twice.
+func (c *YouTrackClient) AddBundleValue(ctx context.Context, bundleType, bundleID, valueType, name string) error {
+	body := map[string]interface{}{"$type": valueType, "name": name}
+	path := "/api/admin/customFieldSettings/bundles/" + bundleType + "/" + bundleID + "/values"
+	return c.do(ctx, http.MethodPost, path, url.Values{"fields": {"id,name"}}, body, nil, false)
+}
+
+// AddIssueTag tags an issue; tagging twice is harmless.
+func (c *YouTrackClient) AddIssueTag(ctx context.Context, issueID, tagID string) error {
+	body := map[string]interface{}{"id": tagID}
+	return c.do(ctx, http.MethodPost, "/api/issues/"+issueID+"/tags", nil, body, nil, true)
+}
+
+// trackerErrorStatus maps a tracker error onto the HTTP status this service
+// should answer with.
+func trackerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return 499 // client closed request
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
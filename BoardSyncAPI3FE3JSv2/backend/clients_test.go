@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// youTrackTestContext returns a context for a pair on project YT of a tenant
+// whose YouTrack client talks to url
+func youTrackTestContext(t *testing.T, url string) context.Context {
+	t.Helper()
+	useTestConfig(t)
+	tenant := &Tenant{id: "test", youTrack: NewYouTrackClient(url, "token")}
+	e := NewSyncEngine(ProjectPair{Name: defaultPairName, AsanaProjectID: "A1", YouTrackProjectID: "YT"},
+		filepath.Join(t.TempDir(), "ignored.json"), nil, NewRunCoordinator())
+	e.tenant = tenant
+	return withPair(context.Background(), e)
+}
+
+func TestYouTrackFullFetchesPageToTheEnd(t *testing.T) {
+	const total = 450
+	fetches := map[string]func(context.Context) ([]YouTrackIssue, error){
+		"query":    getYouTrackIssuesWithQuery,
+		"all":      getYouTrackIssuesSimpleCloud,
+		"projects": getYouTrackIssuesViaProjects,
+	}
+	for name, fetch := range fetches {
+		var pages int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&pages, 1)
+			if r.URL.Query().Has("top") {
+				t.Errorf("%s: %s uses top instead of $top", name, r.URL.Path)
+			}
+			skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+			top, _ := strconv.Atoi(r.URL.Query().Get("$top"))
+			issues := []YouTrackIssue{}
+			for i := skip; i < total && i < skip+top; i++ {
+				issue := YouTrackIssue{ID: fmt.Sprint(i)}
+				issue.Project.ShortName = "YT"
+				issues = append(issues, issue)
+			}
+			json.NewEncoder(w).Encode(issues)
+		}))
+		ctx := youTrackTestContext(t, srv.URL)
+
+		issues, err := fetch(ctx)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(issues) != total || pages != 3 {
+			t.Errorf("%s: got %d issues in %d pages, want %d in 3", name, len(issues), pages, total)
+		}
+	}
+}
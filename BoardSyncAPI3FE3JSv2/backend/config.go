@@ -0,0 +1,946 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration comes from, in increasing precedence: built-in defaults,
+// the YAML file named by CONFIG_FILE (config.yaml), and environment
+// variables. The result is validated as a whole and swapped in atomically,
+// so a reload never exposes a half-applied config.
+
+const defaultConfigFile = "config.yaml"
+
+var activeConfig atomic.Pointer[Config]
+
+// currentConfig returns the active configuration. Callers must not modify it.
+func currentConfig() *Config {
+	return activeConfig.Load()
+}
+
+func setConfig(c Config) {
+	secretRedactor.Add(c.Alerts.secrets()...)
+	activeConfig.Store(&c)
+}
+
+// StateRule maps Asana sections whose lowercased name contains Section (and
+// not Unless) to a YouTrack state. States ending in _NO_SYNC are shown but
+// never synced.
+type StateRule struct {
+	Section string `yaml:"section" json:"section"`
+	Unless  string `yaml:"unless,omitempty" json:"unless,omitempty"`
+	State   string `yaml:"state" json:"state"`
+}
+
+const noSyncStateSuffix = "_NO_SYNC"
+
+// ColumnConfig lists the Asana columns that are synced or only displayed,
+// and how sections map to YouTrack states. Rules are tried in order.
+type ColumnConfig struct {
+	Syncable     []string    `yaml:"syncable" json:"syncable"`
+	DisplayOnly  []string    `yaml:"display_only" json:"display_only"`
+	States       []StateRule `yaml:"states" json:"states"`
+	DefaultState string      `yaml:"default_state" json:"default_state"`
+}
+
+func (c ColumnConfig) all() []string {
+	return append(append([]string(nil), c.Syncable...), c.DisplayOnly...)
+}
+
+// stateFor maps a lowercased Asana section name to a YouTrack state
+func (c ColumnConfig) stateFor(section string) string {
+	for _, rule := range c.States {
+		if strings.Contains(section, rule.Section) && (rule.Unless == "" || !strings.Contains(section, rule.Unless)) {
+			return rule.State
+		}
+	}
+	return c.DefaultState
+}
+
+// activeStates are the YouTrack states that sync targets
+func (c ColumnConfig) activeStates() []string {
+	var states []string
+	for _, rule := range c.States {
+		if !strings.HasSuffix(rule.State, noSyncStateSuffix) && !containsString(states, rule.State) {
+			states = append(states, rule.State)
+		}
+	}
+	return states
+}
+
+// fileConfig is the layout of config.yaml
+type fileConfig struct {
+	Server struct {
+		Port           string `yaml:"port"`
+		PollIntervalMS int    `yaml:"poll_interval_ms"`
+		APIKey         string `yaml:"api_key"` // bootstrap admin key; prefer SYNC_SERVICE_API_KEY
+	} `yaml:"server"`
+
+	// Trackers and project pairs only seed the default tenant on first start
+	Trackers struct {
+		YouTrackBaseURL         string `yaml:"youtrack_base_url"`
+		AsanaRateLimitPerMin    int    `yaml:"asana_rate_limit_per_min"`
+		YouTrackRateLimitPerMin int    `yaml:"youtrack_rate_limit_per_min"`
+	} `yaml:"trackers"`
+	ProjectPairs []ProjectPair `yaml:"project_pairs"`
+
+	Columns    ColumnConfig      `yaml:"columns"`
+	TagMapping map[string]string `yaml:"tag_mapping"`
+
+	Provisioning struct {
+		BundleValues bool `yaml:"bundle_values"`
+	} `yaml:"provisioning"`
+
+	Logging struct {
+		Level  string `yaml:"level"`
+		Format string `yaml:"format"`
+	} `yaml:"logging"`
+
+	Tracing struct {
+		Exporter     string  `yaml:"exporter"`
+		OTLPEndpoint string  `yaml:"otlp_endpoint"`
+		SampleRatio  float64 `yaml:"sample_ratio"`
+		ServiceName  string  `yaml:"service_name"`
+	} `yaml:"tracing"`
+
+	Alerts struct {
+		WebhookURL      string `yaml:"webhook_url"`
+		SlackWebhookURL string `yaml:"slack_webhook_url"`
+		Email           struct {
+			SMTPHost string   `yaml:"smtp_host"`
+			SMTPPort int      `yaml:"smtp_port"`
+			Username string   `yaml:"username"`
+			Password string   `yaml:"password"`
+			From     string   `yaml:"from"`
+			To       []string `yaml:"to"`
+		} `yaml:"email"`
+		FailureThreshold int                      `yaml:"failure_threshold"`
+		QuietPeriods     map[string]time.Duration `yaml:"quiet_periods"`
+	} `yaml:"alerts"`
+
+	Schedules struct {
+		FullReconcileMinutes    int `yaml:"full_reconcile_minutes"`
+		CacheTTLSeconds         int `yaml:"cache_ttl_seconds"`
+		CacheFullRefreshMinutes int `yaml:"cache_full_refresh_minutes"`
+	} `yaml:"schedules"`
+
+	Retry struct {
+		MaxAttempts   int           `yaml:"max_attempts"`
+		BaseDelay     time.Duration `yaml:"base_delay"`
+		MaxDelay      time.Duration `yaml:"max_delay"`
+		MaxRetryAfter time.Duration `yaml:"max_retry_after"`
+	} `yaml:"retry"`
+
+	Auth struct {
+		OIDC struct {
+			Issuer      string            `yaml:"issuer"`
+			Audience    string            `yaml:"audience"`
+			JWKSURL     string            `yaml:"jwks_url"`
+			RoleClaim   string            `yaml:"role_claim"`
+			UserClaim   string            `yaml:"user_claim"`
+			TenantClaim string            `yaml:"tenant_claim"`
+			RoleMap     map[string]string `yaml:"role_map"`
+			DefaultRole string            `yaml:"default_role"`
+			DevIssuer   bool              `yaml:"dev_issuer"`
+		} `yaml:"oidc"`
+		CORS struct {
+			AllowedOrigins   []string `yaml:"allowed_origins"`
+			AllowedMethods   []string `yaml:"allowed_methods"`
+			AllowedHeaders   []string `yaml:"allowed_headers"`
+			AllowCredentials bool     `yaml:"allow_credentials"`
+			MaxAgeSeconds    int      `yaml:"max_age_seconds"`
+		} `yaml:"cors"`
+	} `yaml:"auth"`
+
+	Trash struct {
+		RetentionDays int `yaml:"retention_days"`
+	} `yaml:"trash"`
+
+	Delete struct {
+		Asana                 string `yaml:"asana"`
+		YouTrack              string `yaml:"youtrack"`
+		AsanaArchiveSectionID string `yaml:"asana_archive_section_id"`
+		YouTrackResolvedState string `yaml:"youtrack_resolved_state"`
+		YouTrackArchiveTag    string `yaml:"youtrack_archive_tag"`
+	} `yaml:"delete"`
+
+	Secrets struct {
+		Backend string `yaml:"backend"`
+		File    string `yaml:"file"`
+		Dir     string `yaml:"dir"`
+	} `yaml:"secrets"`
+}
+
+func defaultFileConfig() fileConfig {
+	var fc fileConfig
+	fc.Server.Port = "8080"
+	fc.Server.PollIntervalMS = 60000
+	fc.Trackers.AsanaRateLimitPerMin = 150
+	fc.Trackers.YouTrackRateLimitPerMin = 600
+	fc.Columns = ColumnConfig{
+		Syncable:    []string{"backlog", "in progress", "dev", "stage", "blocked"},
+		DisplayOnly: []string{"ready for stage", "findings"},
+		States: []StateRule{
+			{Section: "backlog", State: "Backlog"},
+			{Section: "in progress", State: "In Progress"},
+			{Section: "dev", Unless: "ready", State: "DEV"},
+			{Section: "stage", Unless: "ready", State: "STAGE"},
+			{Section: "blocked", State: "Blocked"},
+			{Section: "findings", State: "FINDINGS_NO_SYNC"},
+			{Section: "ready for stage", State: "READY_FOR_STAGE_NO_SYNC"},
+		},
+		DefaultState: "Backlog",
+	}
+	fc.TagMapping = defaultTagMapping
+	fc.Logging.Level = "info"
+	fc.Logging.Format = logFormatText
+	fc.Tracing.Exporter = tracingNone
+	fc.Tracing.SampleRatio = 1
+	fc.Tracing.ServiceName = "asana-youtrack-sync"
+	fc.Alerts.Email.SMTPPort = 587
+	fc.Alerts.FailureThreshold = 3
+	fc.Alerts.QuietPeriods = map[string]time.Duration{
+		alertFindings:     24 * time.Hour,
+		alertSyncFailure:  time.Hour,
+		alertAutoStopped:  15 * time.Minute,
+		alertConfigReload: time.Hour,
+	}
+	fc.Schedules.FullReconcileMinutes = 60
+	fc.Schedules.CacheTTLSeconds = 30
+	fc.Schedules.CacheFullRefreshMinutes = 15
+	fc.Retry.MaxAttempts = 4
+	fc.Retry.BaseDelay = 500 * time.Millisecond
+	fc.Retry.MaxDelay = 30 * time.Second
+	fc.Retry.MaxRetryAfter = 2 * time.Minute
+	fc.Auth.OIDC.RoleClaim = "roles"
+	fc.Auth.OIDC.UserClaim = "email"
+	fc.Auth.CORS.AllowedOrigins = []string{"*"}
+	fc.Auth.CORS.AllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	fc.Auth.CORS.AllowedHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "X-Tenant", requestIDHeader}
+	fc.Auth.CORS.MaxAgeSeconds = 600
+	fc.Trash.RetentionDays = 30
+	fc.Delete.Asana = strategyHard
+	fc.Delete.YouTrack = strategyHard
+	fc.Delete.YouTrackResolvedState = "Done"
+	fc.Delete.YouTrackArchiveTag = "archived"
+	fc.Secrets.Backend = secretsEnv
+	fc.Secrets.File = "secrets.enc"
+	fc.Secrets.Dir = "keyring"
+	return fc
+}
+
+// envOverride maps an environment variable onto a config file setting
+type envOverride struct {
+	env  string
+	path string // the setting in config.yaml, for error messages
+	set  func(fc *fileConfig, value string) error
+}
+
+func envString(field func(*fileConfig) *string) func(*fileConfig, string) error {
+	return func(fc *fileConfig, value string) error {
+		*field(fc) = value
+		return nil
+	}
+}
+
+func envInt(field func(*fileConfig) *int) func(*fileConfig, string) error {
+	return func(fc *fileConfig, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("not an integer: %q", value)
+		}
+		*field(fc) = n
+		return nil
+	}
+}
+
+func envFloat(field func(*fileConfig) *float64) func(*fileConfig, string) error {
+	return func(fc *fileConfig, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("not a number: %q", value)
+		}
+		*field(fc) = f
+		return nil
+	}
+}
+
+func envBool(field func(*fileConfig) *bool) func(*fileConfig, string) error {
+	return func(fc *fileConfig, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("not true or false: %q", value)
+		}
+		*field(fc) = b
+		return nil
+	}
+}
+
+func envQuietPeriod(kind string) func(*fileConfig, string) error {
+	return func(fc *fileConfig, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("not a duration like 30m: %q", value)
+		}
+		fc.Alerts.QuietPeriods[kind] = d
+		return nil
+	}
+}
+
+func envList(field func(*fileConfig) *[]string) func(*fileConfig, string) error {
+	return func(fc *fileConfig, value string) error {
+		*field(fc) = splitList(value)
+		return nil
+	}
+}
+
+var envOverrides = []envOverride{
+	{"PORT", "server.port", envString(func(fc *fileConfig) *string { return &fc.Server.Port })},
+	{"POLL_INTERVAL_MS", "server.poll_interval_ms", envInt(func(fc *fileConfig) *int { return &fc.Server.PollIntervalMS })},
+	{"SYNC_SERVICE_API_KEY", "server.api_key", envString(func(fc *fileConfig) *string { return &fc.Server.APIKey })},
+	{"YOUTRACK_BASE_URL", "trackers.youtrack_base_url", envString(func(fc *fileConfig) *string { return &fc.Trackers.YouTrackBaseURL })},
+	{"ASANA_RATE_LIMIT_PER_MIN", "trackers.asana_rate_limit_per_min", envInt(func(fc *fileConfig) *int { return &fc.Trackers.AsanaRateLimitPerMin })},
+	{"YOUTRACK_RATE_LIMIT_PER_MIN", "trackers.youtrack_rate_limit_per_min", envInt(func(fc *fileConfig) *int { return &fc.Trackers.YouTrackRateLimitPerMin })},
+	{"FULL_RECONCILE_MINUTES", "schedules.full_reconcile_minutes", envInt(func(fc *fileConfig) *int { return &fc.Schedules.FullReconcileMinutes })},
+	{"CACHE_TTL_SECONDS", "schedules.cache_ttl_seconds", envInt(func(fc *fileConfig) *int { return &fc.Schedules.CacheTTLSeconds })},
+	{"CACHE_FULL_REFRESH_MINUTES", "schedules.cache_full_refresh_minutes", envInt(func(fc *fileConfig) *int { return &fc.Schedules.CacheFullRefreshMinutes })},
+	{"PROVISION_BUNDLE_VALUES", "provisioning.bundle_values", envBool(func(fc *fileConfig) *bool { return &fc.Provisioning.BundleValues })},
+	{"LOG_LEVEL", "logging.level", envString(func(fc *fileConfig) *string { return &fc.Logging.Level })},
+	{"LOG_FORMAT", "logging.format", envString(func(fc *fileConfig) *string { return &fc.Logging.Format })},
+	{"TRACING_EXPORTER", "tracing.exporter", envString(func(fc *fileConfig) *string { return &fc.Tracing.Exporter })},
+	{"OTEL_EXPORTER_OTLP_ENDPOINT", "tracing.otlp_endpoint", envString(func(fc *fileConfig) *string { return &fc.Tracing.OTLPEndpoint })},
+	{"TRACING_SAMPLE_RATIO", "tracing.sample_ratio", envFloat(func(fc *fileConfig) *float64 { return &fc.Tracing.SampleRatio })},
+	{"OTEL_SERVICE_NAME", "tracing.service_name", envString(func(fc *fileConfig) *string { return &fc.Tracing.ServiceName })},
+	{"ALERT_WEBHOOK_URL", "alerts.webhook_url", envString(func(fc *fileConfig) *string { return &fc.Alerts.WebhookURL })},
+	{"ALERT_SLACK_WEBHOOK_URL", "alerts.slack_webhook_url", envString(func(fc *fileConfig) *string { return &fc.Alerts.SlackWebhookURL })},
+	{"ALERT_SMTP_HOST", "alerts.email.smtp_host", envString(func(fc *fileConfig) *string { return &fc.Alerts.Email.SMTPHost })},
+	{"ALERT_SMTP_PORT", "alerts.email.smtp_port", envInt(func(fc *fileConfig) *int { return &fc.Alerts.Email.SMTPPort })},
+	{"ALERT_SMTP_USERNAME", "alerts.email.username", envString(func(fc *fileConfig) *string { return &fc.Alerts.Email.Username })},
+	{"ALERT_SMTP_PASSWORD", "alerts.email.password", envString(func(fc *fileConfig) *string { return &fc.Alerts.Email.Password })},
+	{"ALERT_EMAIL_FROM", "alerts.email.from", envString(func(fc *fileConfig) *string { return &fc.Alerts.Email.From })},
+	{"ALERT_EMAIL_TO", "alerts.email.to", envList(func(fc *fileConfig) *[]string { return &fc.Alerts.Email.To })},
+	{"ALERT_FAILURE_THRESHOLD", "alerts.failure_threshold", envInt(func(fc *fileConfig) *int { return &fc.Alerts.FailureThreshold })},
+	{"ALERT_QUIET_FINDINGS", "alerts.quiet_periods.findings", envQuietPeriod(alertFindings)},
+	{"ALERT_QUIET_SYNC_FAILURE", "alerts.quiet_periods.sync_failure", envQuietPeriod(alertSyncFailure)},
+	{"ALERT_QUIET_AUTO_STOPPED", "alerts.quiet_periods.auto_stopped", envQuietPeriod(alertAutoStopped)},
+	{"ALERT_QUIET_CONFIG_RELOAD", "alerts.quiet_periods.config_reload", envQuietPeriod(alertConfigReload)},
+	{"HTTP_MAX_ATTEMPTS", "retry.max_attempts", envInt(func(fc *fileConfig) *int { return &fc.Retry.MaxAttempts })},
+	{"OIDC_ISSUER", "auth.oidc.issuer", envString(func(fc *fileConfig) *string { return &fc.Auth.OIDC.Issuer })},
+	{"OIDC_AUDIENCE", "auth.oidc.audience", envString(func(fc *fileConfig) *string { return &fc.Auth.OIDC.Audience })},
+	{"OIDC_JWKS_URL", "auth.oidc.jwks_url", envString(func(fc *fileConfig) *string { return &fc.Auth.OIDC.JWKSURL })},
+	{"OIDC_ROLE_CLAIM", "auth.oidc.role_claim", envString(func(fc *fileConfig) *string { return &fc.Auth.OIDC.RoleClaim })},
+	{"OIDC_USER_CLAIM", "auth.oidc.user_claim", envString(func(fc *fileConfig) *string { return &fc.Auth.OIDC.UserClaim })},
+	{"OIDC_TENANT_CLAIM", "auth.oidc.tenant_claim", envString(func(fc *fileConfig) *string { return &fc.Auth.OIDC.TenantClaim })},
+	{"OIDC_ROLE_MAP", "auth.oidc.role_map", func(fc *fileConfig, value string) error {
+		roles, err := parseRoleMap(value)
+		if err != nil {
+			return err
+		}
+		fc.Auth.OIDC.RoleMap = make(map[string]string, len(roles))
+		for claim, role := range roles {
+			fc.Auth.OIDC.RoleMap[claim] = string(role)
+		}
+		return nil
+	}},
+	{"OIDC_DEFAULT_ROLE", "auth.oidc.default_role", envString(func(fc *fileConfig) *string { return &fc.Auth.OIDC.DefaultRole })},
+	{"OIDC_DEV_ISSUER", "auth.oidc.dev_issuer", envBool(func(fc *fileConfig) *bool { return &fc.Auth.OIDC.DevIssuer })},
+	{"CORS_ALLOWED_ORIGINS", "auth.cors.allowed_origins", envList(func(fc *fileConfig) *[]string { return &fc.Auth.CORS.AllowedOrigins })},
+	{"CORS_ALLOWED_METHODS", "auth.cors.allowed_methods", envList(func(fc *fileConfig) *[]string { return &fc.Auth.CORS.AllowedMethods })},
+	{"CORS_ALLOWED_HEADERS", "auth.cors.allowed_headers", envList(func(fc *fileConfig) *[]string { return &fc.Auth.CORS.AllowedHeaders })},
+	{"CORS_ALLOW_CREDENTIALS", "auth.cors.allow_credentials", envBool(func(fc *fileConfig) *bool { return &fc.Auth.CORS.AllowCredentials })},
+	{"CORS_MAX_AGE", "auth.cors.max_age_seconds", envInt(func(fc *fileConfig) *int { return &fc.Auth.CORS.MaxAgeSeconds })},
+	{"TRASH_RETENTION_DAYS", "trash.retention_days", envInt(func(fc *fileConfig) *int { return &fc.Trash.RetentionDays })},
+	{"DELETE_STRATEGY_ASANA", "delete.asana", envString(func(fc *fileConfig) *string { return &fc.Delete.Asana })},
+	{"DELETE_STRATEGY_YOUTRACK", "delete.youtrack", envString(func(fc *fileConfig) *string { return &fc.Delete.YouTrack })},
+	{"ASANA_ARCHIVE_SECTION_ID", "delete.asana_archive_section_id", envString(func(fc *fileConfig) *string { return &fc.Delete.AsanaArchiveSectionID })},
+	{"YOUTRACK_RESOLVED_STATE", "delete.youtrack_resolved_state", envString(func(fc *fileConfig) *string { return &fc.Delete.YouTrackResolvedState })},
+	{"YOUTRACK_ARCHIVE_TAG", "delete.youtrack_archive_tag", envString(func(fc *fileConfig) *string { return &fc.Delete.YouTrackArchiveTag })},
+	{"SECRETS_BACKEND", "secrets.backend", envString(func(fc *fileConfig) *string { return &fc.Secrets.Backend })},
+	{"SECRETS_FILE", "secrets.file", envString(func(fc *fileConfig) *string { return &fc.Secrets.File })},
+	{"SECRETS_DIR", "secrets.dir", envString(func(fc *fileConfig) *string { return &fc.Secrets.Dir })},
+}
+
+// configFile is the path of the YAML file, and whether it was named
+// explicitly (a missing default file is fine, a missing named one is not).
+func configFile() (string, bool) {
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		return file, true
+	}
+	return defaultConfigFile, false
+}
+
+// loadDotEnv loads .env for local dev; Render sets real environment variables.
+func loadDotEnv() {
+	if os.Getenv("RENDER") == "" {
+		if err := godotenv.Load(); err != nil {
+			slog.Info("No .env file found, using environment variables")
+		}
+	}
+}
+
+// buildConfig reads file (if present), applies environment overrides and
+// validates the result. All problems are reported together, one per line.
+func buildConfig(file string, required bool) (Config, error) {
+	fc := defaultFileConfig()
+
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && !required:
+	case err != nil:
+		return Config{}, err
+	default:
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", file, err)
+		}
+		if len(root.Content) > 0 {
+			if unknown := unknownKeys(root.Content[0], reflect.TypeOf(fc), ""); len(unknown) > 0 {
+				return Config{}, fmt.Errorf("%s", strings.Join(unknown, "\n"))
+			}
+			// The defaults' state rules and tag mapping are replaced, not merged
+			defaults := defaultFileConfig()
+			fc.Columns.States, fc.TagMapping = nil, nil
+			if err := root.Content[0].Decode(&fc); err != nil {
+				var typeErr *yaml.TypeError
+				if errors.As(err, &typeErr) {
+					return Config{}, fmt.Errorf("%s", strings.Join(typeErr.Errors, "\n"))
+				}
+				return Config{}, fmt.Errorf("%s: %w", file, err)
+			}
+			if fc.Columns.States == nil {
+				fc.Columns.States = defaults.Columns.States
+			}
+			if fc.TagMapping == nil {
+				fc.TagMapping = defaults.TagMapping
+			}
+		}
+	}
+
+	var problems []string
+	for _, o := range envOverrides {
+		value := os.Getenv(o.env)
+		if value == "" {
+			continue
+		}
+		if err := o.set(&fc, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (env %s): %v", o.path, o.env, err))
+		}
+	}
+
+	c := fc.toConfig()
+
+	// Without a file, the seed pairs still come from PROJECT_PAIRS_FILE or
+	// ASANA_PROJECT_ID / YOUTRACK_PROJECT_ID as before
+	if len(c.ProjectPairs) == 0 {
+		pairsFile := getEnv("PROJECT_PAIRS_FILE", "project_pairs.json")
+		pairs, err := loadProjectPairs(pairsFile)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("project_pairs (env PROJECT_PAIRS_FILE): %v", err))
+		case pairs != nil:
+			c.ProjectPairs, c.ProjectPairsFile = pairs, pairsFile
+		case c.AsanaProjectID != "" && c.YouTrackProjectID != "":
+			c.ProjectPairs = []ProjectPair{{
+				Name:              defaultPairName,
+				AsanaProjectID:    c.AsanaProjectID,
+				YouTrackProjectID: c.YouTrackProjectID,
+			}}
+		}
+	}
+
+	problems = append(problems, c.validate()...)
+	if len(problems) > 0 {
+		return Config{}, fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return c, nil
+}
+
+// unknownKeys reports keys in node that t has no yaml field for, with their
+// path and line, so a typo does not silently fall back to a default.
+func unknownKeys(node *yaml.Node, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var problems []string
+	switch {
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			problems = append(problems, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s (line %d): unknown setting", keyPath, key.Line))
+				continue
+			}
+			problems = append(problems, unknownKeys(value, field, keyPath)...)
+		}
+	}
+	return problems
+}
+
+func (fc fileConfig) toConfig() Config {
+	c := Config{
+		Port:              fc.Server.Port,
+		PollIntervalMS:    fc.Server.PollIntervalMS,
+		SyncServiceAPIKey: fc.Server.APIKey,
+		AsanaProjectID:    getEnv("ASANA_PROJECT_ID", ""),
+		YouTrackBaseURL:   fc.Trackers.YouTrackBaseURL,
+		YouTrackProjectID: getEnv("YOUTRACK_PROJECT_ID", ""),
+
+		AsanaRateLimitPerMin:    fc.Trackers.AsanaRateLimitPerMin,
+		YouTrackRateLimitPerMin: fc.Trackers.YouTrackRateLimitPerMin,
+		Retry: RetryPolicy{
+			MaxAttempts:   fc.Retry.MaxAttempts,
+			BaseDelay:     fc.Retry.BaseDelay,
+			MaxDelay:      fc.Retry.MaxDelay,
+			MaxRetryAfter: fc.Retry.MaxRetryAfter,
+		},
+
+		Columns:    fc.Columns,
+		TagMapping: fc.TagMapping,
+
+		ProvisionBundleValues: fc.Provisioning.BundleValues,
+
+		LogLevel:  fc.Logging.Level,
+		LogFormat: fc.Logging.Format,
+
+		Tracing: TracingConfig{
+			Exporter:     fc.Tracing.Exporter,
+			OTLPEndpoint: fc.Tracing.OTLPEndpoint,
+			SampleRatio:  fc.Tracing.SampleRatio,
+			ServiceName:  fc.Tracing.ServiceName,
+		},
+
+		Alerts: AlertsConfig{
+			Channels: AlertChannels{
+				WebhookURL:      fc.Alerts.WebhookURL,
+				SlackWebhookURL: fc.Alerts.SlackWebhookURL,
+				Email: EmailConfig{
+					SMTPHost: fc.Alerts.Email.SMTPHost,
+					SMTPPort: fc.Alerts.Email.SMTPPort,
+					Username: fc.Alerts.Email.Username,
+					Password: fc.Alerts.Email.Password,
+					From:     fc.Alerts.Email.From,
+					To:       fc.Alerts.Email.To,
+				},
+			},
+			FailureThreshold: fc.Alerts.FailureThreshold,
+			QuietPeriods:     fc.Alerts.QuietPeriods,
+		},
+
+		OIDCIssuer:      fc.Auth.OIDC.Issuer,
+		OIDCAudience:    fc.Auth.OIDC.Audience,
+		OIDCJWKSURL:     fc.Auth.OIDC.JWKSURL,
+		OIDCRoleClaim:   fc.Auth.OIDC.RoleClaim,
+		OIDCUserClaim:   fc.Auth.OIDC.UserClaim,
+		OIDCTenantClaim: fc.Auth.OIDC.TenantClaim,
+		OIDCRoleMap:     formatRoleMap(fc.Auth.OIDC.RoleMap),
+		OIDCDefaultRole: fc.Auth.OIDC.DefaultRole,
+		OIDCDevIssuer:   fc.Auth.OIDC.DevIssuer,
+
+		CORS: CORSConfig{
+			AllowedOrigins:   fc.Auth.CORS.AllowedOrigins,
+			AllowedMethods:   fc.Auth.CORS.AllowedMethods,
+			AllowedHeaders:   fc.Auth.CORS.AllowedHeaders,
+			ExposedHeaders:   []string{"Location", requestIDHeader},
+			AllowCredentials: fc.Auth.CORS.AllowCredentials,
+			MaxAgeSeconds:    fc.Auth.CORS.MaxAgeSeconds,
+		},
+
+		TrashRetentionDays: fc.Trash.RetentionDays,
+
+		DeleteStrategy:        DeleteStrategy{Asana: fc.Delete.Asana, YouTrack: fc.Delete.YouTrack},
+		AsanaArchiveSectionID: fc.Delete.AsanaArchiveSectionID,
+		YouTrackResolvedState: fc.Delete.YouTrackResolvedState,
+		YouTrackArchiveTag:    fc.Delete.YouTrackArchiveTag,
+
+		CacheTTLSeconds:         fc.Schedules.CacheTTLSeconds,
+		CacheFullRefreshMinutes: fc.Schedules.CacheFullRefreshMinutes,
+		FullReconcileMinutes:    fc.Schedules.FullReconcileMinutes,
+
+		SecretsBackend: fc.Secrets.Backend,
+		SecretsFile:    fc.Secrets.File,
+		SecretsDir:     fc.Secrets.Dir,
+
+		ProjectPairs: fc.ProjectPairs,
+	}
+
+	if c.OIDCDevIssuer && c.OIDCIssuer == "" {
+		c.OIDCIssuer = "http://localhost:" + c.Port + "/dev-oidc"
+		if c.OIDCAudience == "" {
+			c.OIDCAudience = "boardsync-dev"
+		}
+	}
+	return c
+}
+
+func formatRoleMap(roles map[string]string) string {
+	pairs := make([]string, 0, len(roles))
+	for claim, role := range roles {
+		pairs = append(pairs, claim+"="+role)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// validate returns one message per problem, each naming the setting
+func (c Config) validate() []string {
+	var problems []string
+	add := func(path, format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		add("server.port", "must be a port number between 1 and 65535, got %q", c.Port)
+	}
+	if c.PollIntervalMS <= 0 {
+		add("server.poll_interval_ms", "must be positive, got %d", c.PollIntervalMS)
+	}
+	if c.AsanaRateLimitPerMin < 0 {
+		add("trackers.asana_rate_limit_per_min", "cannot be negative (0 means unlimited), got %d", c.AsanaRateLimitPerMin)
+	}
+	if c.YouTrackRateLimitPerMin < 0 {
+		add("trackers.youtrack_rate_limit_per_min", "cannot be negative (0 means unlimited), got %d", c.YouTrackRateLimitPerMin)
+	}
+	if len(c.ProjectPairs) > 0 {
+		if err := validatePairs(c.ProjectPairs); err != nil {
+			add("project_pairs", "%v", err)
+		}
+	}
+
+	cols := c.Columns
+	if len(cols.Syncable) == 0 {
+		add("columns.syncable", "must list at least one column")
+	}
+	seen := make(map[string]string)
+	for _, list := range []struct {
+		path    string
+		columns []string
+	}{{"columns.syncable", cols.Syncable}, {"columns.display_only", cols.DisplayOnly}} {
+		for i, col := range list.columns {
+			path := fmt.Sprintf("%s[%d]", list.path, i)
+			switch {
+			case col == "" || col != strings.ToLower(col):
+				add(path, "must be a non-empty lowercase column name, got %q", col)
+			case seen[col] != "":
+				add(path, "%q is already listed at %s", col, seen[col])
+			default:
+				seen[col] = path
+			}
+		}
+	}
+	for i, rule := range cols.States {
+		path := fmt.Sprintf("columns.states[%d]", i)
+		if rule.Section == "" || rule.Section != strings.ToLower(rule.Section) {
+			add(path+".section", "must be a non-empty lowercase section name, got %q", rule.Section)
+		}
+		if rule.State == "" {
+			add(path+".state", "is required")
+		}
+	}
+	for i, col := range cols.all() {
+		state := cols.stateFor(col)
+		synced := i < len(cols.Syncable)
+		switch {
+		case synced && strings.HasSuffix(state, noSyncStateSuffix):
+			add(fmt.Sprintf("columns.syncable[%d]", i), "%q maps to %s, which is never synced", col, state)
+		case !synced && !strings.HasSuffix(state, noSyncStateSuffix):
+			add(fmt.Sprintf("columns.display_only[%d]", i-len(cols.Syncable)), "%q maps to %q; display-only columns need a state ending in %s", col, state, noSyncStateSuffix)
+		}
+	}
+	if cols.DefaultState == "" {
+		add("columns.default_state", "is required")
+	}
+
+	for tag, subsystem := range c.TagMapping {
+		if strings.TrimSpace(tag) == "" || strings.TrimSpace(subsystem) == "" {
+			add("tag_mapping", "tags and subsystems cannot be empty (%q: %q)", tag, subsystem)
+		}
+	}
+
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		add("logging.level", "%v", err)
+	}
+	if c.LogFormat != logFormatText && c.LogFormat != logFormatJSON {
+		add("logging.format", "must be text or json, got %q", c.LogFormat)
+	}
+
+	if err := c.Tracing.validate(); err != nil {
+		add("tracing", "%v", err)
+	}
+	if err := c.Alerts.validate(); err != nil {
+		add("alerts", "%v", err)
+	}
+
+	if c.FullReconcileMinutes < 0 {
+		add("schedules.full_reconcile_minutes", "cannot be negative, got %d", c.FullReconcileMinutes)
+	}
+	if c.CacheTTLSeconds < 0 {
+		add("schedules.cache_ttl_seconds", "cannot be negative, got %d", c.CacheTTLSeconds)
+	}
+	if c.CacheFullRefreshMinutes < 0 {
+		add("schedules.cache_full_refresh_minutes", "cannot be negative, got %d", c.CacheFullRefreshMinutes)
+	}
+
+	if c.Retry.MaxAttempts < 1 {
+		add("retry.max_attempts", "must be at least 1, got %d", c.Retry.MaxAttempts)
+	}
+	if c.Retry.BaseDelay <= 0 {
+		add("retry.base_delay", "must be a positive duration like 500ms, got %s", c.Retry.BaseDelay)
+	}
+	if c.Retry.MaxDelay < c.Retry.BaseDelay {
+		add("retry.max_delay", "must be at least retry.base_delay (%s), got %s", c.Retry.BaseDelay, c.Retry.MaxDelay)
+	}
+	if c.Retry.MaxRetryAfter <= 0 {
+		add("retry.max_retry_after", "must be a positive duration like 2m, got %s", c.Retry.MaxRetryAfter)
+	}
+
+	if _, err := parseRoleMap(c.OIDCRoleMap); err != nil {
+		add("auth.oidc.role_map", "%v", err)
+	}
+	if c.OIDCDefaultRole != "" && !Role(c.OIDCDefaultRole).Valid() {
+		add("auth.oidc.default_role", "unknown role %q (valid: %s)", c.OIDCDefaultRole, strings.Join(roleNames(), ", "))
+	}
+	if err := c.CORS.Validate(); err != nil {
+		add("auth.cors", "%v", err)
+	}
+
+	if c.TrashRetentionDays <= 0 {
+		add("trash.retention_days", "must be a positive number of days, got %d", c.TrashRetentionDays)
+	}
+	if err := c.DeleteStrategy.validate(c.AsanaArchiveSectionID); err != nil {
+		add("delete", "%v", err)
+	}
+
+	switch c.SecretsBackend {
+	case secretsEnv, secretsFile, secretsKeyring:
+	default:
+		add("secrets.backend", "must be env, file or keyring, got %q", c.SecretsBackend)
+	}
+	return problems
+}
+
+// Reload
+
+// reloadableSettings take effect on reload. Everything else is wired into
+// components at startup and needs a restart.
+var reloadableSettings = map[string]bool{
+	"PollIntervalMS":          true,
+	"AsanaRateLimitPerMin":    true,
+	"YouTrackRateLimitPerMin": true,
+	"Retry":                   true,
+	"Columns":                 true,
+	"TagMapping":              true,
+	"ProvisionBundleValues":   true,
+	"LogLevel":                true,
+	"Alerts":                  true,
+	"DeleteStrategy":          true,
+	"AsanaArchiveSectionID":   true,
+	"YouTrackResolvedState":   true,
+	"YouTrackArchiveTag":      true,
+}
+
+// ConfigReload is the outcome of the last reload, for /admin/config
+type ConfigReload struct {
+	Time            time.Time `json:"time"`
+	Trigger         string    `json:"trigger"` // sighup or the acting user
+	Applied         []string  `json:"applied"`
+	RestartRequired []string  `json:"restart_required"`
+	Error           string    `json:"error,omitempty"`
+}
+
+var (
+	reloadMu   sync.Mutex
+	lastReload *ConfigReload
+)
+
+// reloadConfig rebuilds the configuration and swaps in the settings that can
+// change at runtime. A config that does not validate leaves the running one
+// untouched.
+func reloadConfig(trigger string) ConfigReload {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	result := ConfigReload{Time: time.Now().UTC(), Trigger: trigger, Applied: []string{}, RestartRequired: []string{}}
+	defer func() { lastReload = &result }()
+
+	file, required := configFile()
+	next, err := buildConfig(file, required)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	current := *currentConfig()
+	merged := current
+	cur, nxt, out := reflect.ValueOf(current), reflect.ValueOf(next), reflect.ValueOf(&merged).Elem()
+	for i := 0; i < cur.NumField(); i++ {
+		name := cur.Type().Field(i).Name
+		if reflect.DeepEqual(cur.Field(i).Interface(), nxt.Field(i).Interface()) {
+			continue
+		}
+		if reloadableSettings[name] {
+			out.Field(i).Set(nxt.Field(i))
+			result.Applied = append(result.Applied, name)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, name)
+		}
+	}
+
+	setConfig(merged)
+	if merged.AsanaRateLimitPerMin != current.AsanaRateLimitPerMin || merged.YouTrackRateLimitPerMin != current.YouTrackRateLimitPerMin {
+		configureTrackerLimits(merged.AsanaRateLimitPerMin, merged.YouTrackRateLimitPerMin)
+	}
+	if merged.LogLevel != current.LogLevel {
+		setLogLevel(merged.LogLevel)
+	}
+	return result
+}
+
+// configFileName is the config file in use, for status output
+func configFileName() string {
+	file, _ := configFile()
+	return file
+}
+
+// lastConfigReload is the outcome of the last reload, or nil
+func lastConfigReload() *ConfigReload {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return lastReload
+}
+
+// logReload reports a reload in the service log
+func logReload(r ConfigReload) {
+	switch {
+	case r.Error != "":
+		slog.Error("Config reload rejected; keeping the running config", "trigger", r.Trigger, "problems", strings.Split(r.Error, "\n"))
+		notifyConfigReloadFailed(r)
+	default:
+		slog.Info("Config reloaded", "trigger", r.Trigger, "applied", r.Applied, "restart_required", r.RestartRequired)
+	}
+}
+
+// watchSIGHUP reloads the configuration whenever the process gets SIGHUP.
+func watchSIGHUP() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			logReload(reloadConfig("sighup"))
+		}
+	}()
+}
+
+// Config handler: GET /admin/config shows the running configuration and the
+// last reload; POST /admin/config/reload re-reads the file and environment.
+func (s *Server) configHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireServiceAdmin(w, r) {
+		return
+	}
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/config"), "/")
+
+	switch {
+	case r.Method == "GET" && action == "":
+		c := *currentConfig()
+		if c.SyncServiceAPIKey != "" {
+			c.SyncServiceAPIKey = redactedText
+		}
+		c.Alerts = c.Alerts.redacted()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":      "success",
+			"file":        configFileName(),
+			"config":      c,
+			"last_reload": lastConfigReload(),
+		})
+
+	case r.Method == "POST" && action == "reload":
+		result := reloadConfig(actorName(r.Context()))
+		logReload(result)
+
+		w.Header().Set("Content-Type", "application/json")
+		if result.Error != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":    "invalid_config",
+				"message":  "The configuration was not reloaded; the running config is unchanged.",
+				"problems": strings.Split(result.Error, "\n"),
+				"status":   http.StatusBadRequest,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":           "reloaded",
+			"applied":          result.Applied,
+			"restart_required": result.RestartRequired,
+		})
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use GET /admin/config or POST /admin/config/reload.")
+	}
+}
+
+// runConfigCommand implements "asana-youtrack-sync config validate [file]".
+// It returns the process exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: asana-youtrack-sync config validate [file]")
+		return 2
+	}
+
+	loadDotEnv()
+	file, required := configFile()
+	if len(args) == 2 {
+		file, required = args[1], true
+	}
+
+	c, err := buildConfig(file, required)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s is invalid:\n", file)
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "  %s\n", line)
+		}
+		return 1
+	}
+
+	source := file
+	if _, err := os.Stat(file); err != nil {
+		source = "defaults and environment (no " + file + ")"
+	}
+	fmt.Printf("OK: configuration from %s is valid\n", source)
+	fmt.Printf("  port %s, %d syncable columns, %d state rules, %d tag mappings, secrets backend %s\n",
+		c.Port, len(c.Columns.Syncable), len(c.Columns.States), len(c.TagMapping), c.SecretsBackend)
+	return 0
+}
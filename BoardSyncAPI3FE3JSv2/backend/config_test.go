@@ -0,0 +1,189 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile points CONFIG_FILE at a file holding content and clears
+// the environment overrides the tests rely on
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	for _, env := range []string{"PORT", "POLL_INTERVAL_MS"} {
+		t.Setenv(env, "")
+	}
+	return file
+}
+
+func TestBuildConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		want    func(Config) bool
+		wantErr []string // substrings of the error, one per problem
+	}{
+		{
+			name: "file values",
+			file: "server:\n  poll_interval_ms: 1000\n",
+			want: func(c Config) bool { return c.PollIntervalMS == 1000 && c.Port == "8080" },
+		},
+		{
+			name: "env overrides file",
+			file: "server:\n  poll_interval_ms: 1000\n",
+			env:  map[string]string{"POLL_INTERVAL_MS": "2500"},
+			want: func(c Config) bool { return c.PollIntervalMS == 2500 },
+		},
+		{
+			name:    "bad env value",
+			file:    "",
+			env:     map[string]string{"POLL_INTERVAL_MS": "soon"},
+			wantErr: []string{"server.poll_interval_ms (env POLL_INTERVAL_MS)"},
+		},
+		{
+			name:    "unknown keys with lines",
+			file:    "server:\n  prot: \"9090\"\ncolumns:\n  states:\n    - sectoin: backlog\n      state: Backlog\n",
+			wantErr: []string{"server.prot (line 2): unknown setting", "columns.states[0].sectoin (line 5): unknown setting"},
+		},
+		{
+			name:    "invalid values",
+			file:    "server:\n  port: \"99999\"\nretry:\n  max_attempts: 0\n",
+			wantErr: []string{"server.port: must be a port number", "retry.max_attempts: must be at least 1"},
+		},
+		{
+			name:    "wrong type",
+			file:    "server:\n  poll_interval_ms: often\n",
+			wantErr: []string{"line 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeConfigFile(t, tt.file)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			c, err := buildConfig(file, true)
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatal("buildConfig succeeded, want an error")
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(c) {
+				t.Errorf("unexpected config: port %s, poll interval %d", c.Port, c.PollIntervalMS)
+			}
+		})
+	}
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	writeConfigFile(t, "")
+	if _, err := buildConfig("config.example.yaml", true); err != nil {
+		t.Errorf("config.example.yaml: %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string // prefix of the only problem; empty for none
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"zero poll interval", func(c *Config) { c.PollIntervalMS = 0 }, "server.poll_interval_ms:"},
+		{"negative rate limit", func(c *Config) { c.AsanaRateLimitPerMin = -1 }, "trackers.asana_rate_limit_per_min:"},
+		{"no default state", func(c *Config) { c.Columns.DefaultState = "" }, "columns.default_state:"},
+		{"max delay below base", func(c *Config) { c.Retry.MaxDelay = c.Retry.BaseDelay / 2 }, "retry.max_delay:"},
+		{"unknown default role", func(c *Config) { c.OIDCDefaultRole = "root" }, "auth.oidc.default_role:"},
+		{"credentials with any origin", func(c *Config) { c.CORS.AllowCredentials = true }, "auth.cors:"},
+		{"unknown secrets backend", func(c *Config) { c.SecretsBackend = "vault" }, "secrets.backend:"},
+	}
+	for _, tt := range tests {
+		c := useTestConfig(t)
+		tt.change(&c)
+		problems := c.validate()
+		switch {
+		case tt.want == "" && len(problems) > 0:
+			t.Errorf("%s: unexpected problems %q", tt.name, problems)
+		case tt.want != "" && (len(problems) != 1 || !strings.HasPrefix(problems[0], tt.want)):
+			t.Errorf("%s: problems = %q, want one starting with %q", tt.name, problems, tt.want)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		file            string
+		env             map[string]string
+		applied         []string
+		restartRequired []string
+		wantErr         bool
+		check           func(*Config) bool
+	}{
+		{
+			name:    "reloadable setting applies",
+			file:    "server:\n  poll_interval_ms: 1500\n",
+			applied: []string{"PollIntervalMS"},
+			check:   func(c *Config) bool { return c.PollIntervalMS == 1500 },
+		},
+		{
+			name:            "restart-only setting is reported and kept",
+			file:            "server:\n  port: \"9090\"\n",
+			restartRequired: []string{"Port"},
+			check:           func(c *Config) bool { return c.Port == "8080" },
+		},
+		{
+			name:    "invalid file leaves the running config",
+			file:    "server:\n  poll_interval_ms: 1500\n  port: \"0\"\n",
+			wantErr: true,
+			check:   func(c *Config) bool { return c.PollIntervalMS == 60000 && c.Port == "8080" },
+		},
+		{
+			name:    "env override wins over the file",
+			file:    "server:\n  poll_interval_ms: 1500\n",
+			env:     map[string]string{"POLL_INTERVAL_MS": "2500"},
+			applied: []string{"PollIntervalMS"},
+			check:   func(c *Config) bool { return c.PollIntervalMS == 2500 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfigFile(t, tt.file)
+			useTestConfig(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			r := reloadConfig("test")
+			if (r.Error != "") != tt.wantErr {
+				t.Fatalf("reload error = %q, want error: %v", r.Error, tt.wantErr)
+			}
+			if strings.Join(r.Applied, ",") != strings.Join(tt.applied, ",") {
+				t.Errorf("applied = %v, want %v", r.Applied, tt.applied)
+			}
+			if strings.Join(r.RestartRequired, ",") != strings.Join(tt.restartRequired, ",") {
+				t.Errorf("restart required = %v, want %v", r.RestartRequired, tt.restartRequired)
+			}
+			if c := currentConfig(); !tt.check(c) {
+				t.Errorf("running config: port %s, poll interval %d", c.Port, c.PollIntervalMS)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Run triggers recorded by the coordinator
+const (
+	triggerManual     = "manual"
+	triggerAutoSync   = "auto-sync"
+	triggerAutoCreate = "auto-create"
+)
+
+// RunCoordinator serializes mutating runs (manual /sync and /create,
+// auto-sync, auto-create, bulk delete) per YouTrack project, so two runs can
+// never race through the check-then-act in isDuplicateTicket and create the
+// same issue twice.
+type RunCoordinator struct {
+	mu    sync.Mutex
+	slots map[string]*runSlot
+}
+
+type runSlot struct {
+	sem       chan struct{}
+	trigger   string
+	startedAt time.Time
+	queued    int
+}
+
+// RunSlotStatus describes the current occupancy of a project's run slot.
+type RunSlotStatus struct {
+	InProgress     bool      `json:"in_progress"`
+	CurrentTrigger string    `json:"current_trigger,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	Queued         int       `json:"queued"`
+}
+
+func NewRunCoordinator() *RunCoordinator {
+	return &RunCoordinator{slots: make(map[string]*runSlot)}
+}
+
+func (c *RunCoordinator) slot(project string) *runSlot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.slots[project]
+	if !ok {
+		s = &runSlot{sem: make(chan struct{}, 1)}
+		c.slots[project] = s
+	}
+	return s
+}
+
+// Run waits for the project's slot and then executes fn. Callers that have to
+// wait are counted as queued until they acquire the slot. If ctx ends first,
+// Run gives up its place in the queue and returns ctx.Err() without running
+// fn, so nothing is changed on behalf of a caller that has already left.
+func (c *RunCoordinator) Run(ctx context.Context, project, trigger string, fn func()) error {
+	s := c.slot(project)
+
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		c.mu.Lock()
+		s.queued++
+		c.mu.Unlock()
+
+		var err error
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		c.mu.Lock()
+		s.queued--
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	// The slot may have freed up just as ctx ended
+	if err := ctx.Err(); err != nil {
+		<-s.sem
+		return err
+	}
+	c.execute(s, trigger, fn)
+	return nil
+}
+
+// TryRun executes fn only if no other run holds the project's slot. It
+// returns false without running fn when the slot is busy.
+func (c *RunCoordinator) TryRun(project, trigger string, fn func()) bool {
+	s := c.slot(project)
+
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		return false
+	}
+
+	c.execute(s, trigger, fn)
+	return true
+}
+
+func (c *RunCoordinator) execute(s *runSlot, trigger string, fn func()) {
+	c.mu.Lock()
+	s.trigger = trigger
+	s.startedAt = time.Now()
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		s.trigger = ""
+		s.startedAt = time.Time{}
+		c.mu.Unlock()
+		<-s.sem
+	}()
+
+	fn()
+}
+
+func (c *RunCoordinator) Status(project string) RunSlotStatus {
+	s := c.slot(project)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return RunSlotStatus{
+		InProgress:     s.trigger != "",
+		CurrentTrigger: s.trigger,
+		StartedAt:      s.startedAt,
+		Queued:         s.queued,
+	}
+}
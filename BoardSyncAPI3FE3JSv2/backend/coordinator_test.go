@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunCoordinatorSerializesRunsPerProject(t *testing.T) {
+	c := NewRunCoordinator()
+
+	var active, maxActive, runs int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Run(context.Background(), "YT", triggerManual, func() {
+				n := atomic.AddInt32(&active, 1)
+				for {
+					m := atomic.LoadInt32(&maxActive)
+					if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&runs, 1)
+				atomic.AddInt32(&active, -1)
+			})
+		}()
+	}
+	wg.Wait()
+
+	if runs != 50 {
+		t.Errorf("runs = %d, want 50", runs)
+	}
+	if maxActive != 1 {
+		t.Errorf("%d runs overlapped on one project, want 1", maxActive)
+	}
+}
+
+func TestRunCoordinatorProjectsRunIndependently(t *testing.T) {
+	c := NewRunCoordinator()
+
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go c.Run(context.Background(), "A", triggerAutoSync, func() {
+		close(held)
+		<-release
+	})
+	<-held
+	defer close(release)
+
+	done := make(chan struct{})
+	go c.Run(context.Background(), "B", triggerManual, func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a run on project B waited for project A")
+	}
+}
+
+func TestRunCoordinatorTryRunSkipsBusyProject(t *testing.T) {
+	c := NewRunCoordinator()
+
+	release := make(chan struct{})
+	held := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		c.Run(context.Background(), "YT", triggerManual, func() {
+			close(held)
+			<-release
+		})
+		close(finished)
+	}()
+	<-held
+
+	var skipped, ran int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.TryRun("YT", triggerAutoSync, func() { atomic.AddInt32(&ran, 1) }) {
+				return
+			}
+			atomic.AddInt32(&skipped, 1)
+		}()
+	}
+	wg.Wait()
+	if skipped != 20 || ran != 0 {
+		t.Errorf("while busy: skipped = %d, ran = %d; want 20 and 0", skipped, ran)
+	}
+
+	close(release)
+	<-finished
+	if !c.TryRun("YT", triggerAutoSync, func() { atomic.AddInt32(&ran, 1) }) || ran != 1 {
+		t.Error("TryRun on an idle project did not run")
+	}
+}
+
+func TestRunCoordinatorStatusCountsQueuedRuns(t *testing.T) {
+	c := NewRunCoordinator()
+
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go c.Run(context.Background(), "YT", triggerAutoCreate, func() {
+		close(held)
+		<-release
+	})
+	<-held
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Run(context.Background(), "YT", triggerManual, func() {})
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for c.Status("YT").Queued != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued = %d, want 3", c.Status("YT").Queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	status := c.Status("YT")
+	if !status.InProgress || status.CurrentTrigger != triggerAutoCreate {
+		t.Errorf("status = %+v, want auto-create in progress", status)
+	}
+
+	close(release)
+	wg.Wait()
+	if status := c.Status("YT"); status.InProgress || status.Queued != 0 {
+		t.Errorf("after all runs: status = %+v, want idle", status)
+	}
+}
+
+func TestRunCoordinatorQueuedRunGivesUpWhenContextEnds(t *testing.T) {
+	c := NewRunCoordinator()
+
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go c.Run(context.Background(), "YT", triggerAutoSync, func() {
+		close(held)
+		<-release
+	})
+	<-held
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	var ran int32
+	go func() {
+		result <- c.Run(ctx, "YT", triggerManual, func() { atomic.AddInt32(&ran, 1) })
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for c.Status("YT").Queued != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("run was never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run returned %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued run kept waiting after its context ended")
+	}
+	if ran != 0 {
+		t.Error("fn ran after the caller gave up")
+	}
+	if status := c.Status("YT"); status.Queued != 0 || status.CurrentTrigger != triggerAutoSync {
+		t.Errorf("status = %+v, want only the auto-sync run", status)
+	}
+
+	// A cancelled context never runs, even on an idle project
+	if err := c.Run(ctx, "idle", triggerManual, func() { atomic.AddInt32(&ran, 1) }); err == nil || ran != 0 {
+		t.Errorf("Run on idle project with cancelled context = %v, ran = %d", err, ran)
+	}
+	if c.TryRun("idle", triggerManual, func() {}) == false {
+		t.Error("slot was not released after a cancelled Run")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig is the cross-origin policy applied to every endpoint
+type CORSConfig struct {
+	AllowedOrigins   []string // exact origins, "*" or wildcard subdomains like "https://*.example.com"
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAgeSeconds    int // how long browsers may cache a preflight
+}
+
+// Validate rejects combinations browsers would refuse or that are unsafe.
+func (c CORSConfig) Validate() error {
+	if len(c.AllowedOrigins) == 0 {
+		return fmt.Errorf("CORS_ALLOWED_ORIGINS must list at least one origin (or *)")
+	}
+	if c.AllowCredentials && c.allowsAnyOrigin() {
+		return fmt.Errorf("CORS_ALLOW_CREDENTIALS cannot be combined with CORS_ALLOWED_ORIGINS=*; list the dashboard origin explicitly")
+	}
+	return nil
+}
+
+func (c CORSConfig) allowsAnyOrigin() bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) originAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			return true
+		case strings.EqualFold(allowed, origin):
+			return true
+		case strings.Contains(allowed, "://*."):
+			scheme, suffix, _ := strings.Cut(allowed, "://*")
+			if strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(scheme+"://")+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) methodAllowed(method string) bool {
+	for _, m := range c.AllowedMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware applies the policy in front of the whole mux. Preflights are
+// answered here, before authentication, so browsers can learn that the
+// Authorization and X-API-Key headers are allowed. Requests from origins that
+// are not allowed get a 403; requests without an Origin header (curl, other
+// services) pass through untouched.
+func corsMiddleware(cfg CORSConfig, next http.Handler) http.Handler {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAgeSeconds)
+	anyOrigin := cfg.allowsAnyOrigin()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+
+		if !cfg.originAllowed(origin) {
+			slog.WarnContext(r.Context(), "CORS: rejected origin", "method", r.Method, "path", r.URL.Path, "origin", origin)
+			writeJSONError(w, http.StatusForbidden, "origin_not_allowed", fmt.Sprintf("Origin %s is not allowed.", origin))
+			return
+		}
+
+		if anyOrigin && !cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == http.MethodOptions && requestedMethod != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			if !cfg.methodAllowed(requestedMethod) {
+				writeJSONError(w, http.StatusForbidden, "method_not_allowed", fmt.Sprintf("Method %s is not allowed cross-origin.", requestedMethod))
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAgeSeconds > 0 {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if exposed != "" {
+			w.Header().Set("Access-Control-Expose-Headers", exposed)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// splitList parses a comma-separated setting, dropping blanks
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSOriginAllowed(t *testing.T) {
+	cfg := CORSConfig{AllowedOrigins: []string{"https://dash.example.org", "https://*.example.com"}}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://dash.example.org", true},
+		{"HTTPS://DASH.EXAMPLE.ORG", true},
+		{"https://a.example.com", true},
+		{"https://a.b.example.com", true},
+		{"https://example.com", false},
+		{"https://evil-example.com", false},
+		{"https://example.com.evil.net", false},
+		{"http://a.example.com", false},
+		{"https://dash.example.org.evil.net", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.originAllowed(tt.origin); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     CORSConfig
+		wantErr bool
+	}{
+		{"any origin", CORSConfig{AllowedOrigins: []string{"*"}}, false},
+		{"credentials with listed origin", CORSConfig{AllowedOrigins: []string{"https://dash.example.org"}, AllowCredentials: true}, false},
+		{"credentials with any origin", CORSConfig{AllowedOrigins: []string{"https://dash.example.org", "*"}, AllowCredentials: true}, true},
+		{"no origins", CORSConfig{}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error: %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"https://dash.example.org", "https://*.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"Retry-After"},
+		AllowCredentials: true,
+		MaxAgeSeconds:    600,
+	}
+	var reached bool
+	handler := corsMiddleware(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	tests := []struct {
+		name          string
+		method        string
+		origin        string
+		requestMethod string // Access-Control-Request-Method of a preflight
+		status        int
+		reached       bool
+		headers       map[string]string
+	}{
+		{
+			name: "exact origin", method: "GET", origin: "https://dash.example.org",
+			status: http.StatusOK, reached: true,
+			headers: map[string]string{
+				"Access-Control-Allow-Origin":      "https://dash.example.org",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "Retry-After",
+			},
+		},
+		{
+			name: "wildcard subdomain", method: "POST", origin: "https://team.example.com",
+			status: http.StatusOK, reached: true,
+			headers: map[string]string{"Access-Control-Allow-Origin": "https://team.example.com"},
+		},
+		{
+			name: "lookalike domain", method: "GET", origin: "https://evil-example.com",
+			status:  http.StatusForbidden,
+			headers: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name: "disallowed origin", method: "GET", origin: "https://evil.net",
+			status: http.StatusForbidden,
+		},
+		{
+			name: "preflight", method: "OPTIONS", origin: "https://dash.example.org", requestMethod: "POST",
+			status: http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Origin":  "https://dash.example.org",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Content-Type, Authorization",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name: "preflight for disallowed method", method: "OPTIONS", origin: "https://dash.example.org", requestMethod: "DELETE",
+			status: http.StatusForbidden,
+		},
+		{
+			name: "no origin", method: "GET",
+			status: http.StatusOK, reached: true,
+			headers: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	}
+	for _, tt := range tests {
+		reached = false
+		req := httptest.NewRequest(tt.method, "/status", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if reached != tt.reached {
+			t.Errorf("%s: handler reached = %v, want %v", tt.name, reached, tt.reached)
+		}
+		for name, want := range tt.headers {
+			if got := rec.Header().Get(name); got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, want)
+			}
+		}
+	}
+}
+
+func TestCORSMiddlewareAnyOrigin(t *testing.T) {
+	cfg := CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}
+	handler := corsMiddleware(cfg, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/status", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q with any origin", got)
+	}
+}
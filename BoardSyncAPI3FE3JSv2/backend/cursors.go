@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Change directions a cursor can track: which tracker's edits are read.
+const (
+	directionFromAsana    = "asana"
+	directionFromYouTrack = "youtrack"
+)
+
+// SyncCursor is the high-water mark of one background run for one project
+// and direction: everything changed before Since has been handled.
+type SyncCursor struct {
+	Key        string    `json:"key"`
+	Since      time.Time `json:"since"`
+	LastFullAt time.Time `json:"last_full_at"`
+}
+
+// CursorStore persists sync cursors in a JSON file. A run takes a window,
+// processes only tickets changed inside it, and commits the window only if
+// it succeeded, so failed tickets are picked up again by the next run.
+// Every fullEvery a window covers everything, which reconciles changes the
+// timestamps missed (clock skew, unignored tickets, moves between projects).
+type CursorStore struct {
+	file      string
+	fullEvery time.Duration
+
+	mu      sync.Mutex
+	cursors map[string]*SyncCursor
+}
+
+// SyncWindow is what one run should look at
+type SyncWindow struct {
+	Full          bool
+	AsanaSince    time.Time
+	YouTrackSince time.Time
+
+	startedAt   time.Time
+	asanaKey    string
+	youTrackKey string
+}
+
+func NewCursorStore(file string, fullEvery time.Duration) *CursorStore {
+	cs := &CursorStore{
+		file:      file,
+		fullEvery: fullEvery,
+		cursors:   make(map[string]*SyncCursor),
+	}
+	cs.load()
+	return cs
+}
+
+func cursorKey(runner, direction, project string) string {
+	return runner + "/" + direction + "/" + project
+}
+
+// Window opens a run for runner over the given project pair. A nil store
+// always returns a full window.
+func (cs *CursorStore) Window(runner, asanaProject, youTrackProject string) SyncWindow {
+	w := SyncWindow{
+		Full:        true,
+		startedAt:   time.Now(),
+		asanaKey:    cursorKey(runner, directionFromAsana, asanaProject),
+		youTrackKey: cursorKey(runner, directionFromYouTrack, youTrackProject),
+	}
+	if cs == nil {
+		return w
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	asana, youTrack := cs.cursors[w.asanaKey], cs.cursors[w.youTrackKey]
+	if asana == nil || youTrack == nil || asana.Since.IsZero() || youTrack.Since.IsZero() {
+		return w
+	}
+	if w.startedAt.Sub(asana.LastFullAt) >= cs.fullEvery || w.startedAt.Sub(youTrack.LastFullAt) >= cs.fullEvery {
+		return w
+	}
+
+	w.Full = false
+	w.AsanaSince = asana.Since
+	w.YouTrackSince = youTrack.Since
+	return w
+}
+
+// Commit advances both cursors of a successful run to when it started.
+// Both are written in one atomic file replace.
+func (cs *CursorStore) Commit(w SyncWindow) error {
+	if cs == nil {
+		return nil
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	previous := make(map[string]SyncCursor)
+	for _, key := range []string{w.asanaKey, w.youTrackKey} {
+		c, ok := cs.cursors[key]
+		if !ok {
+			c = &SyncCursor{Key: key}
+			cs.cursors[key] = c
+		}
+		previous[key] = *c
+		c.Since = w.startedAt
+		if w.Full {
+			c.LastFullAt = w.startedAt
+		}
+	}
+
+	if err := cs.saveLocked(); err != nil {
+		for key, c := range previous {
+			*cs.cursors[key] = c
+		}
+		return err
+	}
+	return nil
+}
+
+// RequestFull makes the next window of every runner a full reconciliation.
+// The request holds in memory even when it cannot be saved; the error means
+// it will not survive a restart.
+func (cs *CursorStore) RequestFull() error {
+	if cs == nil {
+		return nil
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, c := range cs.cursors {
+		c.LastFullAt = time.Time{}
+	}
+	return cs.saveLocked()
+}
+
+func (cs *CursorStore) List() []SyncCursor {
+	if cs == nil {
+		return []SyncCursor{}
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cursors := make([]SyncCursor, 0, len(cs.cursors))
+	for _, c := range cs.cursors {
+		cursors = append(cursors, *c)
+	}
+	sort.Slice(cursors, func(i, j int) bool { return cursors[i].Key < cursors[j].Key })
+	return cursors
+}
+
+// AsanaChanged reports whether the task was modified inside the window.
+// Unparseable timestamps count as changed.
+func (w SyncWindow) AsanaChanged(task AsanaTask) bool {
+	if w.Full {
+		return true
+	}
+	modified, err := time.Parse(time.RFC3339, task.ModifiedAt)
+	return err != nil || modified.After(w.AsanaSince.Add(-cacheClockSkew))
+}
+
+// YouTrackChanged reports whether the issue was updated inside the window.
+func (w SyncWindow) YouTrackChanged(issue YouTrackIssue) bool {
+	if w.Full {
+		return true
+	}
+	return issue.Updated == 0 || time.UnixMilli(issue.Updated).After(w.YouTrackSince.Add(-cacheClockSkew))
+}
+
+func (w SyncWindow) String() string {
+	if w.Full {
+		return "full reconciliation"
+	}
+	return fmt.Sprintf("changes since %s", w.AsanaSince.Format(time.RFC3339))
+}
+
+func (cs *CursorStore) load() {
+	data, err := os.ReadFile(cs.file)
+	if err != nil {
+		return
+	}
+
+	var cursors []*SyncCursor
+	if err := json.Unmarshal(data, &cursors); err != nil {
+		slog.Error("Could not read sync cursors", "file", cs.file, "error", err)
+		return
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, c := range cursors {
+		cs.cursors[c.Key] = c
+	}
+}
+
+func (cs *CursorStore) saveLocked() error {
+	cursors := make([]*SyncCursor, 0, len(cs.cursors))
+	for _, c := range cs.cursors {
+		cursors = append(cursors, c)
+	}
+	sort.Slice(cursors, func(i, j int) bool { return cursors[i].Key < cursors[j].Key })
+
+	data, err := json.MarshalIndent(cursors, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := cs.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cs.file)
+}
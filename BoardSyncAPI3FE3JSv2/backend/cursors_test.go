@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCursorStoreWindowAdvancesOnlyOnCommit(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cursors.json")
+	cs := NewCursorStore(file, time.Hour)
+
+	first := cs.Window("auto-sync", "A1", "YT")
+	if !first.Full {
+		t.Fatalf("first window = %s, want a full reconciliation", first)
+	}
+	if err := cs.Commit(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed run does not commit, so the next one starts from the same point
+	failed := cs.Window("auto-sync", "A1", "YT")
+	if failed.Full || !failed.AsanaSince.Equal(first.startedAt) || !failed.YouTrackSince.Equal(first.startedAt) {
+		t.Fatalf("window after commit = %+v, want changes since %s", failed, first.startedAt)
+	}
+	if retry := cs.Window("auto-sync", "A1", "YT"); !retry.AsanaSince.Equal(first.startedAt) {
+		t.Fatalf("uncommitted window moved the cursor to %s", retry.AsanaSince)
+	}
+
+	// Runners and projects keep their own cursors
+	if other := cs.Window("auto-create", "A1", "YT"); !other.Full {
+		t.Fatalf("auto-create window = %s, want full", other)
+	}
+
+	// Cursors survive a restart
+	reloaded := NewCursorStore(file, time.Hour)
+	if w := reloaded.Window("auto-sync", "A1", "YT"); w.Full || !w.AsanaSince.Equal(first.startedAt) {
+		t.Fatalf("window after reload = %+v, want changes since %s", w, first.startedAt)
+	}
+
+	if err := reloaded.RequestFull(); err != nil {
+		t.Fatal(err)
+	}
+	if w := reloaded.Window("auto-sync", "A1", "YT"); !w.Full {
+		t.Fatalf("window after RequestFull = %s, want full", w)
+	}
+}
+
+func TestCursorStoreFullEvery(t *testing.T) {
+	cs := NewCursorStore(filepath.Join(t.TempDir(), "cursors.json"), 0)
+	if err := cs.Commit(cs.Window("auto-sync", "A1", "YT")); err != nil {
+		t.Fatal(err)
+	}
+	if w := cs.Window("auto-sync", "A1", "YT"); !w.Full {
+		t.Fatalf("window with full_reconcile 0 = %s, want full", w)
+	}
+}
+
+func TestCursorStoreCommitFailureKeepsCursors(t *testing.T) {
+	cs := NewCursorStore(filepath.Join(t.TempDir(), "missing", "cursors.json"), time.Hour)
+	if err := cs.Commit(cs.Window("auto-sync", "A1", "YT")); err == nil {
+		t.Fatal("commit into a missing directory succeeded")
+	}
+	if w := cs.Window("auto-sync", "A1", "YT"); !w.Full {
+		t.Fatalf("window after failed commit = %s, want full", w)
+	}
+}
+
+func TestCursorStoreRequestFullReportsSaveFailure(t *testing.T) {
+	dir := t.TempDir()
+	cs := NewCursorStore(filepath.Join(dir, "cursors.json"), time.Hour)
+	if err := cs.Commit(cs.Window("auto-sync", "A1", "YT")); err != nil {
+		t.Fatal(err)
+	}
+
+	cs.file = filepath.Join(dir, "missing", "cursors.json")
+	if err := cs.RequestFull(); err == nil {
+		t.Fatal("RequestFull into a missing directory succeeded")
+	}
+	// The request still applies until the process restarts
+	if w := cs.Window("auto-sync", "A1", "YT"); !w.Full {
+		t.Fatalf("window after unsaved RequestFull = %s, want full", w)
+	}
+}
+
+func TestSyncWindowChanged(t *testing.T) {
+	since := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := SyncWindow{AsanaSince: since, YouTrackSince: since}
+
+	tests := []struct {
+		name    string
+		task    AsanaTask
+		issue   YouTrackIssue
+		changed bool
+	}{
+		{"after", AsanaTask{ModifiedAt: since.Add(time.Minute).Format(time.RFC3339)}, YouTrackIssue{Updated: since.Add(time.Minute).UnixMilli()}, true},
+		{"inside clock skew", AsanaTask{ModifiedAt: since.Add(-cacheClockSkew / 2).Format(time.RFC3339)}, YouTrackIssue{Updated: since.Add(-cacheClockSkew / 2).UnixMilli()}, true},
+		{"before", AsanaTask{ModifiedAt: since.Add(-time.Hour).Format(time.RFC3339)}, YouTrackIssue{Updated: since.Add(-time.Hour).UnixMilli()}, false},
+		{"unknown", AsanaTask{ModifiedAt: "yesterday"}, YouTrackIssue{}, true},
+	}
+	for _, tt := range tests {
+		if got := w.AsanaChanged(tt.task); got != tt.changed {
+			t.Errorf("%s: AsanaChanged = %v, want %v", tt.name, got, tt.changed)
+		}
+		if got := w.YouTrackChanged(tt.issue); got != tt.changed {
+			t.Errorf("%s: YouTrackChanged = %v, want %v", tt.name, got, tt.changed)
+		}
+	}
+}
+
+func TestCursorStoreConcurrentRuns(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cursors.json")
+	cs := NewCursorStore(file, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			project := fmt.Sprintf("P%d", i%4)
+			for j := 0; j < 10; j++ {
+				if err := cs.Commit(cs.Window("auto-sync", "A"+project, "Y"+project)); err != nil {
+					t.Error(err)
+					return
+				}
+				cs.List()
+				if j == 5 {
+					cs.RequestFull()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(cs.List()); n != 8 {
+		t.Fatalf("%d cursors, want 2 per project", n)
+	}
+	if n := len(NewCursorStore(file, time.Hour).List()); n != 8 {
+		t.Fatalf("%d cursors on disk, want 8", n)
+	}
+}
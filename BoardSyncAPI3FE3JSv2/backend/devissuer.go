@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const devIssuerKeyID = "dev-1"
+
+// DevIssuer is a minimal OIDC issuer for local development and tests. It
+// signs tokens for whatever subject and roles are asked for, so it must
+// never be enabled in production (OIDC_DEV_ISSUER=true turns it on).
+//
+// Endpoints, mounted under /dev-oidc:
+//
+//	GET  /.well-known/openid-configuration
+//	GET  /jwks
+//	POST /token  {"sub":"alice","email":"alice@example.com","roles":["admin"],"tenant":"acme","ttl_seconds":3600}
+//
+// tenant is required when the verifier binds tokens to tenants and goes
+// into its tenant claim.
+type DevIssuer struct {
+	issuer      string
+	audience    string
+	key         *rsa.PrivateKey
+	tenantClaim string // set by Attach from the verifier's config
+}
+
+// devIssuerPath is where the dev issuer is mounted
+const devIssuerPath = "/dev-oidc"
+
+// NewDevIssuer generates a fresh signing key; tokens do not survive a restart.
+// The issuer must be this service's own /dev-oidc URL on a loopback host, so
+// the dev issuer can never stand in for a real identity provider.
+func NewDevIssuer(issuer, audience string) (*DevIssuer, error) {
+	if !isLocalDevIssuer(issuer) {
+		return nil, fmt.Errorf("the dev issuer only runs as http://localhost:<port>%s, not %q; unset OIDC_ISSUER or OIDC_DEV_ISSUER", devIssuerPath, issuer)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &DevIssuer{issuer: normalizeIssuer(issuer), audience: audience, key: key}, nil
+}
+
+func isLocalDevIssuer(issuer string) bool {
+	u, err := url.Parse(normalizeIssuer(issuer))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Path != devIssuerPath {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return u.User == nil && u.RawQuery == "" && u.Fragment == ""
+	}
+	return false
+}
+
+// Attach makes the verifier trust this issuer's key without an HTTP round
+// trip, and has /token put tenants where the verifier looks for them. It
+// refuses a verifier configured for any other issuer.
+func (d *DevIssuer) Attach(v *OIDCVerifier) error {
+	if normalizeIssuer(v.cfg.Issuer) != d.issuer {
+		return fmt.Errorf("the verifier trusts %q, not the dev issuer %q", v.cfg.Issuer, d.issuer)
+	}
+	d.tenantClaim = v.cfg.TenantClaim
+	v.fetchKeys = func(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+		return map[string]*rsa.PublicKey{devIssuerKeyID: &d.key.PublicKey}, nil
+	}
+	return nil
+}
+
+// Sign issues an RS256 token with the given extra claims.
+func (d *DevIssuer) Sign(claims map[string]interface{}, ttl time.Duration) (string, error) {
+	now := time.Now()
+	all := map[string]interface{}{
+		"iss": d.issuer,
+		"aud": d.audience,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": devIssuerKeyID})
+	payload, err := json.Marshal(all)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, d.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Dev issuer handler
+func (d *DevIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch strings.TrimPrefix(r.URL.Path, devIssuerPath) {
+	case "/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                d.issuer,
+			"jwks_uri":                              d.issuer + "/jwks",
+			"token_endpoint":                        d.issuer + "/token",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+
+	case "/jwks":
+		json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{rsaJWK(devIssuerKeyID, &d.key.PublicKey)}})
+
+	case "/token":
+		if r.Method != "POST" {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use POST.")
+			return
+		}
+
+		var req struct {
+			Sub        string   `json:"sub"`
+			Email      string   `json:"email"`
+			Name       string   `json:"name"`
+			Roles      []string `json:"roles"`
+			Tenant     string   `json:"tenant"`
+			TTLSeconds int      `json:"ttl_seconds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Sub == "" {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", `Body must be an object like {"sub":"alice","email":"alice@example.com","roles":["operator"]}`)
+			return
+		}
+		switch {
+		case d.tenantClaim != "" && req.Tenant == "":
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("tenant is required: tokens must carry the %q claim", d.tenantClaim))
+			return
+		case d.tenantClaim == "" && req.Tenant != "":
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "tenant is not accepted: OIDC_TENANT_CLAIM is not set")
+			return
+		}
+		ttl := time.Hour
+		if req.TTLSeconds > 0 {
+			ttl = time.Duration(req.TTLSeconds) * time.Second
+		}
+
+		claims := map[string]interface{}{"sub": req.Sub, "roles": req.Roles}
+		if req.Email != "" {
+			claims["email"] = req.Email
+		}
+		if req.Name != "" {
+			claims["name"] = req.Name
+		}
+		if req.Tenant != "" {
+			claims[d.tenantClaim] = req.Tenant
+		}
+
+		token, err := d.Sign(claims, ttl)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", fmt.Sprintf("Failed to sign token: %v", err))
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Dev issuer minted a token", "sub", req.Sub, "roles", req.Roles, "tenant", req.Tenant)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": token,
+			"id_token":     token,
+			"token_type":   "Bearer",
+			"expires_in":   int(ttl.Seconds()),
+		})
+
+	default:
+		writeJSONError(w, http.StatusNotFound, "not_found", "Unknown dev issuer endpoint.")
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Diagnostics check that a tenant's tracker credentials, projects and YouTrack
+// fields match what the sync needs. They never change anything; each problem
+// comes with what to change. The same checks run at startup, where they only
+// log.
+
+// Finding statuses, from best to worst
+const (
+	findingOK      = "ok"
+	findingWarning = "warning"
+	findingError   = "error"
+)
+
+// DiagnosticFinding is the result of one check
+type DiagnosticFinding struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+// PairDiagnostics holds the checks of one project pair
+type PairDiagnostics struct {
+	Pair     string              `json:"pair"`
+	Status   string              `json:"status"`
+	Findings []DiagnosticFinding `json:"findings"`
+}
+
+// TenantDiagnostics holds the token checks of a tenant and the checks of
+// its pairs. Status is the worst status of any finding.
+type TenantDiagnostics struct {
+	Tenant    string              `json:"tenant"`
+	Status    string              `json:"status"`
+	CheckedAt time.Time           `json:"checked_at"`
+	Findings  []DiagnosticFinding `json:"findings"`
+	Pairs     []PairDiagnostics   `json:"pairs"`
+}
+
+type findings []DiagnosticFinding
+
+func (f *findings) ok(check, format string, args ...interface{}) {
+	*f = append(*f, DiagnosticFinding{Check: check, Status: findingOK, Message: fmt.Sprintf(format, args...)})
+}
+
+func (f *findings) warn(check, fix, format string, args ...interface{}) {
+	*f = append(*f, DiagnosticFinding{Check: check, Status: findingWarning, Message: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+func (f *findings) fail(check, fix, format string, args ...interface{}) {
+	*f = append(*f, DiagnosticFinding{Check: check, Status: findingError, Message: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+func (f findings) status() string {
+	status := findingOK
+	for _, finding := range f {
+		if finding.Status == findingError {
+			return findingError
+		}
+		if finding.Status == findingWarning {
+			status = findingWarning
+		}
+	}
+	return status
+}
+
+func worstStatus(statuses ...string) string {
+	var fs findings
+	for _, status := range statuses {
+		fs = append(fs, DiagnosticFinding{Status: status})
+	}
+	return fs.status()
+}
+
+// diagnoseTenant runs every check for t, limited to the named pairs (all
+// pairs when none are given).
+func diagnoseTenant(ctx context.Context, t *Tenant, pairs []*SyncEngine) TenantDiagnostics {
+	ctx = withTenant(ctx, t)
+	report := TenantDiagnostics{Tenant: t.ID(), CheckedAt: time.Now().UTC(), Pairs: []PairDiagnostics{}}
+
+	var fs findings
+	asanaOK := checkAsanaToken(ctx, &fs, t.ID())
+	youTrackOK := checkYouTrackToken(ctx, &fs, t.ID())
+	report.Findings = fs
+
+	statuses := []string{fs.status()}
+	for _, engine := range pairs {
+		pair := diagnosePair(withPair(ctx, engine), t.ID(), asanaOK, youTrackOK)
+		statuses = append(statuses, pair.Status)
+		report.Pairs = append(report.Pairs, pair)
+	}
+	report.Status = worstStatus(statuses...)
+	return report
+}
+
+func rotateFix(tenantID, secret string) string {
+	return fmt.Sprintf(`Set a valid token with POST /admin/secrets?tenant=%s {"name":"%s","value":"..."}`, tenantID, secret)
+}
+
+func checkAsanaToken(ctx context.Context, fs *findings, tenantID string) bool {
+	me, err := asanaFrom(ctx).Me(ctx)
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		fs.fail("asana_token", rotateFix(tenantID, "asana_pat"), "Asana rejected the personal access token: %v", err)
+		return false
+	case err != nil:
+		fs.fail("asana_token", "Check network access to app.asana.com and retry.", "Could not reach Asana: %v", err)
+		return false
+	}
+	fs.ok("asana_token", "Authenticated to Asana as %s", me.Name)
+	return true
+}
+
+func checkYouTrackToken(ctx context.Context, fs *findings, tenantID string) bool {
+	client := youTrackFrom(ctx)
+	me, err := client.Me(ctx)
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		fs.fail("youtrack_token", rotateFix(tenantID, "youtrack_token"), "YouTrack at %s rejected the token: %v", client.baseURL, err)
+		return false
+	case errors.Is(err, ErrNotFound):
+		fs.fail("youtrack_token", fmt.Sprintf("Set youtrack_base_url to your instance root (e.g. https://example.youtrack.cloud) with PUT /admin/tenants/%s", tenantID),
+			"%s does not look like a YouTrack instance: %v", client.baseURL, err)
+		return false
+	case err != nil:
+		fs.fail("youtrack_token", "Check youtrack_base_url and network access to it, then retry.", "Could not reach YouTrack at %s: %v", client.baseURL, err)
+		return false
+	}
+	fs.ok("youtrack_token", "Authenticated to YouTrack at %s as %s", client.baseURL, me.Login)
+	return true
+}
+
+// diagnosePair checks the pair in ctx. Checks that need a tracker whose
+// token failed are skipped; the token finding already says why.
+func diagnosePair(ctx context.Context, tenantID string, asanaOK, youTrackOK bool) PairDiagnostics {
+	pair := pairFrom(ctx)
+	var fs findings
+	if asanaOK {
+		checkAsanaProject(ctx, &fs, tenantID, pair)
+	}
+	if youTrackOK {
+		if project, ok := checkYouTrackProject(ctx, &fs, tenantID, pair); ok {
+			checkYouTrackFields(ctx, &fs, pair, project)
+		}
+	}
+	return PairDiagnostics{Pair: pair.Name, Status: fs.status(), Findings: fs}
+}
+
+func checkAsanaProject(ctx context.Context, fs *findings, tenantID string, pair ProjectPair) {
+	project, err := asanaFrom(ctx).Project(ctx, pair.AsanaProjectID)
+	if err != nil {
+		fix := fmt.Sprintf("Set asana_project_id of pair %q to a project the token's user can see with PUT /admin/tenants/%s", pair.Name, tenantID)
+		if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUnauthorized) {
+			fix = "Retry; if it persists check Asana's status page."
+		}
+		fs.fail("asana_project", fix, "Asana project %s is not accessible: %v", pair.AsanaProjectID, err)
+		return
+	}
+	fs.ok("asana_project", "Asana project %s (%s) is accessible", project.Name, project.GID)
+
+	sections, err := asanaFrom(ctx).ProjectSections(ctx, pair.AsanaProjectID)
+	if err != nil {
+		fs.warn("asana_sections", "Retry; column checks are skipped until sections can be listed.", "Could not list sections of Asana project %s: %v", pair.AsanaProjectID, err)
+		return
+	}
+	var names, unmatched []string
+	for _, section := range sections {
+		names = append(names, section.Name)
+	}
+	for _, col := range currentConfig().Columns.Syncable {
+		matched := false
+		for _, name := range names {
+			if strings.Contains(strings.ToLower(name), col) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, col)
+		}
+	}
+	if len(unmatched) > 0 {
+		fs.warn("asana_sections", "Rename the Asana sections, or change columns.syncable and columns.states in config.yaml.",
+			"No Asana section matches column(s) %s; sections are: %s", quoteList(unmatched), strings.Join(names, ", "))
+		return
+	}
+	fs.ok("asana_sections", "Every syncable column has an Asana section")
+}
+
+// checkYouTrackProject finds the pair's project, accepting its short name or
+// database ID.
+func checkYouTrackProject(ctx context.Context, fs *findings, tenantID string, pair ProjectPair) (YouTrackProject, bool) {
+	client := youTrackFrom(ctx)
+	projects, err := client.AdminProjects(ctx, 500)
+	if errors.Is(err, ErrUnauthorized) {
+		projects, err = client.Projects(ctx)
+	}
+	if err != nil {
+		fs.fail("youtrack_project", "Give the token's user read access to the project, then retry.", "Could not list YouTrack projects: %v", err)
+		return YouTrackProject{}, false
+	}
+
+	fix := fmt.Sprintf("Set youtrack_project_id of pair %q with PUT /admin/tenants/%s", pair.Name, tenantID)
+	for _, project := range projects {
+		if project.ShortName == pair.YouTrackProjectID {
+			fs.ok("youtrack_project", "YouTrack project %s (%s) is accessible", project.Name, project.ShortName)
+			return project, true
+		}
+		if project.ID == pair.YouTrackProjectID {
+			fs.warn("youtrack_project", fix+fmt.Sprintf(" to the project key %q", project.ShortName),
+				"youtrack_project_id %s is the database ID of %s; issue queries need its key %s", project.ID, project.Name, project.ShortName)
+			return project, true
+		}
+	}
+
+	keys := make([]string, 0, len(projects))
+	for _, project := range projects {
+		keys = append(keys, project.ShortName)
+	}
+	if len(keys) > 0 {
+		fix += " to one of: " + strings.Join(keys, ", ")
+	} else {
+		fix = "The token's user sees no YouTrack projects; grant it access to the project."
+	}
+	fs.fail("youtrack_project", fix, "YouTrack project %s was not found", pair.YouTrackProjectID)
+	return YouTrackProject{}, false
+}
+
+// requiredStates are the State values the sync writes: every active mapped
+// state, the default state and the resolved state when deletes resolve.
+func requiredStates() []string {
+	cfg := currentConfig()
+	states := cfg.Columns.activeStates()
+	if d := cfg.Columns.DefaultState; !strings.HasSuffix(d, noSyncStateSuffix) && !containsString(states, d) {
+		states = append(states, d)
+	}
+	if cfg.DeleteStrategy.YouTrack == strategyResolve && !containsString(states, cfg.YouTrackResolvedState) {
+		states = append(states, cfg.YouTrackResolvedState)
+	}
+	return states
+}
+
+func checkYouTrackFields(ctx context.Context, fs *findings, pair ProjectPair, project YouTrackProject) {
+	fields, err := youTrackFrom(ctx).ProjectCustomFields(ctx, project.ID)
+	if err != nil {
+		fix := "Retry; field checks are skipped until the project's fields can be read."
+		if errors.Is(err, ErrUnauthorized) {
+			fix = "Give the token's user permission to read project settings (Project Admin or Read Project)."
+		}
+		fs.warn("youtrack_fields", fix, "Could not read custom fields of %s: %v", project.ShortName, err)
+		return
+	}
+
+	byName := make(map[string]YouTrackProjectField, len(fields))
+	for _, f := range fields {
+		byName[f.Field.Name] = f
+	}
+	projectSettings := fmt.Sprintf("YouTrack project %s settings, Fields tab", project.ShortName)
+
+	state, ok := byName["State"]
+	if !ok {
+		fs.fail("youtrack_field_state", "Attach the State field in the "+projectSettings+".",
+			"Project %s has no State field; status sync cannot work", project.ShortName)
+	} else if _, _, err := kindOf(state); err != nil {
+		fs.fail("youtrack_field_state", "Make State an enum or state field in the "+projectSettings+".", "%v", err)
+	} else if missing := missingBundleValues(state, requiredStates()); len(missing) > 0 {
+		fs.fail("youtrack_field_state", "Add the value(s) to the State field in the "+projectSettings+", or change columns.states in config.yaml.",
+			"State has no value(s) %s used by the column mapping", quoteList(missing))
+	} else {
+		fs.ok("youtrack_field_state", "State has every mapped value")
+	}
+
+	var subsystems []string
+	for _, subsystem := range pair.tagMapping() {
+		if !containsString(subsystems, subsystem) {
+			subsystems = append(subsystems, subsystem)
+		}
+	}
+	sort.Strings(subsystems)
+
+	subsystem, ok := byName["Subsystem"]
+	if !ok {
+		fs.warn("youtrack_field_subsystem", "Attach a Subsystem field in the "+projectSettings+" to sync Asana tags.",
+			"Project %s has no Subsystem field; tickets sync without it", project.ShortName)
+	} else if missing := missingBundleValues(subsystem, subsystems); len(missing) > 0 {
+		fs.warn("youtrack_field_subsystem", "Add the value(s) to the Subsystem field in the "+projectSettings+", or change tag_mapping.",
+			"Subsystem has no value(s) %s used by the tag mapping; tickets with those tags sync without a Subsystem", quoteList(missing))
+	} else {
+		fs.ok("youtrack_field_subsystem", "Subsystem has every mapped value")
+	}
+}
+
+// missingBundleValues returns the wanted values the field's bundle lacks or
+// has archived. Fields without a bundle accept any value.
+func missingBundleValues(field YouTrackProjectField, wanted []string) []string {
+	if field.Bundle == nil {
+		return nil
+	}
+	var missing []string
+	for _, want := range wanted {
+		found := false
+		for _, v := range field.Bundle.Values {
+			if strings.EqualFold(v.Name, want) && !v.Archived {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, want)
+		}
+	}
+	return missing
+}
+
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// logStartupDiagnostics checks every tenant and logs the problems. It never
+// stops the service: a tenant with a wrong project or token keeps running,
+// and /diagnostics shows the same report.
+func logStartupDiagnostics(tenants *TenantRegistry) {
+	for _, t := range tenants.List() {
+		ctx, cancel := context.WithTimeout(withTenant(context.Background(), t), 2*time.Minute)
+		report := diagnoseTenant(ctx, t, t.Pairs().All())
+		cancel()
+
+		logFindings := func(pair string, fs []DiagnosticFinding) {
+			for _, f := range fs {
+				level := slog.LevelWarn
+				switch f.Status {
+				case findingOK:
+					continue
+				case findingError:
+					level = slog.LevelError
+				}
+				args := []any{"check", f.Check, "fix", f.Fix}
+				if pair != "" {
+					args = append(args, "pair", pair)
+				}
+				slog.Log(ctx, level, "Diagnostics: "+f.Message, args...)
+			}
+		}
+		logFindings("", report.Findings)
+		for _, pair := range report.Pairs {
+			logFindings(pair.Pair, pair.Findings)
+		}
+		slog.InfoContext(ctx, "Diagnostics complete (details at GET /diagnostics)", "status", report.Status)
+	}
+}
+
+// Diagnostics handler: GET /diagnostics runs every check for the tenant, or
+// only for ?pair=<name>.
+func (s *Server) diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use GET.")
+		return
+	}
+
+	t := tenantFrom(r.Context())
+	pairs := t.Pairs().All()
+	if name := r.URL.Query().Get("pair"); name != "" {
+		engine, ok := t.Pairs().Get(name)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "unknown_pair",
+				fmt.Sprintf("Unknown project pair %q. Known pairs: %s.", name, strings.Join(t.Pairs().Names(), ", ")))
+			return
+		}
+		pairs = []*SyncEngine{engine}
+	}
+
+	report := diagnoseTenant(r.Context(), t, pairs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SyncEngine owns every piece of runtime state that handlers and the
+// background loops mutate. All access goes through its methods so that
+// concurrent /ignore calls and ticker goroutines never race on the maps.
+type SyncEngine struct {
+	pair    ProjectPair
+	tenant  *Tenant
+	runs    *RunCoordinator
+	cursors *CursorStore
+	cache   *TrackerCache
+
+	mu             sync.RWMutex
+	ignoredTemp    map[string]bool
+	ignoredForever map[string]bool
+	ignoreFile     string
+	lastSyncTime   time.Time
+	proposals      map[string]BundleProposal // missing YouTrack values awaiting approval
+
+	autoSync   *autoRunner
+	autoCreate *autoRunner
+}
+
+// NewSyncEngine builds the engine of one project pair. runs may be shared
+// between engines; runs on the same YouTrack project are serialized.
+func NewSyncEngine(pair ProjectPair, ignoreFile string, cursors *CursorStore, runs *RunCoordinator) *SyncEngine {
+	e := &SyncEngine{
+		pair:           pair,
+		runs:           runs,
+		cursors:        cursors,
+		ignoredTemp:    make(map[string]bool),
+		ignoredForever: make(map[string]bool),
+		ignoreFile:     ignoreFile,
+	}
+	e.autoSync = newAutoRunner("Auto-sync ["+pair.Name+"]", triggerAutoSync, e, e.performAutoSync)
+	e.autoCreate = newAutoRunner("Auto-create ["+pair.Name+"]", triggerAutoCreate, e, e.performAutoCreate)
+	e.loadIgnoredTickets()
+	return e
+}
+
+// Pair returns the project pair the engine works on.
+func (e *SyncEngine) Pair() ProjectPair {
+	return e.pair
+}
+
+// Ignore list access
+
+func (e *SyncEngine) IsIgnored(ticketID string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.ignoredTemp[ticketID] || e.ignoredForever[ticketID]
+}
+
+func (e *SyncEngine) Ignore(ticketID string, forever bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if forever {
+		e.ignoredForever[ticketID] = true
+		e.saveIgnoredTicketsLocked()
+	} else {
+		e.ignoredTemp[ticketID] = true
+	}
+}
+
+// Unignore also makes the next auto runs reconcile fully, since the ticket
+// may not have changed since the cursors last advanced.
+func (e *SyncEngine) Unignore(ticketID string, forever bool) {
+	e.mu.Lock()
+	if forever {
+		delete(e.ignoredForever, ticketID)
+		e.saveIgnoredTicketsLocked()
+	} else {
+		delete(e.ignoredTemp, ticketID)
+	}
+	e.mu.Unlock()
+
+	if err := e.cursors.RequestFull(); err != nil {
+		slog.Error("Unignore could not save the full reconciliation request", "ticket_id", ticketID, "error", err)
+	}
+}
+
+func (e *SyncEngine) IgnoredTemp() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return getMapKeys(e.ignoredTemp)
+}
+
+func (e *SyncEngine) IgnoredForever() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return getMapKeys(e.ignoredForever)
+}
+
+func (e *SyncEngine) LastSyncTime() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lastSyncTime
+}
+
+// MarkSynced records a completed sync run.
+func (e *SyncEngine) MarkSynced() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastSyncTime = time.Now()
+}
+
+func (e *SyncEngine) loadIgnoredTickets() {
+	data, err := os.ReadFile(e.ignoreFile)
+	if err != nil {
+		return
+	}
+
+	var ignored []string
+	if err := json.Unmarshal(data, &ignored); err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, id := range ignored {
+		e.ignoredForever[id] = true
+	}
+}
+
+// saveIgnoredTicketsLocked must be called with e.mu held for writing.
+func (e *SyncEngine) saveIgnoredTicketsLocked() {
+	ignored := getMapKeys(e.ignoredForever)
+	data, _ := json.MarshalIndent(ignored, "", "  ")
+	os.WriteFile(e.ignoreFile, data, 0644)
+}
+
+// Run coordination
+
+// RunExclusive executes fn once no other mutating run is active for the
+// engine's project, queueing behind any run in progress. It returns ctx.Err()
+// without running fn if ctx ends while queued.
+func (e *SyncEngine) RunExclusive(ctx context.Context, trigger string, fn func()) error {
+	return e.runs.Run(ctx, e.pair.YouTrackProjectID, trigger, fn)
+}
+
+// TryRunExclusive executes fn only if the project is idle.
+func (e *SyncEngine) TryRunExclusive(trigger string, fn func()) bool {
+	return e.runs.TryRun(e.pair.YouTrackProjectID, trigger, fn)
+}
+
+func (e *SyncEngine) RunStatus() RunSlotStatus {
+	return e.runs.Status(e.pair.YouTrackProjectID)
+}
+
+// Auto-sync / auto-create control
+
+func (e *SyncEngine) StartAutoSync(interval int) bool   { return e.autoSync.Start(interval) }
+func (e *SyncEngine) StopAutoSync(reason string) bool   { return e.autoSync.Stop(reason) }
+func (e *SyncEngine) AutoSyncState() autoRunnerState    { return e.autoSync.State() }
+func (e *SyncEngine) StartAutoCreate(interval int) bool { return e.autoCreate.Start(interval) }
+func (e *SyncEngine) StopAutoCreate(reason string) bool { return e.autoCreate.Stop(reason) }
+func (e *SyncEngine) AutoCreateState() autoRunnerState  { return e.autoCreate.State() }
+
+// StartSchedules starts the auto runs configured for the engine's pair.
+func (e *SyncEngine) StartSchedules() {
+	if e.pair.AutoSyncInterval > 0 {
+		e.StartAutoSync(e.pair.AutoSyncInterval)
+	}
+	if e.pair.AutoCreateInterval > 0 {
+		e.StartAutoCreate(e.pair.AutoCreateInterval)
+	}
+}
+
+// StopAll stops both background loops; used on shutdown. An empty reason
+// means they are restarted right away and nobody is alerted.
+func (e *SyncEngine) StopAll(reason string) {
+	e.autoSync.Stop(reason)
+	e.autoCreate.Stop(reason)
+}
+
+// cursorRunner names a runner's cursors. Pairs may share a project, so
+// cursors are per pair; the default pair keeps the unprefixed names.
+func (e *SyncEngine) cursorRunner(trigger string) string {
+	if e.pair.Name == defaultPairName {
+		return trigger
+	}
+	return e.pair.Name + ":" + trigger
+}
+
+// SyncCursors lists the engine's incremental sync cursors.
+func (e *SyncEngine) SyncCursors() []SyncCursor {
+	cursors := []SyncCursor{}
+	for _, c := range e.cursors.List() {
+		if strings.HasPrefix(c.Key, e.cursorRunner(triggerAutoSync)+"/") ||
+			strings.HasPrefix(c.Key, e.cursorRunner(triggerAutoCreate)+"/") {
+			cursors = append(cursors, c)
+		}
+	}
+	return cursors
+}
+
+// commitWindow advances the cursors after a run without errors.
+func (e *SyncEngine) commitWindow(ctx context.Context, name string, window SyncWindow, errors int) {
+	if errors > 0 {
+		slog.WarnContext(ctx, name+" had errors; cursor not advanced", "errors", errors)
+		return
+	}
+	if err := e.cursors.Commit(window); err != nil {
+		slog.ErrorContext(ctx, name+" could not save its sync cursor", "error", err)
+	}
+}
+
+// performAutoSync only looks at mismatches where either side changed inside
+// the run's cursor window; see cursors.go.
+func (e *SyncEngine) performAutoSync(ctx context.Context) (string, error) {
+	window := e.cursors.Window(e.cursorRunner(triggerAutoSync), e.pair.AsanaProjectID, e.pair.YouTrackProjectID)
+
+	analysis, err := performTicketAnalysis(ctx, e, currentConfig().Columns.Syncable)
+	if err != nil {
+		slog.ErrorContext(ctx, "Auto-sync analysis failed", "error", err)
+		return fmt.Sprintf("Analysis failed: %v", err), err
+	}
+
+	synced := 0
+	errors := 0
+	unchanged := 0
+
+	for _, ticket := range analysis.Mismatched {
+		if e.IsIgnored(ticket.AsanaTask.GID) {
+			continue
+		}
+		if !window.AsanaChanged(ticket.AsanaTask) && !window.YouTrackChanged(ticket.YouTrackIssue) {
+			unchanged++
+			continue
+		}
+
+		err := syncMismatchedTicket(ctx, ticket)
+		if err != nil {
+			slog.ErrorContext(ctx, "Auto-sync could not update ticket", "asana_id", ticket.AsanaTask.GID, "youtrack_id", ticket.YouTrackIssue.ID, "error", err)
+			notifierFrom(ctx).Failed(ctx, "sync/"+ticket.AsanaTask.GID, fmt.Sprintf("Syncing %s to %s", ticket.AsanaTask.GID, ticket.YouTrackIssue.ID), err)
+			errors++
+		} else {
+			notifierFrom(ctx).Succeeded(ctx, "sync/"+ticket.AsanaTask.GID)
+			synced++
+		}
+	}
+
+	e.MarkSynced()
+	e.commitWindow(ctx, "Auto-sync", window, errors)
+
+	return fmt.Sprintf("Synced: %d, Errors: %d, Unchanged: %d (%s)", synced, errors, unchanged, window), nil
+}
+
+func (e *SyncEngine) performAutoCreate(ctx context.Context) (string, error) {
+	window := e.cursors.Window(e.cursorRunner(triggerAutoCreate), e.pair.AsanaProjectID, e.pair.YouTrackProjectID)
+
+	analysis, err := performTicketAnalysis(ctx, e, currentConfig().Columns.Syncable)
+	if err != nil {
+		slog.ErrorContext(ctx, "Auto-create analysis failed", "error", err)
+		return fmt.Sprintf("Analysis failed: %v", err), err
+	}
+
+	created := 0
+	failed := 0
+	unchanged := 0
+
+	for _, task := range analysis.MissingYouTrack {
+		if !window.AsanaChanged(task) {
+			unchanged++
+			continue
+		}
+		if e.IsIgnored(task.GID) {
+			continue
+		}
+
+		err := createYouTrackIssue(ctx, task)
+		if errors.Is(err, errDuplicateTicket) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Auto-create could not create ticket", "asana_id", task.GID, "error", err)
+			notifierFrom(ctx).Failed(ctx, "create/"+task.GID, "Creating the YouTrack issue for "+task.GID, err)
+			failed++
+		} else {
+			notifierFrom(ctx).Succeeded(ctx, "create/"+task.GID)
+			created++
+		}
+	}
+
+	e.commitWindow(ctx, "Auto-create", window, failed)
+
+	return fmt.Sprintf("Created: %d, Errors: %d, Unchanged: %d (%s)", created, failed, unchanged, window), nil
+}
+
+// autoRunner drives one periodic background job (auto-sync or auto-create).
+// Its fields are only touched under mu; the run function itself executes
+// without the lock so that status reads never block on a slow tracker call.
+// Each ticker fire goes through the engine's run coordinator: if the previous
+// run (or a manual one) still holds the project, the fire is skipped.
+// Stopping the runner cancels the context of a run in progress and waits
+// for it to return.
+type autoRunner struct {
+	name    string
+	trigger string
+	engine  *SyncEngine
+	run     func(context.Context) (string, error) // error when the run could not analyse
+
+	mu       sync.Mutex
+	running  bool
+	interval int
+	ctx      context.Context
+	cancel   context.CancelFunc
+	count    int
+	skipped  int
+	lastRun  time.Time
+	lastInfo string
+
+	ticks sync.WaitGroup // runs in flight
+}
+
+type autoRunnerState struct {
+	Running  bool
+	Interval int
+	Count    int
+	Skipped  int
+	LastRun  time.Time
+	NextRun  time.Time
+	LastInfo string
+}
+
+func newAutoRunner(name, trigger string, engine *SyncEngine, run func(context.Context) (string, error)) *autoRunner {
+	return &autoRunner{
+		name:     name,
+		trigger:  trigger,
+		engine:   engine,
+		run:      run,
+		interval: 15, // default to 15 seconds
+	}
+}
+
+// Start launches the loop. It returns false if the loop was already running.
+func (a *autoRunner) Start(interval int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running {
+		return false
+	}
+
+	if interval > 0 {
+		a.interval = interval
+	} else {
+		a.interval = 15
+	}
+
+	a.running = true
+	a.ctx, a.cancel = context.WithCancel(withPair(withAuditContext(context.Background(), AuditContext{
+		Actor:   "system",
+		Trigger: a.trigger,
+	}), a.engine))
+	ticker := time.NewTicker(time.Duration(a.interval) * time.Second)
+
+	slog.InfoContext(a.ctx, a.name+" started", "interval_seconds", a.interval)
+
+	go a.loop(a.ctx, ticker)
+	return true
+}
+
+// Stop halts the loop, waits for a run in progress to return and, unless
+// reason is empty, sends an auto_stopped alert. It returns false if the
+// loop was not running.
+func (a *autoRunner) Stop(reason string) bool {
+	a.mu.Lock()
+	if !a.running {
+		a.mu.Unlock()
+		return false
+	}
+	a.running = false
+	a.cancel()
+	ctx := a.ctx
+	a.mu.Unlock()
+
+	// The run's context is cancelled, so it ends at its next tracker call
+	a.ticks.Wait()
+
+	slog.InfoContext(ctx, a.name+" stopped", "reason", reason)
+	if reason != "" {
+		notifyAutoStopped(ctx, a.name, reason)
+	}
+	return true
+}
+
+func (a *autoRunner) loop(ctx context.Context, ticker *time.Ticker) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Counted under mu, so Stop either waits for this run or it never starts
+			a.mu.Lock()
+			if !a.running || a.ctx != ctx {
+				a.mu.Unlock()
+				return
+			}
+			a.ticks.Add(1)
+			a.mu.Unlock()
+			go func() {
+				defer a.ticks.Done()
+				a.tick(ctx)
+			}()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *autoRunner) tick(ctx context.Context) {
+	// Each run gets its own ID, sent on the tracker calls it makes
+	ctx = withRequestID(ctx, newRequestID())
+	ctx, span := startSpan(ctx, a.trigger+" run", trace.WithNewRoot(),
+		trace.WithAttributes(attribute.String("request_id", requestIDFrom(ctx))))
+
+	var info string
+	var runErr error
+	defer func() { endSpan(span, runErr) }()
+	ran := a.engine.TryRunExclusive(a.trigger, func() {
+		a.mu.Lock()
+		n := a.count + 1
+		a.mu.Unlock()
+
+		slog.DebugContext(ctx, "Performing "+a.name, "run", n)
+		info, runErr = a.run(ctx)
+	})
+
+	a.mu.Lock()
+	if !ran {
+		a.skipped++
+		a.mu.Unlock()
+		observeAutoRun(ctx, a.trigger, "skipped")
+		span.SetAttributes(attribute.String("result", "skipped"))
+		slog.InfoContext(ctx, a.name+" skipped: another run is still in progress")
+		return
+	}
+	if runErr != nil {
+		observeAutoRun(ctx, a.trigger, "failed")
+		notifierFrom(ctx).Failed(ctx, a.trigger, a.name, runErr)
+	} else {
+		observeAutoRun(ctx, a.trigger, "completed")
+		notifierFrom(ctx).Succeeded(ctx, a.trigger)
+	}
+	a.count++
+	a.lastRun = time.Now()
+	a.lastInfo = info
+	n := a.count
+	a.mu.Unlock()
+
+	slog.InfoContext(ctx, a.name+" completed", "run", n, "result", info)
+}
+
+func (a *autoRunner) State() autoRunnerState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state := autoRunnerState{
+		Running:  a.running,
+		Interval: a.interval,
+		Count:    a.count,
+		Skipped:  a.skipped,
+		LastRun:  a.lastRun,
+		LastInfo: a.lastInfo,
+	}
+	if a.running {
+		state.NextRun = time.Now().Add(time.Duration(a.interval) * time.Second)
+	}
+	return state
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useTestConfig installs the default configuration for the test
+func useTestConfig(t *testing.T) Config {
+	t.Helper()
+	c, err := buildConfig("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := currentConfig()
+	setConfig(c)
+	t.Cleanup(func() {
+		if previous != nil {
+			setConfig(*previous)
+		}
+	})
+	return c
+}
+
+func newTestEngine(t *testing.T) *SyncEngine {
+	t.Helper()
+	pair := ProjectPair{Name: defaultPairName, AsanaProjectID: "A1", YouTrackProjectID: "YT"}
+	return NewSyncEngine(pair, filepath.Join(t.TempDir(), "ignored.json"), nil, NewRunCoordinator())
+}
+
+func TestSyncEngineIgnoreListsConcurrently(t *testing.T) {
+	useTestConfig(t)
+	e := newTestEngine(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("T%d", i)
+			forever := i%2 == 0
+			e.Ignore(id, forever)
+			if !e.IsIgnored(id) {
+				t.Errorf("%s not ignored after Ignore", id)
+			}
+			e.IgnoredTemp()
+			e.IgnoredForever()
+			if i%4 == 0 {
+				e.Unignore(id, forever)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	forever := e.IgnoredForever()
+	sort.Strings(forever)
+	want := []string{"T10", "T14", "T18", "T2", "T6"}
+	if fmt.Sprint(forever) != fmt.Sprint(want) {
+		t.Errorf("ignored forever = %v, want %v", forever, want)
+	}
+	if n := len(e.IgnoredTemp()); n != 10 {
+		t.Errorf("%d temporarily ignored, want 10", n)
+	}
+
+	// The forever list survives a restart
+	reloaded := NewSyncEngine(e.pair, e.ignoreFile, nil, NewRunCoordinator())
+	got := reloaded.IgnoredForever()
+	sort.Strings(got)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reloaded ignored forever = %v, want %v", got, want)
+	}
+}
+
+func TestAutoRunnerStartStopConcurrently(t *testing.T) {
+	useTestConfig(t)
+	e := newTestEngine(t)
+
+	var started, stopped int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if e.StartAutoSync(60) {
+				atomic.AddInt32(&started, 1)
+			}
+			e.AutoSyncState()
+		}()
+	}
+	wg.Wait()
+	if started != 1 {
+		t.Errorf("%d concurrent starts succeeded, want 1", started)
+	}
+	if state := e.AutoSyncState(); !state.Running || state.Interval != 60 {
+		t.Errorf("state = %+v, want running every 60s", state)
+	}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if e.StopAutoSync("") {
+				atomic.AddInt32(&stopped, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if stopped != 1 {
+		t.Errorf("%d concurrent stops succeeded, want 1", stopped)
+	}
+	if e.AutoSyncState().Running {
+		t.Error("auto-sync still running after Stop")
+	}
+}
+
+func TestAutoRunnerTickSkipsWhileProjectBusy(t *testing.T) {
+	useTestConfig(t)
+	e := newTestEngine(t)
+
+	var ran int32
+	runner := newAutoRunner("Auto-sync [test]", triggerAutoSync, e, func(context.Context) (string, error) {
+		atomic.AddInt32(&ran, 1)
+		return "ok", nil
+	})
+
+	// A manual run holds the project; every tick is skipped
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go e.RunExclusive(context.Background(), triggerManual, func() {
+		close(held)
+		<-release
+	})
+	<-held
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner.tick(context.Background())
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	state := runner.State()
+	if state.Skipped != 10 || state.Count != 0 || ran != 0 {
+		t.Errorf("while busy: skipped = %d, count = %d, ran = %d; want 10, 0, 0", state.Skipped, state.Count, ran)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for e.RunStatus().InProgress {
+		if time.Now().After(deadline) {
+			t.Fatal("manual run did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	runner.tick(context.Background())
+	if state := runner.State(); state.Count != 1 || state.LastInfo != "ok" || ran != 1 {
+		t.Errorf("after release: %+v, ran = %d; want one completed run", state, ran)
+	}
+}
+
+func TestAutoRunnerOverlappingTicksRunOnce(t *testing.T) {
+	useTestConfig(t)
+	e := newTestEngine(t)
+
+	var active, overlaps int32
+	runner := newAutoRunner("Auto-create [test]", triggerAutoCreate, e, func(context.Context) (string, error) {
+		if atomic.AddInt32(&active, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		return "", errors.New("tracker down")
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner.tick(context.Background())
+			runner.State()
+		}()
+	}
+	wg.Wait()
+
+	state := runner.State()
+	if overlaps != 0 {
+		t.Errorf("%d runs overlapped", overlaps)
+	}
+	if state.Count+state.Skipped != 30 || state.Count == 0 {
+		t.Errorf("count = %d, skipped = %d; want them to add up to 30 with at least one run", state.Count, state.Skipped)
+	}
+}
+
+func TestAutoRunnerStopWaitsForRunInProgress(t *testing.T) {
+	useTestConfig(t)
+	e := newTestEngine(t)
+
+	started := make(chan struct{})
+	var once sync.Once
+	var finished int32
+	runner := newAutoRunner("Auto-sync [test]", triggerAutoSync, e, func(ctx context.Context) (string, error) {
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond) // e.g. writing the audit entry of the last call
+		atomic.StoreInt32(&finished, 1)
+		return "", ctx.Err()
+	})
+	runner.Start(1)
+	<-started
+
+	if !runner.Stop("") {
+		t.Fatal("Stop reported the runner was not running")
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Fatal("Stop returned while a run was still in progress")
+	}
+}
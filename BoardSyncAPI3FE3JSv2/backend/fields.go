@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// YouTrack issue payloads must name each custom field's exact $type, which
+// depends on how the field is set up in the project (State may be a plain
+// enum, Subsystem single or multi, ...). fieldSchema holds a project's field
+// definitions; YouTrackClient caches one per project.
+
+// fieldSchemaTTL is how long a project's field definitions are reused.
+// Validation errors from YouTrack drop the cached schema straight away.
+const fieldSchemaTTL = 10 * time.Minute
+
+// errNoSuchField is returned for fields the project does not have
+var errNoSuchField = errors.New("field not in project")
+
+// fieldKind is how a field is written in issue payloads
+type fieldKind struct {
+	issueType string // e.g. SingleEnumIssueCustomField
+	valueType string // e.g. EnumBundleElement; empty for plain values
+	multi     bool
+}
+
+// issueFieldKinds maps a project field's fieldType.id, without the [1] or
+// [*] cardinality suffix, onto the issue field and value types.
+var issueFieldKinds = map[string]struct{ single, multi, value string }{
+	"enum":          {"SingleEnumIssueCustomField", "MultiEnumIssueCustomField", "EnumBundleElement"},
+	"ownedField":    {"SingleOwnedIssueCustomField", "MultiOwnedIssueCustomField", "OwnedBundleElement"},
+	"state":         {"StateIssueCustomField", "", "StateBundleElement"},
+	"user":          {"SingleUserIssueCustomField", "MultiUserIssueCustomField", "User"},
+	"group":         {"SingleGroupIssueCustomField", "MultiGroupIssueCustomField", "UserGroup"},
+	"version":       {"SingleVersionIssueCustomField", "MultiVersionIssueCustomField", "VersionBundleElement"},
+	"build":         {"SingleBuildIssueCustomField", "MultiBuildIssueCustomField", "BuildBundleElement"},
+	"date":          {"DateIssueCustomField", "", ""},
+	"date and time": {"DateIssueCustomField", "", ""},
+	"integer":       {"SimpleIssueCustomField", "", ""},
+	"float":         {"SimpleIssueCustomField", "", ""},
+	"string":        {"SimpleIssueCustomField", "", ""},
+	"text":          {"TextIssueCustomField", "", "TextFieldValue"},
+	"period":        {"PeriodIssueCustomField", "", "PeriodValue"},
+}
+
+func kindOf(f YouTrackProjectField) (fieldKind, string, error) {
+	id := f.Field.FieldType.ID
+	base := strings.TrimSuffix(strings.TrimSuffix(id, "[1]"), "[*]")
+	kinds, ok := issueFieldKinds[base]
+	if !ok {
+		return fieldKind{}, base, fmt.Errorf("field %s has unsupported type %q", f.Field.Name, id)
+	}
+	kind := fieldKind{issueType: kinds.single, valueType: kinds.value}
+	if strings.HasSuffix(id, "[*]") && kinds.multi != "" {
+		kind.issueType, kind.multi = kinds.multi, true
+	}
+	return kind, base, nil
+}
+
+// fieldSchema is the custom field setup of one YouTrack project
+type fieldSchema struct {
+	project   string
+	fields    map[string]YouTrackProjectField
+	fetchedAt time.Time
+	assumed   bool // discovery failed; fields are the historical defaults
+}
+
+// assumedFieldSchema is used when the project's fields cannot be read: a
+// state-type State and a multi-value owned Subsystem, as the sync always
+// assumed before discovery.
+func assumedFieldSchema(project string) *fieldSchema {
+	s := &fieldSchema{project: project, fields: map[string]YouTrackProjectField{}, fetchedAt: time.Now(), assumed: true}
+	for name, typeID := range map[string]string{"State": "state[1]", "Subsystem": "ownedField[*]"} {
+		var f YouTrackProjectField
+		f.Field.Name = name
+		f.Field.FieldType.ID = typeID
+		s.fields[name] = f
+	}
+	return s
+}
+
+// Has reports whether the project has the field
+func (s *fieldSchema) Has(name string) bool {
+	_, ok := s.fields[name]
+	return ok
+}
+
+// HasValue reports whether value can be set on a bundle field. Fields
+// without a bundle (and assumed fields) accept anything.
+func (s *fieldSchema) HasValue(name, value string) bool {
+	f, ok := s.fields[name]
+	if !ok {
+		return false
+	}
+	return len(missingBundleValues(f, []string{value})) == 0
+}
+
+// IssueField builds the customFields entry that sets name to values. Bundle
+// values are names, users are logins, dates are RFC 3339 or YYYY-MM-DD. No
+// values clears the field.
+func (s *fieldSchema) IssueField(name string, values ...string) (map[string]interface{}, error) {
+	f, ok := s.fields[name]
+	if !ok {
+		return nil, fmt.Errorf("%s in project %s: %w", name, s.project, errNoSuchField)
+	}
+	kind, base, err := kindOf(f)
+	if err != nil {
+		return nil, err
+	}
+	if !kind.multi && len(values) > 1 {
+		return nil, fmt.Errorf("field %s takes one value, got %d", name, len(values))
+	}
+
+	converted := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		value, err := fieldValue(base, kind.valueType, v)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		converted = append(converted, value)
+	}
+
+	entry := map[string]interface{}{"$type": kind.issueType, "name": name}
+	switch {
+	case kind.multi:
+		entry["value"] = converted
+	case len(converted) == 0:
+		entry["value"] = nil
+	default:
+		entry["value"] = converted[0]
+	}
+	return entry, nil
+}
+
+func fieldValue(base, valueType, v string) (interface{}, error) {
+	switch base {
+	case "user":
+		return map[string]interface{}{"$type": valueType, "login": v}, nil
+	case "date", "date and time":
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", v); err != nil {
+				return nil, fmt.Errorf("%q is not a date", v)
+			}
+		}
+		return t.UnixMilli(), nil
+	case "integer":
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", v)
+		}
+		return n, nil
+	case "float":
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", v)
+		}
+		return n, nil
+	case "string":
+		return v, nil
+	case "text":
+		return map[string]interface{}{"$type": valueType, "text": v}, nil
+	case "period":
+		return map[string]interface{}{"$type": valueType, "presentation": v}, nil
+	default:
+		return map[string]interface{}{"$type": valueType, "name": v}, nil
+	}
+}
+
+// fieldSchemaCache holds the field schemas a YouTrackClient has fetched.
+// Credentials changes build a new client, so the cache goes with them.
+type fieldSchemaCache struct {
+	mu       sync.Mutex
+	schemas  map[string]*fieldSchema
+	fetching map[string]chan struct{} // closed when the project's fetch ends
+}
+
+// freshLocked returns the cached schema of project if it is still fresh;
+// mu must be held.
+func (c *fieldSchemaCache) freshLocked(project string) *fieldSchema {
+	s, ok := c.schemas[project]
+	if !ok {
+		return nil
+	}
+	ttl := fieldSchemaTTL
+	if s.assumed {
+		ttl = time.Minute
+	}
+	if time.Since(s.fetchedAt) >= ttl {
+		return nil
+	}
+	return s
+}
+
+// FieldSchema returns the custom fields of project (key or database ID),
+// from the cache while fresh. When the fields cannot be read it logs and
+// returns the assumed schema, retried after a minute.
+//
+// The fetch runs without the cache lock: callers for the same project wait
+// for the one fetch in flight, other projects are not held up by it.
+func (c *YouTrackClient) FieldSchema(ctx context.Context, project string) *fieldSchema {
+	cache := &c.schemas
+	cache.mu.Lock()
+	for {
+		if s := cache.freshLocked(project); s != nil {
+			cache.mu.Unlock()
+			return s
+		}
+		done, busy := cache.fetching[project]
+		if !busy {
+			break
+		}
+		cache.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return assumedFieldSchema(project)
+		}
+		cache.mu.Lock()
+	}
+	if cache.fetching == nil {
+		cache.fetching = make(map[string]chan struct{})
+	}
+	done := make(chan struct{})
+	cache.fetching[project] = done
+	cache.mu.Unlock()
+
+	s, err := c.fetchFieldSchema(ctx, project)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not read YouTrack fields, assuming State and multi-value Subsystem", "project", project, "error", err)
+		s = assumedFieldSchema(project)
+	}
+
+	cache.mu.Lock()
+	if cache.schemas == nil {
+		cache.schemas = make(map[string]*fieldSchema)
+	}
+	cache.schemas[project] = s
+	delete(cache.fetching, project)
+	cache.mu.Unlock()
+	close(done)
+	return s
+}
+
+// InvalidateFieldSchema drops the cached fields of project, so the next
+// payload is built from a fresh read.
+func (c *YouTrackClient) InvalidateFieldSchema(project string) {
+	c.schemas.mu.Lock()
+	defer c.schemas.mu.Unlock()
+	delete(c.schemas.schemas, project)
+}
+
+func (c *YouTrackClient) fetchFieldSchema(ctx context.Context, project string) (*fieldSchema, error) {
+	// customFields is keyed by database ID; pairs usually name the key
+	projects, err := c.AdminProjects(ctx, 500)
+	if err != nil {
+		return nil, err
+	}
+	projectID := ""
+	for _, p := range projects {
+		if p.ShortName == project || p.ID == project {
+			projectID = p.ID
+			break
+		}
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("project %s: %w", project, ErrNotFound)
+	}
+
+	fields, err := c.ProjectCustomFields(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	s := &fieldSchema{project: project, fields: make(map[string]YouTrackProjectField, len(fields)), fetchedAt: time.Now()}
+	for _, f := range fields {
+		s.fields[f.Field.Name] = f
+	}
+	return s, nil
+}
+
+// pairFieldSchema is the field schema of the YouTrack project of the pair in ctx
+func pairFieldSchema(ctx context.Context) *fieldSchema {
+	return youTrackFrom(ctx).FieldSchema(ctx, pairFrom(ctx).YouTrackProjectID)
+}
+
+// syncedFields builds the customFields the sync sets for a task: State and,
+// when the project has it and knows the value, Subsystem. Skipped fields are
+// reported in notes.
+func syncedFields(ctx context.Context, state, subsystem string) ([]map[string]interface{}, []string, error) {
+	schema := pairFieldSchema(ctx)
+	var fields []map[string]interface{}
+	var notes []string
+
+	if state != "" {
+		f, err := schema.IssueField("State", state)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, f)
+	}
+
+	switch {
+	case subsystem == "":
+	case !schema.Has("Subsystem"):
+		notes = append(notes, "Subsystem field not available")
+	case !schema.HasValue("Subsystem", subsystem):
+		notes = append(notes, fmt.Sprintf("Subsystem has no value %q", subsystem))
+	default:
+		f, err := schema.IssueField("Subsystem", subsystem)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, notes, nil
+}
+
+// forgetFieldSchemaOn drops the pair's cached schema when YouTrack rejected
+// a payload, since the project's fields may have changed.
+func forgetFieldSchemaOn(ctx context.Context, err error) {
+	if errors.Is(err, ErrValidation) {
+		youTrackFrom(ctx).InvalidateFieldSchema(pairFrom(ctx).YouTrackProjectID)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func projectField(name, typeID string) YouTrackProjectField {
+	var f YouTrackProjectField
+	f.Field.Name = name
+	f.Field.FieldType.ID = typeID
+	return f
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		typeID    string
+		issueType string
+		valueType string
+		multi     bool
+	}{
+		{"state[1]", "StateIssueCustomField", "StateBundleElement", false},
+		{"state[*]", "StateIssueCustomField", "StateBundleElement", false}, // states are never multi
+		{"enum[1]", "SingleEnumIssueCustomField", "EnumBundleElement", false},
+		{"enum[*]", "MultiEnumIssueCustomField", "EnumBundleElement", true},
+		{"ownedField[1]", "SingleOwnedIssueCustomField", "OwnedBundleElement", false},
+		{"ownedField[*]", "MultiOwnedIssueCustomField", "OwnedBundleElement", true},
+		{"user[*]", "MultiUserIssueCustomField", "User", true},
+		{"version[1]", "SingleVersionIssueCustomField", "VersionBundleElement", false},
+		{"date", "DateIssueCustomField", "", false},
+		{"date and time", "DateIssueCustomField", "", false},
+		{"integer", "SimpleIssueCustomField", "", false},
+		{"string", "SimpleIssueCustomField", "", false},
+		{"text", "TextIssueCustomField", "TextFieldValue", false},
+		{"period", "PeriodIssueCustomField", "PeriodValue", false},
+	}
+	for _, tt := range tests {
+		kind, _, err := kindOf(projectField("F", tt.typeID))
+		if err != nil {
+			t.Errorf("kindOf(%s): %v", tt.typeID, err)
+			continue
+		}
+		if kind.issueType != tt.issueType || kind.valueType != tt.valueType || kind.multi != tt.multi {
+			t.Errorf("kindOf(%s) = %+v, want %s/%s multi=%v", tt.typeID, kind, tt.issueType, tt.valueType, tt.multi)
+		}
+	}
+
+	if _, _, err := kindOf(projectField("F", "vcs[1]")); err == nil {
+		t.Errorf("kindOf(vcs[1]) succeeded, want an unsupported type error")
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	tests := []struct {
+		base, valueType, in string
+		want                string // JSON
+		wantErr             bool
+	}{
+		{"state", "StateBundleElement", "Done", `{"$type":"StateBundleElement","name":"Done"}`, false},
+		{"ownedField", "OwnedBundleElement", "API", `{"$type":"OwnedBundleElement","name":"API"}`, false},
+		{"user", "User", "jdoe", `{"$type":"User","login":"jdoe"}`, false},
+		{"date", "", "2024-03-01", `1709251200000`, false},
+		{"date and time", "", "2024-03-01T12:00:00Z", `1709294400000`, false},
+		{"date", "", "March 1st", "", true},
+		{"integer", "", "42", `42`, false},
+		{"integer", "", "4.2", "", true},
+		{"float", "", "4.5", `4.5`, false},
+		{"float", "", "many", "", true},
+		{"string", "", "plain", `"plain"`, false},
+		{"text", "TextFieldValue", "long", `{"$type":"TextFieldValue","text":"long"}`, false},
+		{"period", "PeriodValue", "1w 2d", `{"$type":"PeriodValue","presentation":"1w 2d"}`, false},
+	}
+	for _, tt := range tests {
+		got, err := fieldValue(tt.base, tt.valueType, tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("fieldValue(%s, %q) = %v, want an error", tt.base, tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("fieldValue(%s, %q): %v", tt.base, tt.in, err)
+			continue
+		}
+		if data, _ := json.Marshal(got); string(data) != tt.want {
+			t.Errorf("fieldValue(%s, %q) = %s, want %s", tt.base, tt.in, data, tt.want)
+		}
+	}
+}
+
+func TestIssueField(t *testing.T) {
+	schema := &fieldSchema{project: "YT", fields: map[string]YouTrackProjectField{
+		"State":     projectField("State", "state[1]"),
+		"Priority":  projectField("Priority", "enum[1]"),
+		"Subsystem": projectField("Subsystem", "ownedField[*]"),
+		"Estimate":  projectField("Estimate", "integer"),
+	}}
+
+	tests := []struct {
+		name    string
+		values  []string
+		want    string // JSON
+		wantErr bool
+	}{
+		{"State", []string{"Done"}, `{"$type":"StateIssueCustomField","name":"State","value":{"$type":"StateBundleElement","name":"Done"}}`, false},
+		{"Priority", []string{"High"}, `{"$type":"SingleEnumIssueCustomField","name":"Priority","value":{"$type":"EnumBundleElement","name":"High"}}`, false},
+		{"Priority", nil, `{"$type":"SingleEnumIssueCustomField","name":"Priority","value":null}`, false},
+		{"Priority", []string{"High", "Low"}, "", true},
+		{"Subsystem", []string{"API", "UI"}, `{"$type":"MultiOwnedIssueCustomField","name":"Subsystem","value":[{"$type":"OwnedBundleElement","name":"API"},{"$type":"OwnedBundleElement","name":"UI"}]}`, false},
+		{"Subsystem", nil, `{"$type":"MultiOwnedIssueCustomField","name":"Subsystem","value":[]}`, false},
+		{"Estimate", []string{"3"}, `{"$type":"SimpleIssueCustomField","name":"Estimate","value":3}`, false},
+		{"Estimate", []string{"three"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := schema.IssueField(tt.name, tt.values...)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("IssueField(%s, %v) = %v, want an error", tt.name, tt.values, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("IssueField(%s, %v): %v", tt.name, tt.values, err)
+			continue
+		}
+		if data, _ := json.Marshal(got); string(data) != tt.want {
+			t.Errorf("IssueField(%s, %v) = %s, want %s", tt.name, tt.values, data, tt.want)
+		}
+	}
+
+	if _, err := schema.IssueField("Assignee", "jdoe"); !errors.Is(err, errNoSuchField) {
+		t.Errorf("IssueField(Assignee) err = %v, want errNoSuchField", err)
+	}
+}
+
+func TestFieldSchemaSlowProjectDoesNotBlockOthers(t *testing.T) {
+	useTestConfig(t)
+	held := make(chan struct{})
+	release := make(chan struct{})
+	var slowFetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/admin/projects":
+			w.Write([]byte(`[{"id":"0-1","shortName":"SLOW"},{"id":"0-2","shortName":"FAST"}]`))
+		case "/api/admin/projects/0-1/customFields":
+			if atomic.AddInt32(&slowFetches, 1) == 1 {
+				close(held)
+			}
+			<-release
+			w.Write([]byte(`[{"field":{"name":"State","fieldType":{"id":"enum[1]"}}}]`))
+		case "/api/admin/projects/0-2/customFields":
+			w.Write([]byte(`[{"field":{"name":"State","fieldType":{"id":"state[1]"}}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := &YouTrackClient{trackerClient: newTrackerClient(trackerYouTrack, srv.URL, "token")}
+
+	var wg sync.WaitGroup
+	slow := make([]*fieldSchema, 4)
+	for i := range slow {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slow[i] = c.FieldSchema(context.Background(), "SLOW")
+		}(i)
+	}
+	<-held
+
+	fast := make(chan *fieldSchema)
+	go func() { fast <- c.FieldSchema(context.Background(), "FAST") }()
+	select {
+	case s := <-fast:
+		if s.assumed || !s.Has("State") {
+			t.Fatalf("FAST schema = %+v, want the fetched fields", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("FAST waited for the SLOW fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	for i, s := range slow {
+		if s.assumed || s != slow[0] {
+			t.Fatalf("SLOW caller %d got a different or assumed schema", i)
+		}
+	}
+	if n := atomic.LoadInt32(&slowFetches); n != 1 {
+		t.Fatalf("%d SLOW field fetches, want 1 shared by all callers", n)
+	}
+}
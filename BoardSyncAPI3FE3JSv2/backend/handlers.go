@@ -1,13 +1,36 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
-func healthCheck(w http.ResponseWriter, r *http.Request) {
+// Server exposes the HTTP API. Runtime state lives in the tenants of the
+// injected registry rather than in package globals.
+type Server struct {
+	tenants *TenantRegistry
+	keys    *KeyStore
+	oidc    *OIDCVerifier // nil when OIDC login is not configured
+
+	confirmations *ConfirmationStore
+}
+
+func NewServer(tenants *TenantRegistry, keys *KeyStore, oidc *OIDCVerifier) *Server {
+	return &Server{
+		tenants:       tenants,
+		keys:          keys,
+		oidc:          oidc,
+		confirmations: NewConfirmationStore(),
+	}
+}
+
+func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":    "healthy",
@@ -23,68 +46,186 @@ func healthCheck(w http.ResponseWriter, r *http.Request) {
 			"Auto-create functionality",
 			"Ticket detail views",
 			"Interactive console (fixed)",
-			"Bulk ticket deletion", // NEW
+			"Bulk ticket deletion",
+			"Durable job queue",
+			"API key and OIDC authentication with roles",
+			"Audit log of tracker mutations",
+			"Trash and restore for deleted tickets",
+			"Soft delete: archive or resolve instead of deleting",
+			"Incrementally refreshed tracker cache",
+			"Incremental auto-sync with persisted cursors",
+			"Multiple Asana/YouTrack project pairs",
+			"Multi-tenant workspaces with isolated credentials and data",
+			"Encrypted secret storage with runtime token rotation",
+			"YAML config file with validation and hot reload",
+			"Tracker diagnostics with actionable findings",
+			"YouTrack custom field types discovered per project",
+			"Opt-in provisioning of missing YouTrack state/subsystem values",
+			"Structured logs with levels and X-Request-ID correlation",
+			"Prometheus metrics for analyses, ticket operations, tracker calls and auto runs",
+			"OpenTelemetry tracing of requests, tracker calls and analysis phases (OTLP or stdout)",
+			"Alerts on findings, repeated sync failures and stopped auto runs (webhook, Slack, e-mail)",
 		},
 		"columns": map[string]interface{}{
-			"syncable":     syncableColumns,
-			"display_only": displayOnlyColumns,
+			"syncable":     currentConfig().Columns.Syncable,
+			"display_only": currentConfig().Columns.DisplayOnly,
 		},
 	})
 }
 
-func statusCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"service":          "enhanced-asana-youtrack-sync",
-		"last_sync":        lastSyncTime.Format(time.RFC3339),
-		"poll_interval":    config.PollIntervalMS,
-		"asana_project":    config.AsanaProjectID,
-		"youtrack_project": config.YouTrackProjectID,
+// statusCheck reports the service, plus the state of the caller's tenant when
+// one can be resolved. The tenant's default pair is kept at the top level for
+// older clients; "pairs" has the state of every pair.
+func (s *Server) statusCheck(w http.ResponseWriter, r *http.Request) {
+	cfg := currentConfig()
+	trackerHTTP := map[string]interface{}{
+		"max_attempts":          cfg.Retry.MaxAttempts,
+		"max_retry_after":       cfg.Retry.MaxRetryAfter.String(),
+		"asana_rate_per_min":    cfg.AsanaRateLimitPerMin,
+		"youtrack_rate_per_min": cfg.YouTrackRateLimitPerMin,
+	}
+	alertStatus := map[string]interface{}{
+		"service_channels":  alertChannelNames(cfg.Alerts.Channels),
+		"failure_threshold": cfg.Alerts.FailureThreshold,
+	}
+	status := map[string]interface{}{
+		"service":       "enhanced-asana-youtrack-sync",
+		"poll_interval": cfg.PollIntervalMS,
 		"columns": map[string]interface{}{
-			"syncable":     syncableColumns,
-			"display_only": displayOnlyColumns,
-		},
-		"temp_ignored":    len(ignoredTicketsTemp),
-		"forever_ignored": len(ignoredTicketsForever),
-		"tag_mappings":    len(defaultTagMapping),
-		"auto_sync": map[string]interface{}{
-			"running":   autoSyncRunning,
-			"interval":  autoSyncInterval,
-			"count":     autoSyncCount,
-			"last_info": autoSyncLastInfo,
-		},
-		"auto_create": map[string]interface{}{
-			"running":   autoCreateRunning,
-			"interval":  autoCreateInterval,
-			"count":     autoCreateCount,
-			"last_info": autoCreateLastInfo,
+			"syncable":     cfg.Columns.Syncable,
+			"display_only": cfg.Columns.DisplayOnly,
 		},
+		"tracker_http": trackerHTTP,
 		"endpoints": []string{
 			"GET /health - Health check",
 			"GET /status - Service status",
 			"GET /analyze - Analyze ticket differences",
-			"POST /create - Create missing tickets (bulk)",
+			"?tenant=<id> or X-Tenant - Selects the tenant (not needed with a tenant-bound key or when only one tenant exists)",
+			"?pair=<name> - Selects the project pair for /analyze, /tickets, /sync, /create, /create-single, /delete-tickets, /ignore, /auto-sync and /auto-create (default: first pair)",
+			"POST /create - Queue creation of missing tickets (bulk, returns job ID)",
 			"POST /create-single - Create individual ticket",
-			"GET/POST /sync - Sync mismatched tickets",
+			"GET/POST /sync - List mismatched tickets / queue sync (returns job ID)",
 			"GET/POST /ignore - Manage ignored tickets",
 			"GET/POST /auto-sync - Control auto-sync functionality",
 			"GET/POST /auto-create - Control auto-create functionality",
 			"GET /tickets - Get tickets by type",
-			"POST /delete-tickets - Delete tickets (bulk)", // NEW
+			"POST /delete-tickets/preview - Preview a deletion and get a confirmation token (admin)",
+			"POST /delete-tickets - Queue ticket deletion (bulk, returns job ID; admin)",
+			"GET /jobs - List queued jobs",
+			"GET /jobs/{id} - Job progress and results",
+			"GET /whoami - Caller identity, role and permissions",
+			"GET/POST /provisioning - Proposed missing YouTrack State/Subsystem values / approve them (admin; provisioning.bundle_values)",
+			"GET/POST /alerts - Recently sent alerts of the tenant / send a test alert to its channels (operator; POST admin)",
+			"GET /metrics - Prometheus text format metrics (viewer; tenant-bound callers see their tenant)",
+			"GET /diagnostics - Check tokens, project access and YouTrack State/Subsystem values; ?pair=<name> limits to one pair (operator)",
+			"GET /audit - Audit log with filters and pagination; format=jsonl|csv exports (admin)",
+			"GET /trash - Deleted tickets that can still be restored (admin)",
+			"GET /trash/{id} - Full snapshot of a deleted ticket (admin)",
+			"POST /restore - Recreate a deleted ticket from the trash (admin)",
+			"POST /webhooks/asana - Asana webhook receiver (signed events only; invalidates the tracker cache)",
+			"POST /webhooks/asana/register - Subscribe the pair's Asana project to /webhooks/asana (admin)",
+			"POST /webhooks/youtrack - YouTrack workflow webhook receiver (invalidates the tracker cache)",
+			"DELETE /jobs/{id} - Cancel a job",
+			"GET/POST /admin/api-keys - List or create API keys (admin)",
+			"DELETE /admin/api-keys/{id} - Revoke an API key (admin)",
+			"GET/POST /admin/tenants - List or create tenants (service admin)",
+			"GET/PUT/DELETE /admin/tenants/{id} - Show, update or remove a tenant (service admin)",
+			"GET/POST /admin/secrets - Show which tenant credentials are set / rotate one without a restart (admin)",
+			"GET /admin/config - Running configuration and last reload (service admin)",
+			"POST /admin/config/reload - Re-read config.yaml and the environment; also on SIGHUP (service admin)",
 		},
-	})
-}
-
-func analyzeTicketsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		"auth": map[string]interface{}{
+			"header":      "X-API-Key",
+			"roles":       roleNames(),
+			"configured":  s.keys.Configured() || s.oidc != nil,
+			"oidc_issuer": cfg.OIDCIssuer,
+		},
+		"trash": map[string]interface{}{
+			"retention_days": cfg.TrashRetentionDays,
+		},
+		"secrets": map[string]interface{}{
+			"backend":    s.tenants.Secrets().Backend(),
+			"persistent": s.tenants.Secrets().Persistent(),
+		},
+		"config": map[string]interface{}{
+			"file":        configFileName(),
+			"last_reload": lastConfigReload(),
+		},
+		"logging": map[string]interface{}{
+			"level":  logLevel.Level().String(),
+			"format": cfg.LogFormat,
+		},
+		"alerts": alertStatus,
+		"tracing": map[string]interface{}{
+			"exporter":     cfg.Tracing.Exporter,
+			"sample_ratio": cfg.Tracing.SampleRatio,
+			"service_name": cfg.Tracing.ServiceName,
+		},
+		"delete_strategy":        cfg.DeleteStrategy,
+		"full_reconcile_minutes": cfg.FullReconcileMinutes,
+		"cors": map[string]interface{}{
+			"allowed_origins":   cfg.CORS.AllowedOrigins,
+			"allow_credentials": cfg.CORS.AllowCredentials,
+		},
+	}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	t, _, _, msg := s.resolveTenant(r)
+	if t == nil {
+		if principalFrom(r.Context()).Tenant == "" {
+			status["tenants"] = s.tenants.IDs()
+			trackerHTTP["retries"] = trackerRetryStats("")
+		}
+		status["tenant_error"] = msg
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
 		return
 	}
 
+	registry := t.Pairs()
+	engine := registry.Default()
+	autoSync := engine.AutoSyncState()
+	autoCreate := engine.AutoCreateState()
+
+	pairs := make([]map[string]interface{}, 0, len(registry.Names()))
+	for _, e := range registry.All() {
+		pairs = append(pairs, pairStatus(e))
+	}
+
+	status["tenant"] = t.ID()
+	trackerHTTP["retries"] = trackerRetryStats(t.ID())
+	alertStatus["channels"] = alertChannelNames(t.Record().Alerts)
+	status["last_sync"] = engine.LastSyncTime().Format(time.RFC3339)
+	status["asana_project"] = engine.Pair().AsanaProjectID
+	status["youtrack_project"] = engine.Pair().YouTrackProjectID
+	status["default_pair"] = engine.Pair().Name
+	status["pairs"] = pairs
+	status["temp_ignored"] = len(engine.IgnoredTemp())
+	status["forever_ignored"] = len(engine.IgnoredForever())
+	status["tag_mappings"] = len(engine.Pair().tagMapping())
+	status["auto_sync"] = map[string]interface{}{
+		"running":   autoSync.Running,
+		"interval":  autoSync.Interval,
+		"count":     autoSync.Count,
+		"skipped":   autoSync.Skipped,
+		"last_info": autoSync.LastInfo,
+	}
+	status["auto_create"] = map[string]interface{}{
+		"running":   autoCreate.Running,
+		"interval":  autoCreate.Interval,
+		"count":     autoCreate.Count,
+		"skipped":   autoCreate.Skipped,
+		"last_info": autoCreate.LastInfo,
+	}
+	status["runs"] = engine.RunStatus()
+	status["cache"] = engine.cache.Stats()
+	status["sync_cursors"] = engine.SyncCursors()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (s *Server) analyzeTicketsHandler(w http.ResponseWriter, r *http.Request) {
+	engine := engineFrom(r.Context())
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed. Use GET.", http.StatusMethodNotAllowed)
 		return
@@ -96,30 +237,20 @@ func analyzeTicketsHandler(w http.ResponseWriter, r *http.Request) {
 	// Default to all syncable columns if no specific column is requested
 	var columnsToAnalyze []string
 	if columnFilter == "" || columnFilter == "all_syncable" {
-		columnsToAnalyze = syncableColumns
+		columnsToAnalyze = currentConfig().Columns.Syncable
 	} else {
-		// Map frontend column names to backend column names
-		columnMap := map[string]string{
-			"backlog":         "backlog",
-			"in_progress":     "in progress",
-			"dev":             "dev",
-			"stage":           "stage",
-			"blocked":         "blocked",
-			"ready_for_stage": "ready for stage",
-			"findings":        "findings",
-		}
-
-		if mappedColumn, exists := columnMap[columnFilter]; exists {
+		// Frontend column names use underscores for spaces
+		if mappedColumn := strings.ReplaceAll(columnFilter, "_", " "); containsString(currentConfig().Columns.all(), mappedColumn) {
 			columnsToAnalyze = []string{mappedColumn}
 		} else {
-			columnsToAnalyze = syncableColumns // fallback
+			columnsToAnalyze = currentConfig().Columns.Syncable // fallback
 		}
 	}
 
-	// FIXED: Pass the specific columns instead of always using syncableColumns
-	analysis, err := performTicketAnalysis(columnsToAnalyze)
+	// FIXED: Pass the specific columns instead of always using the syncable columns
+	analysis, err := performTicketAnalysis(r.Context(), engine, columnsToAnalyze)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Analysis failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Analysis failed: %v", err), trackerErrorStatus(err))
 		return
 	}
 
@@ -157,16 +288,8 @@ func analyzeTicketsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Get tickets by type handler
-func getTicketsByTypeHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
+func (s *Server) getTicketsByTypeHandler(w http.ResponseWriter, r *http.Request) {
+	engine := engineFrom(r.Context())
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed. Use GET.", http.StatusMethodNotAllowed)
 		return
@@ -182,19 +305,20 @@ func getTicketsByTypeHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Handle ignored tickets separately
 	if ticketType == "ignored" {
+		ignored := engine.IgnoredForever()
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status":  "success",
 			"type":    "ignored",
-			"tickets": getMapKeys(ignoredTicketsForever),
-			"count":   len(ignoredTicketsForever),
+			"tickets": ignored,
+			"count":   len(ignored),
 		})
 		return
 	}
 
-	analysis, err := performTicketAnalysis(allColumns)
+	analysis, err := performTicketAnalysis(r.Context(), engine, currentConfig().Columns.all())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Analysis failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Analysis failed: %v", err), trackerErrorStatus(err))
 		return
 	}
 
@@ -239,21 +363,44 @@ func getTicketsByTypeHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // NEW: Delete tickets handler
-func deleteTicketsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+func (s *Server) deleteTicketsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	req, ok := decodeDeleteRequest(w, r)
+	if !ok {
 		return
 	}
 
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed. Use POST.", http.StatusMethodNotAllowed)
+	// Wiping both trackers needs a token from a preceding preview call
+	if req.Source == "both" {
+		principal := principalFrom(r.Context())
+		if req.ConfirmationToken == "" {
+			writeJSONError(w, http.StatusPreconditionRequired, "confirmation_required",
+				"Deleting from both trackers requires a confirmation_token. POST the same ticket_ids, source and strategy to /delete-tickets/preview (with the same pair) first.")
+			return
+		}
+		if err := s.confirmations.Consume(req.ConfirmationToken, principal.KeyID, confirmationScope(r.Context(), req), req.TicketIDs); err != nil {
+			writeJSONError(w, http.StatusPreconditionFailed, "invalid_confirmation", err.Error())
+			return
+		}
+		slog.InfoContext(r.Context(), "Confirmed delete from both trackers", "tickets", len(req.TicketIDs), "actor", principal.Name)
+	}
+	req.ConfirmationToken = ""
+
+	job, err := tenantFrom(r.Context()).jobs.Enqueue(r.Context(), jobTypeDelete, pairFrom(r.Context()).Name, req, actorName(r.Context()))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to queue delete: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	writeJobAccepted(w, job)
+}
+
+// decodeDeleteRequest parses and validates a delete body, writing a 400 on error
+func decodeDeleteRequest(w http.ResponseWriter, r *http.Request) (DeleteTicketsRequest, bool) {
 	var req DeleteTicketsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -263,7 +410,7 @@ func deleteTicketsHandler(w http.ResponseWriter, r *http.Request) {
 			"expected": "Object like: {\"ticket_ids\":[\"123\",\"456\"],\"source\":\"asana|youtrack|both\"}",
 			"example":  `{"ticket_ids":["1234567890","0987654321"],"source":"both"}`,
 		})
-		return
+		return req, false
 	}
 
 	// Validate request
@@ -274,7 +421,7 @@ func deleteTicketsHandler(w http.ResponseWriter, r *http.Request) {
 			"error":   "ticket_ids is required and must not be empty",
 			"example": `{"ticket_ids":["1234567890"],"source":"asana"}`,
 		})
-		return
+		return req, false
 	}
 
 	if req.Source == "" {
@@ -285,7 +432,7 @@ func deleteTicketsHandler(w http.ResponseWriter, r *http.Request) {
 			"valid_sources": []string{"asana", "youtrack", "both"},
 			"example":       `{"ticket_ids":["1234567890"],"source":"asana"}`,
 		})
-		return
+		return req, false
 	}
 
 	// Validate source value
@@ -298,119 +445,100 @@ func deleteTicketsHandler(w http.ResponseWriter, r *http.Request) {
 			"valid_sources": []string{"asana", "youtrack", "both"},
 			"received":      req.Source,
 		})
-		return
+		return req, false
 	}
 
-	// Perform bulk delete
-	fmt.Printf("Starting bulk delete of %d tickets from %s\n", len(req.TicketIDs), req.Source)
-
-	response := performBulkDelete(req.TicketIDs, req.Source)
-
-	// Set appropriate HTTP status based on result
-	httpStatus := http.StatusOK
-	if response.Status == "failed" {
-		httpStatus = http.StatusInternalServerError
-	} else if response.Status == "partial" {
-		httpStatus = http.StatusPartialContent
+	// Resolve the strategy now so a queued job keeps it across config changes
+	req.Strategy = req.Strategy.withDefaults()
+	if err := req.Strategy.Validate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": err.Error(),
+			"valid_strategies": map[string]interface{}{
+				"asana":    asanaStrategies,
+				"youtrack": youTrackStrategies,
+			},
+			"example": `{"ticket_ids":["1234567890"],"source":"both","strategy":{"asana":"complete","youtrack":"resolve"}}`,
+		})
+		return req, false
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(httpStatus)
-	json.NewEncoder(w).Encode(response)
-
-	fmt.Printf("Bulk delete completed: %s\n", response.Summary)
+	return req, true
 }
 
-func createMissingTicketsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+// confirmationScope is what a confirmation token is bound to besides the IDs
+func confirmationScope(ctx context.Context, req DeleteTicketsRequest) string {
+	return tenantFrom(ctx).ID() + "/" + pairFrom(ctx).Name + " " + req.Source + " " + req.Strategy.String()
+}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+// Delete preview handler: shows what a delete would remove and issues the
+// confirmation token that deleting from both trackers requires.
+func (s *Server) deletePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed. Use POST.", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if r.Method != "POST" && r.Method != "GET" {
-		http.Error(w, "Method not allowed. Use POST or GET.", http.StatusMethodNotAllowed)
+	req, ok := decodeDeleteRequest(w, r)
+	if !ok {
 		return
 	}
 
-	analysis, err := performTicketAnalysis(syncableColumns)
+	items, err := previewDelete(r.Context(), req.TicketIDs, req.Source)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Analysis failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Preview failed: %v", err), trackerErrorStatus(err))
 		return
 	}
 
-	if len(analysis.MissingYouTrack) == 0 {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":  "success",
-			"message": "No missing tickets to create",
-			"created": 0,
-		})
+	principal := principalFrom(r.Context())
+	token, expiresAt, err := s.confirmations.Issue(principal.KeyID, confirmationScope(r.Context(), req), req.TicketIDs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to issue confirmation token: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	results := []map[string]interface{}{}
-	created := 0
-	skipped := 0
-
-	for _, task := range analysis.MissingYouTrack {
-		asanaTags := getAsanaTags(task)
-
-		result := map[string]interface{}{
-			"task_id":    task.GID,
-			"task_name":  task.Name,
-			"asana_tags": asanaTags,
-		}
-
-		if isDuplicateTicket(task.Name) {
-			result["status"] = "skipped"
-			result["reason"] = "Duplicate ticket already exists"
-			skipped++
-		} else if isIgnored(task.GID) {
-			result["status"] = "skipped"
-			result["reason"] = "Ticket is ignored"
-			skipped++
-		} else {
-			err := createYouTrackIssue(task)
-			if err != nil {
-				result["status"] = "failed"
-				result["error"] = err.Error()
-			} else {
-				result["status"] = "created"
-				if len(asanaTags) > 0 {
-					primaryTag := asanaTags[0]
-					mappedSubsystem := mapTagToSubsystem(primaryTag)
-					result["mapped_subsystem"] = mappedSubsystem
-				}
-				created++
-			}
-		}
-		results = append(results, result)
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "completed",
-		"created": created,
-		"skipped": skipped,
-		"total":   len(analysis.MissingYouTrack),
-		"results": results,
+		"status":             "preview",
+		"pair":               pairFrom(r.Context()).Name,
+		"source":             req.Source,
+		"strategy":           req.Strategy,
+		"tickets":            items,
+		"count":              len(items),
+		"confirmation_token": token,
+		"expires_at":         expiresAt.Format(time.RFC3339),
+		"confirm_with":       `POST /delete-tickets with the same ticket_ids, source and strategy plus "confirmation_token"`,
 	})
 }
 
-func createSingleTicketHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+func (s *Server) createMissingTicketsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "GET" {
+		http.Error(w, "Method not allowed. Use POST or GET.", http.StatusMethodNotAllowed)
+		return
+	}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	job, err := tenantFrom(r.Context()).jobs.Enqueue(r.Context(), jobTypeCreate, pairFrom(r.Context()).Name, nil, actorName(r.Context()))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to queue create: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	writeJobAccepted(w, job)
+}
+
+// writeRunQueueError answers a request that gave up waiting for the
+// project's run slot; nothing was changed on its behalf.
+func writeRunQueueError(w http.ResponseWriter, engine *SyncEngine, err error) {
+	status := engine.RunStatus()
+	w.Header().Set("Retry-After", "5")
+	writeJSONError(w, http.StatusServiceUnavailable, "project_busy",
+		fmt.Sprintf("Gave up waiting for the %s run on project %s (%v); nothing was changed.",
+			status.CurrentTrigger, engine.pair.YouTrackProjectID, err))
+}
+
+func (s *Server) createSingleTicketHandler(w http.ResponseWriter, r *http.Request) {
+	engine := engineFrom(r.Context())
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed. Use POST.", http.StatusMethodNotAllowed)
 		return
@@ -438,9 +566,9 @@ func createSingleTicketHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	allTasks, err := getAsanaTasks()
+	allTasks, err := getAsanaTasks(r.Context())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get Asana tasks: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to get Asana tasks: %v", err), trackerErrorStatus(err))
 		return
 	}
 
@@ -464,7 +592,21 @@ func createSingleTicketHandler(w http.ResponseWriter, r *http.Request) {
 
 	asanaTags := getAsanaTags(*targetTask)
 
-	if isDuplicateTicket(targetTask.Name) {
+	// The duplicate check in createYouTrackIssue and the create must not
+	// interleave with another run
+	var duplicate, ignored bool
+	if qerr := engine.RunExclusive(r.Context(), triggerManual, func() {
+		ignored = engine.IsIgnored(req.TaskID)
+		if !ignored {
+			err = createYouTrackIssue(r.Context(), *targetTask)
+			duplicate = errors.Is(err, errDuplicateTicket)
+		}
+	}); qerr != nil {
+		writeRunQueueError(w, engine, qerr)
+		return
+	}
+
+	if duplicate {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status":     "skipped",
@@ -476,7 +618,7 @@ func createSingleTicketHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if isIgnored(req.TaskID) {
+	if ignored {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status":     "skipped",
@@ -488,7 +630,6 @@ func createSingleTicketHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = createYouTrackIssue(*targetTask)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -511,7 +652,7 @@ func createSingleTicketHandler(w http.ResponseWriter, r *http.Request) {
 
 	if len(asanaTags) > 0 {
 		primaryTag := asanaTags[0]
-		mappedSubsystem := mapTagToSubsystem(primaryTag)
+		mappedSubsystem := mapTagToSubsystem(r.Context(), primaryTag)
 		response["mapped_subsystem"] = mappedSubsystem
 	}
 
@@ -519,20 +660,12 @@ func createSingleTicketHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func syncMismatchedTicketsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
+func (s *Server) syncMismatchedTicketsHandler(w http.ResponseWriter, r *http.Request) {
+	engine := engineFrom(r.Context())
 	if r.Method == "GET" {
-		analysis, err := performTicketAnalysis(syncableColumns)
+		analysis, err := performTicketAnalysis(r.Context(), engine, currentConfig().Columns.Syncable)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Analysis failed: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Analysis failed: %v", err), trackerErrorStatus(err))
 			return
 		}
 
@@ -570,109 +703,24 @@ func syncMismatchedTicketsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	analysis, err := performTicketAnalysis(syncableColumns)
+	job, err := tenantFrom(r.Context()).jobs.Enqueue(r.Context(), jobTypeSync, pairFrom(r.Context()).Name, requests, actorName(r.Context()))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Analysis failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to queue sync: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	mismatchMap := make(map[string]MismatchedTicket)
-	for _, ticket := range analysis.Mismatched {
-		mismatchMap[ticket.AsanaTask.GID] = ticket
-	}
-
-	results := []map[string]interface{}{}
-	synced := 0
-
-	for _, req := range requests {
-		result := map[string]interface{}{
-			"ticket_id": req.TicketID,
-			"action":    req.Action,
-		}
-
-		ticket, exists := mismatchMap[req.TicketID]
-		if !exists {
-			result["status"] = "failed"
-			result["error"] = "Ticket not found in mismatched list"
-			results = append(results, result)
-			continue
-		}
-
-		switch req.Action {
-		case "sync":
-			if isIgnored(req.TicketID) {
-				result["status"] = "skipped"
-				result["reason"] = "Ticket is ignored"
-			} else {
-				err := updateYouTrackIssue(ticket.YouTrackIssue.ID, ticket.AsanaTask)
-				if err != nil {
-					result["status"] = "failed"
-					result["error"] = err.Error()
-				} else {
-					result["status"] = "synced"
-					result["status_change"] = map[string]string{
-						"from": ticket.YouTrackStatus,
-						"to":   ticket.AsanaStatus,
-					}
-
-					asanaTags := getAsanaTags(ticket.AsanaTask)
-					if len(asanaTags) > 0 {
-						primaryTag := asanaTags[0]
-						mappedSubsystem := mapTagToSubsystem(primaryTag)
-						result["tag_sync"] = map[string]interface{}{
-							"asana_tags":         asanaTags,
-							"mapped_subsystem":   mappedSubsystem,
-							"previous_subsystem": ticket.YouTrackSubsystem,
-						}
-					}
-					synced++
-				}
-			}
-
-		case "ignore_temp":
-			ignoredTicketsTemp[req.TicketID] = true
-			result["status"] = "ignored_temporarily"
-
-		case "ignore_forever":
-			ignoredTicketsForever[req.TicketID] = true
-			saveIgnoredTickets()
-			result["status"] = "ignored_permanently"
-
-		default:
-			result["status"] = "failed"
-			result["error"] = "Invalid action"
-		}
-
-		results = append(results, result)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "completed",
-		"synced":  synced,
-		"total":   len(requests),
-		"results": results,
-		"note":    "Sync operations now include both status and tag/subsystem updates",
-	})
+	writeJobAccepted(w, job)
 }
 
-func manageIgnoredTicketsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
+func (s *Server) manageIgnoredTicketsHandler(w http.ResponseWriter, r *http.Request) {
+	engine := engineFrom(r.Context())
 	switch r.Method {
 	case "GET":
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"temp_ignored":    getMapKeys(ignoredTicketsTemp),
-			"forever_ignored": getMapKeys(ignoredTicketsForever),
-			"tag_mappings":    defaultTagMapping,
+			"temp_ignored":    engine.IgnoredTemp(),
+			"forever_ignored": engine.IgnoredForever(),
+			"tag_mappings":    engine.Pair().tagMapping(),
 		})
 
 	case "POST":
@@ -684,20 +732,12 @@ func manageIgnoredTicketsHandler(w http.ResponseWriter, r *http.Request) {
 
 		switch req.Action {
 		case "add":
-			if req.Type == "forever" {
-				ignoredTicketsForever[req.TicketID] = true
-				saveIgnoredTickets()
-			} else {
-				ignoredTicketsTemp[req.TicketID] = true
-			}
+			engine.Ignore(req.TicketID, req.Type == "forever")
+			recordIgnore(r.Context(), auditIgnore, req.TicketID, req.Type == "forever")
 
 		case "remove":
-			if req.Type == "forever" {
-				delete(ignoredTicketsForever, req.TicketID)
-				saveIgnoredTickets()
-			} else {
-				delete(ignoredTicketsTemp, req.TicketID)
-			}
+			engine.Unignore(req.TicketID, req.Type == "forever")
+			recordIgnore(r.Context(), auditUnignore, req.TicketID, req.Type == "forever")
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -713,28 +753,22 @@ func manageIgnoredTicketsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Auto-sync control handler
-func autoSyncHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
+func (s *Server) autoSyncHandler(w http.ResponseWriter, r *http.Request) {
+	engine := engineFrom(r.Context())
 	switch r.Method {
 	case "GET":
+		state := engine.AutoSyncState()
+		runStatus := engine.RunStatus()
 		status := AutoSyncStatus{
-			Running:      autoSyncRunning,
-			Interval:     autoSyncInterval,
-			LastSync:     lastSyncTime,
-			SyncCount:    autoSyncCount,
-			LastSyncInfo: autoSyncLastInfo,
-		}
-
-		if autoSyncRunning {
-			status.NextSync = time.Now().Add(time.Duration(autoSyncInterval) * time.Second)
+			Running:      state.Running,
+			Interval:     state.Interval,
+			LastSync:     engine.LastSyncTime(),
+			NextSync:     state.NextRun,
+			SyncCount:    state.Count,
+			LastSyncInfo: state.LastInfo,
+			InProgress:   runStatus.CurrentTrigger == triggerAutoSync,
+			SkippedRuns:  state.Skipped,
+			QueuedRuns:   runStatus.Queued,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -762,33 +796,25 @@ func autoSyncHandler(w http.ResponseWriter, r *http.Request) {
 
 		switch req.Action {
 		case "start":
-			if autoSyncRunning {
+			if !engine.StartAutoSync(req.Interval) {
 				w.Header().Set("Content-Type", "application/json")
 				json.NewEncoder(w).Encode(map[string]interface{}{
 					"status":           "already_running",
 					"message":          "Auto-sync is already running",
-					"current_interval": autoSyncInterval,
+					"current_interval": engine.AutoSyncState().Interval,
 				})
 				return
 			}
 
-			if req.Interval > 0 {
-				autoSyncInterval = req.Interval
-			} else {
-				autoSyncInterval = 15
-			}
-
-			startAutoSync()
-
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"status":   "started",
 				"message":  "Auto-sync started successfully",
-				"interval": autoSyncInterval,
+				"interval": engine.AutoSyncState().Interval,
 			})
 
 		case "stop":
-			if !autoSyncRunning {
+			if !engine.StopAutoSync("stopped by " + actorName(r.Context())) {
 				w.Header().Set("Content-Type", "application/json")
 				json.NewEncoder(w).Encode(map[string]interface{}{
 					"status":  "not_running",
@@ -797,13 +823,11 @@ func autoSyncHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			stopAutoSync()
-
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"status":     "stopped",
 				"message":    "Auto-sync stopped successfully",
-				"sync_count": autoSyncCount,
+				"sync_count": engine.AutoSyncState().Count,
 			})
 
 		default:
@@ -822,27 +846,22 @@ func autoSyncHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Auto-create control handler
-func autoCreateHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
+func (s *Server) autoCreateHandler(w http.ResponseWriter, r *http.Request) {
+	engine := engineFrom(r.Context())
 	switch r.Method {
 	case "GET":
+		state := engine.AutoCreateState()
+		runStatus := engine.RunStatus()
 		status := AutoCreateStatus{
-			Running:        autoCreateRunning,
-			Interval:       autoCreateInterval,
-			CreateCount:    autoCreateCount,
-			LastCreateInfo: autoCreateLastInfo,
-		}
-
-		if autoCreateRunning {
-			status.NextCreate = time.Now().Add(time.Duration(autoCreateInterval) * time.Second)
+			Running:        state.Running,
+			Interval:       state.Interval,
+			LastCreate:     state.LastRun,
+			NextCreate:     state.NextRun,
+			CreateCount:    state.Count,
+			LastCreateInfo: state.LastInfo,
+			InProgress:     runStatus.CurrentTrigger == triggerAutoCreate,
+			SkippedRuns:    state.Skipped,
+			QueuedRuns:     runStatus.Queued,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -870,33 +889,25 @@ func autoCreateHandler(w http.ResponseWriter, r *http.Request) {
 
 		switch req.Action {
 		case "start":
-			if autoCreateRunning {
+			if !engine.StartAutoCreate(req.Interval) {
 				w.Header().Set("Content-Type", "application/json")
 				json.NewEncoder(w).Encode(map[string]interface{}{
 					"status":           "already_running",
 					"message":          "Auto-create is already running",
-					"current_interval": autoCreateInterval,
+					"current_interval": engine.AutoCreateState().Interval,
 				})
 				return
 			}
 
-			if req.Interval > 0 {
-				autoCreateInterval = req.Interval
-			} else {
-				autoCreateInterval = 15
-			}
-
-			startAutoCreate()
-
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"status":   "started",
 				"message":  "Auto-create started successfully",
-				"interval": autoCreateInterval,
+				"interval": engine.AutoCreateState().Interval,
 			})
 
 		case "stop":
-			if !autoCreateRunning {
+			if !engine.StopAutoCreate("stopped by " + actorName(r.Context())) {
 				w.Header().Set("Content-Type", "application/json")
 				json.NewEncoder(w).Encode(map[string]interface{}{
 					"status":  "not_running",
@@ -905,13 +916,11 @@ func autoCreateHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			stopAutoCreate()
-
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"status":       "stopped",
 				"message":      "Auto-create stopped successfully",
-				"create_count": autoCreateCount,
+				"create_count": engine.AutoCreateState().Count,
 			})
 
 		default:
@@ -929,153 +938,98 @@ func autoCreateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Auto-sync functions
-func startAutoSync() {
-	if autoSyncRunning {
-		return
-	}
+// Job queue handlers
+func (s *Server) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	// Routes: /jobs, /jobs/{id}, /jobs/{id}/cancel
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+	parts := strings.Split(path, "/")
 
-	autoSyncRunning = true
-	autoSyncDone = make(chan bool)
-	autoSyncTicker = time.NewTicker(time.Duration(autoSyncInterval) * time.Second)
-
-	fmt.Printf("Auto-sync started with %d second interval\n", autoSyncInterval)
+	if path == "" {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed. Use GET.", http.StatusMethodNotAllowed)
+			return
+		}
 
-	go func() {
-		for {
-			select {
-			case <-autoSyncTicker.C:
-				performAutoSync()
-			case <-autoSyncDone:
-				autoSyncTicker.Stop()
-				fmt.Println("Auto-sync stopped")
-				return
-			}
+		jobs := tenantFrom(r.Context()).jobs.List()
+		for i := range jobs {
+			jobs[i].Results = nil // keep the listing small; fetch /jobs/{id} for details
+			jobs[i].Result = nil
 		}
-	}()
-}
 
-func stopAutoSync() {
-	if !autoSyncRunning {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"jobs":   jobs,
+			"count":  len(jobs),
+		})
 		return
 	}
 
-	autoSyncRunning = false
-	if autoSyncDone != nil {
-		close(autoSyncDone)
-	}
-	if autoSyncTicker != nil {
-		autoSyncTicker.Stop()
-	}
-
-	fmt.Println("Auto-sync stopped")
-}
-
-func performAutoSync() {
-	fmt.Printf("Performing auto-sync #%d...\n", autoSyncCount+1)
-
-	analysis, err := performTicketAnalysis(syncableColumns)
-	if err != nil {
-		autoSyncLastInfo = fmt.Sprintf("Analysis failed: %v", err)
-		fmt.Printf("Auto-sync analysis failed: %v\n", err)
+	jobID := parts[0]
+	cancel := len(parts) == 2 && parts[1] == "cancel"
+	if len(parts) > 2 || (len(parts) == 2 && !cancel) {
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	synced := 0
-	errors := 0
-
-	for _, ticket := range analysis.Mismatched {
-		if isIgnored(ticket.AsanaTask.GID) {
-			continue
-		}
-
-		err := updateYouTrackIssue(ticket.YouTrackIssue.ID, ticket.AsanaTask)
-		if err != nil {
-			fmt.Printf("Auto-sync error updating ticket %s: %v\n", ticket.AsanaTask.GID, err)
-			errors++
-		} else {
-			synced++
+	switch {
+	case r.Method == "GET" && !cancel:
+		job, ok := tenantFrom(r.Context()).jobs.Get(jobID)
+		if !ok {
+			writeJobNotFound(w, jobID)
+			return
 		}
-	}
-
-	autoSyncCount++
-	lastSyncTime = time.Now()
-	autoSyncLastInfo = fmt.Sprintf("Synced: %d, Errors: %d", synced, errors)
 
-	fmt.Printf("Auto-sync #%d completed: %s\n", autoSyncCount, autoSyncLastInfo)
-}
-
-// Auto-create functions
-func startAutoCreate() {
-	if autoCreateRunning {
-		return
-	}
-
-	autoCreateRunning = true
-	autoCreateDone = make(chan bool)
-	autoCreateTicker = time.NewTicker(time.Duration(autoCreateInterval) * time.Second)
-
-	fmt.Printf("Auto-create started with %d second interval\n", autoCreateInterval)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
 
-	go func() {
-		for {
-			select {
-			case <-autoCreateTicker.C:
-				performAutoCreate()
-			case <-autoCreateDone:
-				autoCreateTicker.Stop()
-				fmt.Println("Auto-create stopped")
+	case r.Method == "DELETE" && !cancel, r.Method == "POST" && cancel:
+		job, err := tenantFrom(r.Context()).jobs.Cancel(jobID)
+		if err != nil {
+			if job.ID == "" {
+				writeJobNotFound(w, jobID)
 				return
 			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":  err.Error(),
+				"job_id": jobID,
+				"status": job.Status,
+			})
+			return
 		}
-	}()
-}
 
-func stopAutoCreate() {
-	if !autoCreateRunning {
-		return
-	}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "cancel_requested",
+			"job_id":     job.ID,
+			"job_status": job.Status,
+		})
 
-	autoCreateRunning = false
-	if autoCreateDone != nil {
-		close(autoCreateDone)
-	}
-	if autoCreateTicker != nil {
-		autoCreateTicker.Stop()
+	default:
+		http.Error(w, "Method not allowed. Use GET, DELETE or POST /jobs/{id}/cancel.", http.StatusMethodNotAllowed)
 	}
-
-	fmt.Println("Auto-create stopped")
 }
 
-func performAutoCreate() {
-	fmt.Printf("Performing auto-create #%d...\n", autoCreateCount+1)
-
-	analysis, err := performTicketAnalysis(syncableColumns)
-	if err != nil {
-		autoCreateLastInfo = fmt.Sprintf("Analysis failed: %v", err)
-		fmt.Printf("Auto-create analysis failed: %v\n", err)
-		return
-	}
-
-	created := 0
-	errors := 0
-
-	for _, task := range analysis.MissingYouTrack {
-		if isIgnored(task.GID) || isDuplicateTicket(task.Name) {
-			continue
-		}
-
-		err := createYouTrackIssue(task)
-		if err != nil {
-			fmt.Printf("Auto-create error creating ticket %s: %v\n", task.GID, err)
-			errors++
-		} else {
-			created++
-		}
-	}
-
-	autoCreateCount++
-	autoCreateLastInfo = fmt.Sprintf("Created: %d, Errors: %d", created, errors)
+func writeJobAccepted(w http.ResponseWriter, job *Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "queued",
+		"job_id":     job.ID,
+		"job_type":   job.Type,
+		"pair":       job.Pair,
+		"status_url": "/jobs/" + job.ID,
+	})
+}
 
-	fmt.Printf("Auto-create #%d completed: %s\n", autoCreateCount, autoCreateLastInfo)
+func writeJobNotFound(w http.ResponseWriter, jobID string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Job not found",
+		"job_id": jobID,
+	})
 }
@@ -0,0 +1,786 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Job types
+const (
+	jobTypeCreate = "create"
+	jobTypeSync   = "sync"
+	jobTypeDelete = "delete"
+)
+
+// Job statuses
+const (
+	jobPending   = "pending"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// maxFinishedJobs bounds how many finished jobs are kept in the store
+const maxFinishedJobs = 200
+
+// Job is a unit of queued work. Items are processed one at a time and each
+// item's result is persisted as it finishes, so a restart resumes at
+// Processed.
+type Job struct {
+	ID              string                   `json:"id"`
+	Type            string                   `json:"type"`
+	Pair            string                   `json:"pair,omitempty"`
+	Status          string                   `json:"status"`
+	Payload         json.RawMessage          `json:"payload,omitempty"`
+	Total           int                      `json:"total"`
+	Processed       int                      `json:"processed"`
+	Results         []map[string]interface{} `json:"results"`
+	Result          interface{}              `json:"result,omitempty"`
+	Error           string                   `json:"error,omitempty"`
+	CancelRequested bool                     `json:"cancel_requested,omitempty"`
+	RequestedBy     string                   `json:"requested_by,omitempty"`
+	RequestID       string                   `json:"request_id,omitempty"` // of the request that queued the job
+	CreatedAt       time.Time                `json:"created_at"`
+	StartedAt       *time.Time               `json:"started_at,omitempty"`
+	FinishedAt      *time.Time               `json:"finished_at,omitempty"`
+}
+
+// createJobPayload is filled in on the first run with the tasks that were
+// missing at the time, so a resumed job works through the same list.
+type createJobPayload struct {
+	TaskIDs []string `json:"task_ids"`
+}
+
+// JobQueue is a file-backed FIFO of create/sync/delete jobs executed by a
+// single worker goroutine per tenant. Each job runs on the engine of its
+// project pair. Each job is kept in its own file in dir, with its per-item
+// results appended to a second file, so progress on one job never rewrites
+// the others.
+type JobQueue struct {
+	tenant *Tenant
+	dir    string
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	wake chan struct{}
+	stop chan struct{}
+}
+
+// NewJobQueue loads the queue from dir, creating it if needed. An unreadable
+// job is an error rather than silently dropping it.
+func NewJobQueue(t *Tenant, dir string) (*JobQueue, error) {
+	q := &JobQueue{
+		tenant: t,
+		dir:    dir,
+		jobs:   make(map[string]*Job),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Start launches the worker. Jobs left pending or running by a previous
+// process are picked up first.
+func (q *JobQueue) Start() {
+	go q.worker()
+	q.notify()
+}
+
+func (q *JobQueue) Stop() {
+	close(q.stop)
+}
+
+// jobEndpoints names the endpoint that queues each job type, for audit entries
+var jobEndpoints = map[string]string{
+	jobTypeCreate: "/create",
+	jobTypeSync:   "/sync",
+	jobTypeDelete: "/delete-tickets",
+}
+
+// Enqueue stores a new pending job for the named pair and wakes the worker.
+// requestedBy names the acting user for logs; the job's logs and tracker
+// calls carry the request ID of ctx.
+func (q *JobQueue) Enqueue(ctx context.Context, jobType, pair string, payload interface{}, requestedBy string) (*Job, error) {
+	var raw json.RawMessage
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	}
+
+	job := &Job{
+		ID:          newJobID(),
+		Type:        jobType,
+		Pair:        pair,
+		Status:      jobPending,
+		Payload:     raw,
+		Results:     []map[string]interface{}{},
+		RequestedBy: requestedBy,
+		RequestID:   requestIDFrom(ctx),
+		CreatedAt:   time.Now(),
+	}
+
+	q.mu.Lock()
+	if err := q.saveJobLocked(job); err != nil {
+		q.mu.Unlock()
+		return nil, err
+	}
+	q.jobs[job.ID] = job
+	snapshot := *job
+	q.mu.Unlock()
+
+	slog.InfoContext(ctx, "Job queued", "job_id", job.ID, "type", jobType, "actor", requestedBy)
+	q.notify()
+	return &snapshot, nil
+}
+
+// Get returns a copy of the job, or false if it does not exist.
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return copyJob(job), true
+}
+
+// List returns copies of all jobs, newest first.
+func (q *JobQueue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, copyJob(job))
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Active counts jobs that are pending or running.
+func (q *JobQueue) Active() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, job := range q.jobs {
+		if job.Status == jobPending || job.Status == jobRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// Cancel cancels a pending job immediately; a running job stops after the
+// item currently in progress. Finished jobs cannot be cancelled.
+func (q *JobQueue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("job %s not found", id)
+	}
+
+	switch job.Status {
+	case jobPending:
+		now := time.Now()
+		job.Status = jobCancelled
+		job.FinishedAt = &now
+	case jobRunning:
+		job.CancelRequested = true
+	default:
+		return copyJob(job), fmt.Errorf("job %s is already %s", id, job.Status)
+	}
+
+	if err := q.saveJobLocked(job); err != nil {
+		slog.Error("Could not save job", "dir", q.dir, "job_id", id, "error", err)
+	}
+	q.pruneLocked()
+	return copyJob(job), nil
+}
+
+func (q *JobQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *JobQueue) worker() {
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		}
+
+		for {
+			job := q.next()
+			if job == nil {
+				break
+			}
+			q.execute(job)
+
+			select {
+			case <-q.stop:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// next marks the oldest pending job as running and returns it.
+func (q *JobQueue) next() *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var oldest *Job
+	for _, job := range q.jobs {
+		if job.Status != jobPending {
+			continue
+		}
+		if oldest == nil || job.CreatedAt.Before(oldest.CreatedAt) {
+			oldest = job
+		}
+	}
+	if oldest == nil {
+		return nil
+	}
+
+	now := time.Now()
+	oldest.Status = jobRunning
+	if oldest.StartedAt == nil {
+		oldest.StartedAt = &now
+	}
+	if err := q.saveJobLocked(oldest); err != nil {
+		slog.Error("Could not save job", "dir", q.dir, "job_id", oldest.ID, "error", err)
+	}
+	return oldest
+}
+
+func (q *JobQueue) execute(job *Job) {
+	// Jobs outlive the request that queued them, so they run on their own
+	// context, under that request's ID; cancellation is checked between items.
+	requestID := job.RequestID
+	if requestID == "" {
+		requestID = job.ID
+	}
+	ctx := withAuditContext(withRequestID(withTenant(context.Background(), q.tenant), requestID), AuditContext{
+		Actor:    job.RequestedBy,
+		Trigger:  triggerManual,
+		Endpoint: jobEndpoints[job.Type],
+		JobID:    job.ID,
+	})
+	ctx, span := startSpan(ctx, "job "+job.Type, trace.WithAttributes(
+		attribute.String("job_id", job.ID), attribute.String("request_id", requestID)))
+	var err error
+	defer func() { endSpan(span, err) }()
+	slog.InfoContext(ctx, "Running job", "job_id", job.ID, "type", job.Type, "actor", job.RequestedBy)
+
+	// Jobs queued before pairs existed have no pair and run on the default
+	engine, ok := q.tenant.Pairs().Get(job.Pair)
+	if !ok {
+		err = fmt.Errorf("project pair %q is no longer configured", job.Pair)
+		q.finish(ctx, job, err)
+		return
+	}
+	ctx = withPair(ctx, engine)
+
+	if qerr := engine.RunExclusive(ctx, triggerManual, func() {
+		switch job.Type {
+		case jobTypeCreate:
+			err = q.runCreate(ctx, job)
+		case jobTypeSync:
+			err = q.runSync(ctx, job)
+		case jobTypeDelete:
+			err = q.runDelete(ctx, job)
+		default:
+			err = fmt.Errorf("unknown job type %q", job.Type)
+		}
+	}); qerr != nil {
+		err = fmt.Errorf("waiting for the project: %w", qerr)
+	}
+
+	q.finish(ctx, job, err)
+}
+
+// finish records the final status of a job.
+func (q *JobQueue) finish(ctx context.Context, job *Job, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	job.FinishedAt = &now
+	switch {
+	case err != nil:
+		job.Status = jobFailed
+		job.Error = err.Error()
+	case job.CancelRequested:
+		job.Status = jobCancelled
+	default:
+		job.Status = jobCompleted
+	}
+	if err := q.saveJobLocked(job); err != nil {
+		slog.ErrorContext(ctx, "Could not save job", "dir", q.dir, "job_id", job.ID, "error", err)
+	}
+	q.pruneLocked()
+
+	slog.InfoContext(ctx, "Job finished", "job_id", job.ID, "status", job.Status, "processed", job.Processed, "total", job.Total, "error", job.Error)
+}
+
+// step records the result of one item. It returns false once the job has
+// been asked to cancel, and an error if the progress could not be saved;
+// the job then fails rather than run on without being resumable.
+func (q *JobQueue) step(job *Job, result map[string]interface{}, final interface{}) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.appendResultLocked(job, result); err != nil {
+		return false, fmt.Errorf("save job progress: %w", err)
+	}
+	job.Results = append(job.Results, result)
+	job.Processed++
+	job.Result = final
+	return !job.CancelRequested, nil
+}
+
+func (q *JobQueue) setTotal(job *Job, total int, payload interface{}) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		job.Payload = data
+	}
+	job.Total = total
+	return q.saveJobLocked(job)
+}
+
+func (q *JobQueue) cancelled(job *Job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return job.CancelRequested
+}
+
+// Job runners
+
+func (q *JobQueue) runCreate(ctx context.Context, job *Job) error {
+	engine := engineFrom(ctx)
+	analysis, err := performTicketAnalysis(ctx, engine, currentConfig().Columns.Syncable)
+	if err != nil {
+		return fmt.Errorf("analysis failed: %v", err)
+	}
+
+	missing := make(map[string]AsanaTask, len(analysis.MissingYouTrack))
+	for _, task := range analysis.MissingYouTrack {
+		missing[task.GID] = task
+	}
+
+	var payload createJobPayload
+	if len(job.Payload) > 0 {
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid job payload: %v", err)
+		}
+	} else {
+		for _, task := range analysis.MissingYouTrack {
+			payload.TaskIDs = append(payload.TaskIDs, task.GID)
+		}
+		if err := q.setTotal(job, len(payload.TaskIDs), payload); err != nil {
+			return err
+		}
+	}
+
+	created, skipped := countResults(job.Results, "created", "skipped")
+	for i := job.Processed; i < len(payload.TaskIDs); i++ {
+		if q.cancelled(job) {
+			break
+		}
+
+		taskID := payload.TaskIDs[i]
+		task, ok := missing[taskID]
+		var result map[string]interface{}
+		if !ok {
+			// Created or moved since the job was queued (e.g. before a restart)
+			result = map[string]interface{}{
+				"task_id": taskID,
+				"status":  "skipped",
+				"reason":  "Ticket is no longer missing from YouTrack",
+			}
+		} else {
+			result = createMissingTask(ctx, engine, task)
+		}
+
+		switch result["status"] {
+		case "created":
+			created++
+		case "skipped":
+			skipped++
+		}
+
+		final := map[string]interface{}{
+			"status":  "completed",
+			"created": created,
+			"skipped": skipped,
+			"total":   len(payload.TaskIDs),
+		}
+		more, err := q.step(job, result, final)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+
+	return q.finalize(job, map[string]interface{}{
+		"status":  "completed",
+		"message": createSummaryMessage(len(payload.TaskIDs)),
+		"created": created,
+		"skipped": skipped,
+		"total":   len(payload.TaskIDs),
+	})
+}
+
+func (q *JobQueue) runSync(ctx context.Context, job *Job) error {
+	engine := engineFrom(ctx)
+	var requests []SyncRequest
+	if err := json.Unmarshal(job.Payload, &requests); err != nil {
+		return fmt.Errorf("invalid job payload: %v", err)
+	}
+	if job.Total == 0 {
+		if err := q.setTotal(job, len(requests), nil); err != nil {
+			return err
+		}
+	}
+
+	analysis, err := performTicketAnalysis(ctx, engine, currentConfig().Columns.Syncable)
+	if err != nil {
+		return fmt.Errorf("analysis failed: %v", err)
+	}
+
+	mismatchMap := make(map[string]MismatchedTicket)
+	for _, ticket := range analysis.Mismatched {
+		mismatchMap[ticket.AsanaTask.GID] = ticket
+	}
+
+	synced, _ := countResults(job.Results, "synced", "")
+	for i := job.Processed; i < len(requests); i++ {
+		if q.cancelled(job) {
+			break
+		}
+
+		result := syncTicketRequest(ctx, engine, requests[i], mismatchMap)
+		if result["status"] == "synced" {
+			synced++
+		}
+
+		final := map[string]interface{}{
+			"status": "completed",
+			"synced": synced,
+			"total":  len(requests),
+		}
+		more, err := q.step(job, result, final)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+
+	engine.MarkSynced()
+	return q.finalize(job, map[string]interface{}{
+		"status": "completed",
+		"synced": synced,
+		"total":  len(requests),
+		"note":   "Sync operations now include both status and tag/subsystem updates",
+	})
+}
+
+func (q *JobQueue) runDelete(ctx context.Context, job *Job) error {
+	var req DeleteTicketsRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return fmt.Errorf("invalid job payload: %v", err)
+	}
+	if job.Total == 0 {
+		if err := q.setTotal(job, len(req.TicketIDs), nil); err != nil {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "Starting bulk delete", "job_id", job.ID, "tickets", len(req.TicketIDs), "source", req.Source)
+
+	for i := job.Processed; i < len(req.TicketIDs); i++ {
+		if q.cancelled(job) {
+			break
+		}
+
+		result := deleteTicket(ctx, req.TicketIDs[i], req.Source, req.Strategy)
+		more, err := q.step(job, deleteResultMap(result), nil)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+
+	q.mu.Lock()
+	results := make([]DeleteResult, 0, len(job.Results))
+	for _, r := range job.Results {
+		results = append(results, deleteResultFromMap(r))
+	}
+	q.mu.Unlock()
+
+	response := summarizeDeleteResults(req.Source, len(req.TicketIDs), results)
+	q.mu.Lock()
+	job.Result = response
+	err := q.saveJobLocked(job)
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Bulk delete completed", "job_id", job.ID, "summary", response.Summary)
+	return nil
+}
+
+// finalize stores the response body returned to clients once the job ends.
+// It carries the per-item results in the same shape the inline endpoints
+// used to return.
+func (q *JobQueue) finalize(job *Job, result map[string]interface{}) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result["results"] = job.Results
+	job.Result = result
+	return q.saveJobLocked(job)
+}
+
+// Persistence
+
+const jobResultsSuffix = ".results.jsonl"
+
+func (q *JobQueue) jobFile(id string) string {
+	return filepath.Join(q.dir, id+".json")
+}
+
+func (q *JobQueue) resultsFile(id string) string {
+	return filepath.Join(q.dir, id+jobResultsSuffix)
+}
+
+func (q *JobQueue) load() error {
+	if err := os.MkdirAll(q.dir, 0700); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("read job store: %w", err)
+	}
+	resumed := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		job, err := q.loadJob(filepath.Join(q.dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		// A job that was running when the process stopped resumes where it left off
+		if job.Status == jobRunning {
+			job.Status = jobPending
+		}
+		if job.Status == jobPending {
+			resumed++
+		}
+		q.jobs[job.ID] = job
+	}
+
+	if resumed > 0 {
+		slog.Info("Resuming queued jobs", "jobs", resumed, "dir", q.dir)
+	}
+	return nil
+}
+
+// loadJob reads one job and its results. A last result cut short by a crash
+// is dropped, so that item runs again; anything else unreadable is an error.
+func (q *JobQueue) loadJob(file string) (*Job, error) {
+	corrupt := func(file string, err error) error {
+		return fmt.Errorf("job file %s is corrupt (repair it or move it aside to drop the job): %w", file, err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read job: %w", err)
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, corrupt(file, err)
+	}
+
+	results := q.resultsFile(job.ID)
+	data, err = os.ReadFile(results)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read job results: %w", err)
+	}
+	job.Results = []map[string]interface{}{}
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			if i == len(lines)-1 && !strings.HasSuffix(line, "\n") {
+				slog.Warn("Dropping a job result that was cut short", "file", results, "job_id", job.ID)
+				break
+			}
+			return nil, corrupt(results, err)
+		}
+		job.Results = append(job.Results, result)
+	}
+	job.Processed = len(job.Results)
+	return &job, nil
+}
+
+// saveJobLocked writes the job without its results, which appendResultLocked
+// keeps, replacing the file atomically. Must be called with q.mu held.
+func (q *JobQueue) saveJobLocked(job *Job) error {
+	stored := *job
+	stored.Results = nil
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file := q.jobFile(job.ID)
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// appendResultLocked adds one item's result to the job's results file. Must
+// be called with q.mu held.
+func (q *JobQueue) appendResultLocked(job *Job, result map[string]interface{}) error {
+	line, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(q.resultsFile(job.ID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedJobs.
+func (q *JobQueue) pruneLocked() {
+	var finished []*Job
+	for _, job := range q.jobs {
+		if job.FinishedAt != nil {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(*finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(q.jobs, job.ID)
+		for _, file := range []string{q.jobFile(job.ID), q.resultsFile(job.ID)} {
+			if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Error("Could not remove pruned job", "file", file, "error", err)
+			}
+		}
+	}
+}
+
+// Helpers
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func copyJob(job *Job) Job {
+	c := *job
+	c.Results = make([]map[string]interface{}, len(job.Results))
+	copy(c.Results, job.Results)
+	return c
+}
+
+func countResults(results []map[string]interface{}, a, b string) (int, int) {
+	countA, countB := 0, 0
+	for _, r := range results {
+		switch r["status"] {
+		case a:
+			countA++
+		case b:
+			countB++
+		}
+	}
+	return countA, countB
+}
+
+func createSummaryMessage(total int) string {
+	if total == 0 {
+		return "No missing tickets to create"
+	}
+	return fmt.Sprintf("Processed %d missing tickets", total)
+}
+
+// deleteResultMap and deleteResultFromMap convert between DeleteResult and
+// the generic per-item result stored on jobs.
+func deleteResultMap(result DeleteResult) map[string]interface{} {
+	data, _ := json.Marshal(result)
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	return m
+}
+
+func deleteResultFromMap(m map[string]interface{}) DeleteResult {
+	data, _ := json.Marshal(m)
+	var result DeleteResult
+	json.Unmarshal(data, &result)
+	return result
+}
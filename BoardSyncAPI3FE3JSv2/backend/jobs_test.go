@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testJobQueue is the job queue of a tenant with one pair and no tracker
+// access. Delete jobs without ticket IDs run through the whole queue without
+// a tracker call.
+func testJobQueue(t *testing.T) *JobQueue {
+	t.Helper()
+	useTestConfig(t)
+	tn, err := newTenant(TenantRecord{
+		ID:      "jobs",
+		DataDir: t.TempDir(),
+		Pairs:   []ProjectPair{{Name: defaultPairName, AsanaProjectID: "A1", YouTrackProjectID: "YT"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tn.audit.Close() })
+	return tn.jobs
+}
+
+// reloadJobQueue opens the job store in dir as a restarted process would
+func reloadJobQueue(t *testing.T, tn *Tenant, dir string) *JobQueue {
+	t.Helper()
+	q, err := NewJobQueue(tn, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func emptyDeleteJob(t *testing.T, q *JobQueue) Job {
+	t.Helper()
+	job, err := q.Enqueue(context.Background(), jobTypeDelete, "", DeleteTicketsRequest{TicketIDs: []string{}, Source: "asana"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *job
+}
+
+// startDeleteJob queues a delete job for ticketIDs and marks it running
+// without running it, as the worker would before the first item.
+func startDeleteJob(t *testing.T, q *JobQueue, ticketIDs ...string) *Job {
+	t.Helper()
+	queued, err := q.Enqueue(context.Background(), jobTypeDelete, "", DeleteTicketsRequest{TicketIDs: ticketIDs, Source: "asana"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := q.next()
+	if job == nil || job.ID != queued.ID {
+		t.Fatalf("next() = %v, want job %s", job, queued.ID)
+	}
+	if err := q.setTotal(job, len(ticketIDs), nil); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func deletedResult(ticketID string) map[string]interface{} {
+	return map[string]interface{}{"ticket_id": ticketID, "status": "success"}
+}
+
+// waitForJob polls until the job's status satisfies done
+func waitForJob(t *testing.T, q *JobQueue, id string, done func(Job) bool) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := q.Get(id)
+		if !ok {
+			t.Fatalf("job %s disappeared", id)
+		}
+		if done(job) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s stuck in %s", id, job.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func finished(job Job) bool { return job.FinishedAt != nil }
+
+func TestJobQueueCancelPendingAndRunningJobs(t *testing.T) {
+	q := testJobQueue(t)
+
+	// Hold the project so the first job stays running and the second pending
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go q.tenant.Pairs().Default().RunExclusive(context.Background(), triggerManual, func() {
+		close(held)
+		<-release
+	})
+	<-held
+	q.Start()
+	t.Cleanup(q.Stop)
+
+	running := emptyDeleteJob(t, q)
+	waitForJob(t, q, running.ID, func(j Job) bool { return j.Status == jobRunning })
+	pending := emptyDeleteJob(t, q)
+
+	if job, err := q.Cancel(pending.ID); err != nil || job.Status != jobCancelled {
+		t.Fatalf("cancel pending: status %s, err %v; want cancelled", job.Status, err)
+	}
+	if job, err := q.Cancel(running.ID); err != nil || !job.CancelRequested {
+		t.Fatalf("cancel running: %+v, err %v; want cancel requested", job, err)
+	}
+	close(release)
+
+	if job := waitForJob(t, q, running.ID, finished); job.Status != jobCancelled {
+		t.Fatalf("running job ended %s, want cancelled", job.Status)
+	}
+	if job, _ := q.Get(pending.ID); job.StartedAt != nil {
+		t.Fatalf("cancelled pending job was started")
+	}
+	if _, err := q.Cancel(running.ID); err == nil {
+		t.Fatalf("cancelling a finished job succeeded")
+	}
+}
+
+func TestJobQueueConcurrentEnqueueAndCancel(t *testing.T) {
+	q := testJobQueue(t)
+	q.Start()
+	t.Cleanup(q.Stop)
+
+	var wg sync.WaitGroup
+	ids := make(chan string, 40)
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			job := emptyDeleteJob(t, q)
+			ids <- job.ID
+			if i%3 == 0 {
+				q.Cancel(job.ID) // may lose to the worker; either outcome is fine
+			}
+			q.List()
+			q.Active()
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	for id := range ids {
+		job := waitForJob(t, q, id, finished)
+		if job.Status != jobCompleted && job.Status != jobCancelled {
+			t.Fatalf("job %s ended %s (%s)", id, job.Status, job.Error)
+		}
+	}
+	if n := q.Active(); n != 0 {
+		t.Fatalf("Active() = %d after every job finished", n)
+	}
+}
+
+func TestJobQueueResumesJobsAfterRestart(t *testing.T) {
+	q := testJobQueue(t)
+	queued := emptyDeleteJob(t, q)
+
+	// A job marked running when the process stopped is picked up again
+	q.mu.Lock()
+	q.jobs[queued.ID].Status = jobRunning
+	if err := q.saveJobLocked(q.jobs[queued.ID]); err != nil {
+		t.Fatal(err)
+	}
+	q.mu.Unlock()
+
+	q = reloadJobQueue(t, q.tenant, q.dir)
+	if job, ok := q.Get(queued.ID); !ok || job.Status != jobPending {
+		t.Fatalf("reloaded job = %+v, want pending", job)
+	}
+	q.Start()
+	t.Cleanup(q.Stop)
+	if job := waitForJob(t, q, queued.ID, finished); job.Status != jobCompleted {
+		t.Fatalf("resumed job ended %s (%s), want completed", job.Status, job.Error)
+	}
+}
+
+func TestJobQueueStepStopsOnceCancelled(t *testing.T) {
+	q := testJobQueue(t)
+	job := startDeleteJob(t, q, "T1", "T2", "T3")
+
+	if more, err := q.step(job, deletedResult("T1"), nil); err != nil || !more {
+		t.Fatalf("first step = %v, %v; want more", more, err)
+	}
+	if _, err := q.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if more, err := q.step(job, deletedResult("T2"), nil); err != nil || more {
+		t.Fatalf("step after cancel = %v, %v; want to stop", more, err)
+	}
+	if got, _ := q.Get(job.ID); got.Processed != 2 || len(got.Results) != 2 {
+		t.Fatalf("job processed %d items, want 2", got.Processed)
+	}
+}
+
+func TestJobQueueResumesInterruptedJobAtProcessed(t *testing.T) {
+	q := testJobQueue(t)
+	job := startDeleteJob(t, q, "T1", "T2", "T3", "T4")
+	for _, id := range []string{"T1", "T2"} {
+		if _, err := q.step(job, deletedResult(id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The store now holds what a crash during T3 would leave behind
+	reloaded, ok := reloadJobQueue(t, q.tenant, q.dir).Get(job.ID)
+	if !ok || reloaded.Status != jobPending || reloaded.Processed != 2 || reloaded.Total != 4 {
+		t.Fatalf("reloaded job = %s after %d of %d items, want pending after 2 of 4", reloaded.Status, reloaded.Processed, reloaded.Total)
+	}
+	if id, _ := reloaded.Results[1]["ticket_id"].(string); id != "T2" {
+		t.Fatalf("last reloaded result is for %q, want T2", id)
+	}
+}
+
+func TestJobQueueFailsJobWhenProgressCannotBeSaved(t *testing.T) {
+	q := testJobQueue(t)
+	job := startDeleteJob(t, q, "T1", "T2")
+
+	// A directory in place of the results file makes every save fail
+	if err := os.Mkdir(q.resultsFile(job.ID), 0700); err != nil {
+		t.Fatal(err)
+	}
+	more, err := q.step(job, deletedResult("T1"), nil)
+	if more || err == nil || !strings.Contains(err.Error(), "save job progress") {
+		t.Fatalf("step = %v, %v; want a save error", more, err)
+	}
+	if got, _ := q.Get(job.ID); got.Processed != 0 {
+		t.Fatalf("unsaved item counted as processed (%d)", got.Processed)
+	}
+}
+
+func TestJobQueueAppendsProgressWithoutRewritingJobs(t *testing.T) {
+	q := testJobQueue(t)
+	job := startDeleteJob(t, q, "T1", "T2", "T3")
+	other := emptyDeleteJob(t, q)
+
+	// The other job's file is only written when that job changes
+	otherFile := q.jobFile(other.ID)
+	before, err := os.Stat(otherFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"T1", "T2", "T3"} {
+		if _, err := q.step(job, deletedResult(id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after, err := os.Stat(otherFile); err != nil || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("another job's file was rewritten while this job ran (%v)", err)
+	}
+
+	data, err := os.ReadFile(q.resultsFile(job.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("results file has %d lines, want one per item", lines)
+	}
+	stored, err := os.ReadFile(q.jobFile(job.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(stored), `"ticket_id"`) {
+		t.Error("the job file repeats the per-item results")
+	}
+}
+
+func TestJobQueueDropsResultCutShortByCrash(t *testing.T) {
+	q := testJobQueue(t)
+	job := startDeleteJob(t, q, "T1", "T2")
+
+	// One complete result and half of the next
+	results := `{"ticket_id":"T1","status":"success"}` + "\n" + `{"ticket_id":"T2","sta`
+	if err := os.WriteFile(q.resultsFile(job.ID), []byte(results), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, _ := reloadJobQueue(t, q.tenant, q.dir).Get(job.ID); reloaded.Processed != 1 || len(reloaded.Results) != 1 {
+		t.Fatalf("reloaded job processed %d items, want 1", reloaded.Processed)
+	}
+
+	// A broken result before the last one is not a crash artefact
+	results = `{"ticket_id":"T1","sta` + "\n" + `{"ticket_id":"T2","status":"success"}` + "\n"
+	if err := os.WriteFile(q.resultsFile(job.ID), []byte(results), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJobQueue(q.tenant, q.dir); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("NewJobQueue error = %v, want a corrupt job error", err)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logging goes through log/slog with the level and format of the logging
+// section of config.yaml. Records pick up the request ID, tenant and pair of
+// their context, and are redacted on the way out: tracker credentials (see
+// secrets.go), bearer tokens, credential fields and e-mail addresses.
+
+// Log formats
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logLevel is shared by every handler so a reload can change it in place
+var logLevel = new(slog.LevelVar)
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	switch strings.ToLower(s) {
+	case "debug", "info", "warn", "error":
+		return level, level.UnmarshalText([]byte(s))
+	}
+	return level, fmt.Errorf("must be debug, info, warn or error, got %q", s)
+}
+
+// setupLogging installs the default logger. The standard log package is
+// routed through it too, at info level.
+func setupLogging(level, format string) {
+	setLogLevel(level)
+	slog.SetDefault(slog.New(newLogHandler(os.Stderr, format)))
+}
+
+// newLogHandler formats records for w, masking secrets and PII
+func newLogHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redactLogAttr}
+	out := redactingWriter{w}
+	if format == logFormatJSON {
+		return contextHandler{slog.NewJSONHandler(out, opts)}
+	}
+	return contextHandler{slog.NewTextHandler(out, opts)}
+}
+
+func setLogLevel(level string) {
+	if l, err := parseLogLevel(level); err == nil {
+		logLevel.Set(l)
+	}
+}
+
+// fatal logs at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the request ID, tenant, pair and trace of the
+// record's context as attributes.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := requestIDFrom(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if t := tenantFrom(ctx); t != nil {
+			r.AddAttrs(slog.String("tenant", t.ID()))
+		}
+		if e, ok := ctx.Value(pairContextKey{}).(*SyncEngine); ok {
+			r.AddAttrs(slog.String("pair", e.pair.Name))
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// PII and credentials that are not registered secrets: tokens of callers,
+// credential fields echoed in tracker responses, and e-mail addresses.
+var (
+	bearerPattern     = regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+	credentialPattern = regexp.MustCompile(`(?i)("(?:[a-z_]*token|password|secret|api_key|apikey)"\s*:\s*)"[^"]*"`)
+	emailPattern      = regexp.MustCompile(`\b([A-Za-z0-9])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})\b`)
+)
+
+// redactPII masks what the patterns above match. E-mail addresses keep
+// their first letter and domain, so log lines stay tellable apart.
+func redactPII(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redactedText)
+	s = credentialPattern.ReplaceAllString(s, `${1}"`+redactedText+`"`)
+	return emailPattern.ReplaceAllString(s, "${1}***@${2}")
+}
+
+// redactLogAttr applies redactPII to the message and every string or error
+// attribute.
+func redactLogAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redactPII(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redactPII(err.Error()))
+		}
+	}
+	return a
+}
+
+// Request IDs
+
+// requestIDHeader carries the correlation ID on requests, responses and
+// outgoing tracker calls.
+const requestIDHeader = "X-Request-ID"
+
+type requestIDContextKey struct{}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// requestIDFrom returns the request ID in ctx, or ""
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// requestIDs gives every request an ID, reusing a well-formed X-Request-ID
+// from the caller, and returns it in the response header. Completed
+// requests are logged at debug level.
+func requestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := withRequestID(r.Context(), id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		slog.DebugContext(ctx, "Request completed",
+			"method", r.Method, "path", r.URL.Path, "status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds())
+	})
+}
+
+// statusRecorder remembers the status code written through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
@@ -1,103 +1,149 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
-
-	"github.com/joho/godotenv"
+	"strings"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
+	redactStandardLogger()
 	loadConfig()
 
-	// Verify YouTrack connection
-	projectKey, err := findYouTrackProject()
+	secrets, err := newSecretsProvider(currentConfig().SecretsBackend)
 	if err != nil {
-		log.Printf("Error with YouTrack project: %v", err)
-		log.Println("Finding correct project...")
-		listYouTrackProjects()
-		return
-	}
-
-	if projectKey != config.YouTrackProjectID {
-		log.Printf("Found correct project key: %s", projectKey)
-		log.Printf("Please update your .env file:")
-		log.Printf("   Change YOUTRACK_PROJECT_ID=%s", config.YouTrackProjectID)
-		log.Printf("   To YOUTRACK_PROJECT_ID=%s", projectKey)
-		log.Println("   Then restart the service.")
-		return
-	}
-
-	log.Println("YouTrack connection verified!")
-
-	// Setup HTTP handlers ONLY
-	http.HandleFunc("/health", healthCheck)
-	http.HandleFunc("/status", statusCheck)
-	http.HandleFunc("/analyze", analyzeTicketsHandler)
-	http.HandleFunc("/create-single", createSingleTicketHandler)
-	http.HandleFunc("/create", createMissingTicketsHandler)
-	http.HandleFunc("/sync", syncMismatchedTicketsHandler)
-	http.HandleFunc("/ignore", manageIgnoredTicketsHandler)
-	http.HandleFunc("/auto-sync", autoSyncHandler)
-	http.HandleFunc("/auto-create", autoCreateHandler)
-	http.HandleFunc("/tickets", getTicketsByTypeHandler)
-	http.HandleFunc("/delete-tickets", deleteTicketsHandler)
-
-	// Log startup info
-	log.Printf("Enhanced Asana-YouTrack Sync Service v3.2")
-	log.Printf("Server starting on port %s", config.Port)
-	log.Printf("Service URL: https://boardsyncapi.onrender.com")
-	log.Println("Service Status: READY - HTTP Server Only")
-	log.Printf("Listening on port %s...", config.Port)
-
-	// Start HTTP server - BLOCKING CALL ONLY
-	log.Fatal(http.ListenAndServe(":"+config.Port, nil))
-}
+		fatal("SECRETS_BACKEND", "error", err)
+	}
+	slog.Info("Tracker credentials stored in the secrets backend", "backend", secrets.Backend())
 
-func loadConfig() {
-	// Load .env only for local dev - Render uses environment variables
-	if os.Getenv("RENDER") == "" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Println("Note: .env file not found, using environment variables")
+	tenants, err := NewTenantRegistry("tenants.json", secrets)
+	if err != nil {
+		fatal("Failed to load tenants", "error", err)
+	}
+	if len(tenants.IDs()) == 0 {
+		// First start: carry the tracker env vars over into a default tenant
+		if rec, ok := seedTenantRecord(); ok {
+			if _, err := tenants.Create(rec); err != nil {
+				fatal("Failed to create default tenant from environment", "error", err)
+			}
+			slog.Info("Created tenant from environment; manage it through /admin/tenants from now on", "tenant", defaultTenantID)
+		} else {
+			slog.Warn("No tenants configured. Create one with POST /admin/tenants.")
 		}
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	for _, t := range tenants.List() {
+		slog.Info("Tenant loaded", "tenant", t.ID(), "pairs", strings.Join(t.Pairs().Names(), ", "))
 	}
+	// Check tokens, projects and YouTrack fields of every tenant without
+	// holding up startup; problems are logged with how to fix them.
+	go logStartupDiagnostics(tenants)
+	tenants.StartAll()
 
-	config = Config{
-		Port:              port,
-		SyncServiceAPIKey: getEnv("SYNC_SERVICE_API_KEY", ""),
-		AsanaPAT:          getEnv("ASANA_PAT", ""),
-		AsanaProjectID:    getEnv("ASANA_PROJECT_ID", ""),
-		YouTrackBaseURL:   getEnv("YOUTRACK_BASE_URL", ""),
-		YouTrackToken:     getEnv("YOUTRACK_TOKEN", ""),
-		YouTrackProjectID: getEnv("YOUTRACK_PROJECT_ID", ""),
+	keys, err := NewKeyStore("api_keys.json", currentConfig().SyncServiceAPIKey)
+	if err != nil {
+		fatal("Failed to load API keys", "error", err)
+	}
+	if !keys.Configured() {
+		slog.Warn("No API keys configured; set SYNC_SERVICE_API_KEY. All requests except /health will be rejected.")
+	}
+	verifier, devIssuer := setupOIDC()
+	server := NewServer(tenants, keys, verifier)
+	guard := server.guard
+	forTenant := server.forTenant
+	forPair := func(h http.HandlerFunc) http.HandlerFunc { return forTenant(server.forPair(h)) }
+
+	// Setup HTTP handlers ONLY; required roles are listed in roles.go.
+	// forTenant routes take ?tenant=<id> (or X-Tenant) unless the caller is
+	// bound to a tenant; forPair routes also take ?pair=<name> and default to
+	// the tenant's first pair.
+	http.HandleFunc("/health", guard("/health", server.healthCheck))
+	http.HandleFunc("/status", guard("/status", server.statusCheck))
+	http.HandleFunc("/whoami", guard("/whoami", server.whoamiHandler))
+	http.HandleFunc("/analyze", guard("/analyze", forPair(server.analyzeTicketsHandler)))
+	http.HandleFunc("/create-single", guard("/create-single", forPair(server.createSingleTicketHandler)))
+	http.HandleFunc("/create", guard("/create", forPair(server.createMissingTicketsHandler)))
+	http.HandleFunc("/sync", guard("/sync", forPair(server.syncMismatchedTicketsHandler)))
+	http.HandleFunc("/ignore", guard("/ignore", forPair(server.manageIgnoredTicketsHandler)))
+	http.HandleFunc("/auto-sync", guard("/auto-sync", forPair(server.autoSyncHandler)))
+	http.HandleFunc("/auto-create", guard("/auto-create", forPair(server.autoCreateHandler)))
+	http.HandleFunc("/tickets", guard("/tickets", forPair(server.getTicketsByTypeHandler)))
+	http.HandleFunc("/delete-tickets", guard("/delete-tickets", forPair(server.deleteTicketsHandler)))
+	http.HandleFunc("/delete-tickets/preview", guard("/delete-tickets/preview", forPair(server.deletePreviewHandler)))
+	http.HandleFunc("/jobs", guard("/jobs", forTenant(server.jobsHandler)))
+	http.HandleFunc("/jobs/", guard("/jobs", forTenant(server.jobsHandler)))
+	http.HandleFunc("/admin/api-keys", guard("/admin/api-keys", server.apiKeysHandler))
+	http.HandleFunc("/admin/api-keys/", guard("/admin/api-keys", server.apiKeysHandler))
+	http.HandleFunc("/admin/tenants", guard("/admin/tenants", server.tenantsHandler))
+	http.HandleFunc("/admin/tenants/", guard("/admin/tenants", server.tenantsHandler))
+	http.HandleFunc("/admin/secrets", guard("/admin/secrets", forTenant(server.secretsHandler)))
+	http.HandleFunc("/admin/config", guard("/admin/config", server.configHandler))
+	http.HandleFunc("/admin/config/", guard("/admin/config", server.configHandler))
+	http.HandleFunc("/provisioning", guard("/provisioning", forPair(server.provisioningHandler)))
+	http.HandleFunc("/diagnostics", guard("/diagnostics", forTenant(server.diagnosticsHandler)))
+	http.HandleFunc("/metrics", guard("/metrics", server.metricsHandler))
+	http.HandleFunc("/alerts", guard("/alerts", forTenant(server.alertsHandler)))
+	http.HandleFunc("/audit", guard("/audit", forTenant(server.auditHandler)))
+	http.HandleFunc("/trash", guard("/trash", forTenant(server.trashHandler)))
+	http.HandleFunc("/trash/", guard("/trash", forTenant(server.trashHandler)))
+	http.HandleFunc("/restore", guard("/restore", forTenant(server.restoreHandler)))
+	http.HandleFunc("/webhooks/asana", guard("/webhooks/asana", forTenant(server.asanaWebhookHandler)))
+	http.HandleFunc("/webhooks/asana/register", guard("/webhooks/asana/register", forPair(server.asanaWebhookRegisterHandler)))
+	http.HandleFunc("/webhooks/youtrack", guard("/webhooks/youtrack", forTenant(server.youTrackWebhookHandler)))
+	if devIssuer != nil {
+		http.Handle(devIssuerPath+"/", devIssuer)
 	}
 
-	pollInterval, err := strconv.Atoi(getEnv("POLL_INTERVAL_MS", "60000"))
+	// kill -HUP reloads config.yaml and the environment, like POST /admin/config/reload
+	watchSIGHUP()
+
+	// Log startup info
+	slog.Info("Enhanced Asana-YouTrack Sync Service v3.2",
+		"port", currentConfig().Port,
+		"url", "https://boardsyncapi.onrender.com",
+		"cors_origins", strings.Join(currentConfig().CORS.AllowedOrigins, ", "),
+		"log_level", currentConfig().LogLevel)
+	slog.Info("Service Status: READY - HTTP Server Only")
+
+	// Start HTTP server - BLOCKING CALL ONLY
+	handler := requestIDs(corsMiddleware(currentConfig().CORS, redactResponses(http.DefaultServeMux)))
+	err = http.ListenAndServe(":"+currentConfig().Port, handler)
+	shutdownTracing(context.Background())
+	fatal("HTTP server stopped", "error", err)
+}
+
+// shutdownTracing flushes spans still buffered for export
+var shutdownTracing = func(context.Context) error { return nil }
+
+// loadConfig builds the configuration from config.yaml and the environment
+// (see config.go) and exits listing every problem if it does not validate.
+func loadConfig() {
+	loadDotEnv()
+
+	file, required := configFile()
+	c, err := buildConfig(file, required)
 	if err != nil {
-		pollInterval = 60000 // default
+		fatal("Invalid configuration", "file", file, "problems", strings.Split(err.Error(), "\n"))
 	}
-	config.PollIntervalMS = pollInterval
-
-	// Validate required environment variables
-	if config.AsanaPAT == "" || config.AsanaProjectID == "" ||
-		config.YouTrackBaseURL == "" || config.YouTrackToken == "" ||
-		config.YouTrackProjectID == "" {
-		log.Fatal("Missing required environment variables. Please check your configuration:\n" +
-			"   Required: ASANA_PAT, ASANA_PROJECT_ID, YOUTRACK_BASE_URL, YOUTRACK_TOKEN, YOUTRACK_PROJECT_ID")
+	setConfig(c)
+	setupLogging(c.LogLevel, c.LogFormat)
+	shutdown, err := setupTracing(c.Tracing)
+	if err != nil {
+		fatal("Failed to set up tracing", "exporter", c.Tracing.Exporter, "error", err)
 	}
+	shutdownTracing = shutdown
+	configureTrackerLimits(c.AsanaRateLimitPerMin, c.YouTrackRateLimitPerMin)
 
-	// Load ignored tickets from file
-	loadIgnoredTickets()
-
-	log.Println("Configuration loaded successfully")
+	// Tracker credentials and project pairs live in tenants.json. The
+	// trackers and project_pairs settings only seed the default tenant when
+	// no tenants exist yet.
+	slog.Info("Configuration loaded", "file", file)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -108,3 +154,46 @@ func getEnv(key, defaultValue string) string {
 }
 
 //new
+
+// setupOIDC builds the bearer token verifier, and the local dev issuer when
+// OIDC_DEV_ISSUER=true. Both are nil when OIDC is not configured.
+func setupOIDC() (*OIDCVerifier, *DevIssuer) {
+	if currentConfig().OIDCIssuer == "" {
+		return nil, nil
+	}
+
+	roleMap, err := parseRoleMap(currentConfig().OIDCRoleMap)
+	if err != nil {
+		fatal("OIDC_ROLE_MAP", "error", err)
+	}
+	defaultRole := Role(currentConfig().OIDCDefaultRole)
+	if defaultRole != "" && !defaultRole.Valid() {
+		fatal("OIDC_DEFAULT_ROLE: unknown role", "role", defaultRole)
+	}
+
+	verifier := NewOIDCVerifier(OIDCConfig{
+		Issuer:      currentConfig().OIDCIssuer,
+		Audience:    currentConfig().OIDCAudience,
+		JWKSURL:     currentConfig().OIDCJWKSURL,
+		RoleClaim:   currentConfig().OIDCRoleClaim,
+		UserClaim:   currentConfig().OIDCUserClaim,
+		TenantClaim: currentConfig().OIDCTenantClaim,
+		RoleMap:     roleMap,
+		DefaultRole: defaultRole,
+	})
+	slog.Info("OIDC bearer tokens accepted", "issuer", currentConfig().OIDCIssuer, "role_claim", currentConfig().OIDCRoleClaim)
+
+	if !currentConfig().OIDCDevIssuer {
+		return verifier, nil
+	}
+
+	devIssuer, err := NewDevIssuer(currentConfig().OIDCIssuer, currentConfig().OIDCAudience)
+	if err != nil {
+		fatal("Failed to start dev OIDC issuer", "error", err)
+	}
+	if err := devIssuer.Attach(verifier); err != nil {
+		fatal("Failed to start dev OIDC issuer", "error", err)
+	}
+	slog.Warn("Dev OIDC issuer enabled - it signs tokens for anyone; never use in production", "issuer", currentConfig().OIDCIssuer)
+	return verifier, devIssuer
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Prometheus metrics, served at /metrics by the client_golang handler.
+// Series are labelled with the tenant (and pair) of the context they were
+// recorded in; callers bound to a tenant only see that tenant's series.
+
+var (
+	durationBuckets = []float64{0.5, 1, 2.5, 5, 10, 20, 40, 80, 160}
+	latencyBuckets  = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+	analysisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "boardsync_analysis_duration_seconds", Help: "Time to analyse a project pair.", Buckets: durationBuckets,
+	}, []string{"tenant", "pair"})
+	analysisTickets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "boardsync_analysis_tickets", Help: "Tickets per bucket in the last analysis of a pair.",
+	}, []string{"tenant", "pair", "bucket"})
+	analysisLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "boardsync_analysis_last_success_timestamp_seconds", Help: "Unix time of the last successful analysis of a pair.",
+	}, []string{"tenant", "pair"})
+	analysisFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "boardsync_analysis_failures_total", Help: "Analyses that could not read a tracker.",
+	}, []string{"tenant", "pair", "reason"})
+
+	ticketOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "boardsync_ticket_operations_total", Help: "Ticket creates, syncs and deletes by outcome and failure reason.",
+	}, []string{"tenant", "pair", "operation", "outcome", "reason"})
+
+	trackerRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "boardsync_tracker_requests_total", Help: "Tracker API requests by status code (\"error\" when no response), retries included.",
+	}, []string{"tenant", "tracker", "method", "code"})
+	trackerRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "boardsync_tracker_request_duration_seconds", Help: "Tracker API request latency.", Buckets: latencyBuckets,
+	}, []string{"tenant", "tracker", "method"})
+
+	autoRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "boardsync_auto_runs_total", Help: "Auto-sync and auto-create runs by result (completed, failed or skipped).",
+	}, []string{"tenant", "pair", "runner", "result"})
+	autoRunLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "boardsync_auto_run_last_success_timestamp_seconds", Help: "Unix time of the last auto run that completed.",
+	}, []string{"tenant", "pair", "runner"})
+
+	alertsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "boardsync_alerts_total", Help: "Alerts by channel and outcome (sent or failed); suppressed ones were inside their quiet period.",
+	}, []string{"tenant", "kind", "channel", "outcome"})
+
+	// metricsRegistry holds everything /metrics serves
+	metricsRegistry = prometheus.NewRegistry()
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		analysisDuration, analysisTickets, analysisLastSuccess, analysisFailures,
+		ticketOperations, trackerRequests, trackerRequestDuration,
+		autoRuns, autoRunLastSuccess, alertsSent,
+		retryCollector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// metricScope returns the tenant and pair labels for ctx
+func metricScope(ctx context.Context) (string, string) {
+	tenant, pair := "", ""
+	if t := tenantFrom(ctx); t != nil {
+		tenant = t.ID()
+	}
+	if e, ok := ctx.Value(pairContextKey{}).(*SyncEngine); ok {
+		pair = e.pair.Name
+	}
+	return tenant, pair
+}
+
+// failureReason is a short label for why an operation failed
+func failureReason(err error) string {
+	var apiErr *APIError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrValidation):
+		return "validation"
+	case errors.Is(err, errNoSuchField):
+		return "missing_field"
+	case errors.Is(err, errDuplicateTicket):
+		return "duplicate"
+	case errors.Is(err, errDisplayOnlyColumn):
+		return "display_only"
+	case errors.As(err, &apiErr) && apiErr.StatusCode >= 500:
+		return "server_error"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+	return "other"
+}
+
+func observeAnalysis(ctx context.Context, started time.Time, analysis *TicketAnalysis, err error) {
+	tenant, pair := metricScope(ctx)
+	if err != nil {
+		analysisFailures.WithLabelValues(tenant, pair, failureReason(err)).Inc()
+		return
+	}
+	analysisDuration.WithLabelValues(tenant, pair).Observe(time.Since(started).Seconds())
+	analysisLastSuccess.WithLabelValues(tenant, pair).SetToCurrentTime()
+	for bucket, n := range map[string]int{
+		"matched":           len(analysis.Matched),
+		"mismatched":        len(analysis.Mismatched),
+		"missing_youtrack":  len(analysis.MissingYouTrack),
+		"orphaned_youtrack": len(analysis.OrphanedYouTrack),
+		"findings":          len(analysis.FindingsTickets),
+		"findings_alerts":   len(analysis.FindingsAlerts),
+		"ready_for_stage":   len(analysis.ReadyForStage),
+		"blocked":           len(analysis.BlockedTickets),
+		"ignored":           len(analysis.Ignored),
+	} {
+		analysisTickets.WithLabelValues(tenant, pair, bucket).Set(float64(n))
+	}
+}
+
+// observeTicketOperation counts a create, sync or delete. outcome is
+// success, failed, partial or skipped.
+func observeTicketOperation(ctx context.Context, operation, outcome string, err error) {
+	tenant, pair := metricScope(ctx)
+	ticketOperations.WithLabelValues(tenant, pair, operation, outcome, failureReason(err)).Inc()
+}
+
+func observeTrackerRequest(ctx context.Context, tracker, method string, resp *http.Response, took time.Duration) {
+	tenant, _ := metricScope(ctx)
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	trackerRequests.WithLabelValues(tenant, tracker, method, code).Inc()
+	trackerRequestDuration.WithLabelValues(tenant, tracker, method).Observe(took.Seconds())
+}
+
+// observeAutoRun counts a run of runner (auto-sync or auto-create)
+func observeAutoRun(ctx context.Context, runner, result string) {
+	tenant, pair := metricScope(ctx)
+	autoRuns.WithLabelValues(tenant, pair, runner, result).Inc()
+	if result == "completed" {
+		autoRunLastSuccess.WithLabelValues(tenant, pair, runner).SetToCurrentTime()
+	}
+}
+
+var (
+	trackerRetriesDesc = prometheus.NewDesc("boardsync_tracker_retries_total",
+		"Tracker requests retried.", []string{"tenant", "tracker"}, nil)
+	trackerGaveUpDesc = prometheus.NewDesc("boardsync_tracker_gave_up_total",
+		"Tracker requests that failed after the last retry.", []string{"tenant", "tracker"}, nil)
+)
+
+// retryCollector exports the retry counters kept by the limiters in retry.go
+type retryCollector struct{}
+
+func (retryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- trackerRetriesDesc
+	ch <- trackerGaveUpDesc
+}
+
+func (retryCollector) Collect(ch chan<- prometheus.Metric) {
+	for tenant, trackers := range trackerRetryStatsByTenant() {
+		for tracker, stats := range trackers {
+			ch <- prometheus.MustNewConstMetric(trackerRetriesDesc, prometheus.CounterValue, float64(stats.Retries), tenant, tracker)
+			ch <- prometheus.MustNewConstMetric(trackerGaveUpDesc, prometheus.CounterValue, float64(stats.GaveUp), tenant, tracker)
+		}
+	}
+}
+
+// tenantGatherer keeps the series labelled with one tenant. Families
+// without a tenant label, like the Go runtime ones, are service-level and
+// dropped.
+type tenantGatherer struct {
+	prometheus.Gatherer
+	tenant string
+}
+
+func (g tenantGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	out := families[:0]
+	for _, family := range families {
+		var kept []*dto.Metric
+		for _, m := range family.Metric {
+			for _, label := range m.Label {
+				if label.GetName() == "tenant" && label.GetValue() == g.tenant {
+					kept = append(kept, m)
+					break
+				}
+			}
+		}
+		if len(kept) > 0 {
+			family.Metric = kept
+			out = append(out, family)
+		}
+	}
+	return out, err
+}
+
+// Metrics handler: GET /metrics in the Prometheus exposition format. Callers
+// bound to a tenant get that tenant's series only.
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use GET.")
+		return
+	}
+
+	var gatherer prometheus.Gatherer = metricsRegistry
+	if bound := principalFrom(r.Context()).Tenant; bound != "" {
+		gatherer = tenantGatherer{Gatherer: metricsRegistry, tenant: bound}
+	}
+	// Uncompressed, so redactResponses still sees the text
+	promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{DisableCompression: true}).ServeHTTP(w, r)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrapeMetrics(t *testing.T, boundTo string) string {
+	t.Helper()
+	ctx := withPrincipal(context.Background(), &Principal{Name: "scraper", Role: roleViewer, Tenant: boundTo})
+	rec := httptest.NewRecorder()
+	(&Server{}).metricsHandler(rec, httptest.NewRequest("GET", "/metrics", nil).WithContext(ctx))
+	if rec.Code != 200 {
+		t.Fatalf("GET /metrics: status %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetricsHandlerScopesBoundCallersToTheirTenant(t *testing.T) {
+	for _, id := range []string{"metrics-a", "metrics-b"} {
+		ctx := withTenant(context.Background(), &Tenant{id: id})
+		observeTicketOperation(ctx, auditCreate, "success", nil)
+		limiterFor(id, trackerAsana, "https://app.asana.com").stats.addRetry()
+	}
+
+	all := scrapeMetrics(t, "")
+	for _, want := range []string{`tenant="metrics-a"`, `tenant="metrics-b"`, "boardsync_tracker_retries_total", "go_goroutines"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("unbound scrape misses %s", want)
+		}
+	}
+
+	bound := scrapeMetrics(t, "metrics-a")
+	if !strings.Contains(bound, `boardsync_ticket_operations_total{operation="create",outcome="success",pair="",reason="",tenant="metrics-a"} `) {
+		t.Errorf("bound scrape misses its own series:\n%s", bound)
+	}
+	if !strings.Contains(bound, `boardsync_tracker_retries_total{tenant="metrics-a",tracker="asana"} `) {
+		t.Errorf("bound scrape misses its retry counter:\n%s", bound)
+	}
+	for _, unwanted := range []string{`tenant="metrics-b"`, "go_goroutines", "process_"} {
+		if strings.Contains(bound, unwanted) {
+			t.Errorf("bound scrape includes %s", unwanted)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig configures bearer token validation
+type OIDCConfig struct {
+	Issuer    string
+	Audience  string
+	JWKSURL   string // discovered from the issuer when empty
+	RoleClaim string // claim holding role names or groups, e.g. "roles" or "groups"
+	UserClaim string // claim naming the user in logs, e.g. "email"
+	// TenantClaim binds tokens to the tenant named in that claim. Tokens
+	// without it are rejected. Empty disables tenant binding.
+	TenantClaim string
+	// RoleMap maps claim values (such as IdP group names) to roles. Values
+	// that are already role names map to themselves.
+	RoleMap     map[string]Role
+	DefaultRole Role // role for valid tokens without a mapped claim; empty denies
+	CacheTTL    time.Duration
+}
+
+const (
+	jwtLeeway          = 60 * time.Second
+	jwksMinRefreshWait = 30 * time.Second
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// OIDCVerifier validates JWT bearer tokens against an issuer's JWKS. Keys
+// are cached for CacheTTL and refetched early when a token names an unknown
+// key ID, at most once every jwksMinRefreshWait.
+type OIDCVerifier struct {
+	cfg  OIDCConfig
+	http *http.Client
+
+	// fetchKeys loads the current key set; replaced by the dev issuer so it
+	// does not need to call itself over HTTP.
+	fetchKeys func(ctx context.Context) (map[string]*rsa.PublicKey, error)
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	jwksURL     string
+}
+
+func NewOIDCVerifier(cfg OIDCConfig) *OIDCVerifier {
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "roles"
+	}
+	if cfg.UserClaim == "" {
+		cfg.UserClaim = "email"
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = time.Hour
+	}
+	v := &OIDCVerifier{
+		cfg:     cfg,
+		http:    &http.Client{Timeout: 10 * time.Second},
+		jwksURL: cfg.JWKSURL,
+	}
+	v.fetchKeys = v.fetchJWKS
+	return v
+}
+
+// Verify checks the token's signature and registered claims and maps it to
+// a principal. A valid token whose claims grant no role yields a principal
+// with an empty role, which every guarded route rejects with 403.
+func (v *OIDCVerifier) Verify(ctx context.Context, raw string) (*Principal, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed JWT", errInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", errInvalidToken, err)
+	}
+
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported alg %q", errInvalidToken, header.Alg)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", errInvalidToken)
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), signature); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", errInvalidToken)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", errInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	name, _ := claims[v.cfg.UserClaim].(string)
+	if name == "" {
+		name = subject
+	}
+
+	principal := &Principal{
+		KeyID:      "oidc:" + subject,
+		Name:       name,
+		Role:       v.roleFor(claims),
+		AuthMethod: authMethodOIDC,
+		Subject:    subject,
+	}
+	if v.cfg.TenantClaim != "" {
+		// Without the claim the caller would be treated as unbound and see
+		// every tenant, so a missing or malformed claim fails the token.
+		tenant, _ := claims[v.cfg.TenantClaim].(string)
+		if tenant == "" {
+			return nil, fmt.Errorf("%w: missing %q claim", errInvalidToken, v.cfg.TenantClaim)
+		}
+		principal.Tenant = tenant
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		t := time.Unix(int64(exp), 0)
+		principal.ExpiresAt = &t
+	}
+	return principal, nil
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+func decodeJWTPart(part string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// normalizeIssuer drops trailing slashes, so "https://idp/" and "https://idp"
+// name the same issuer
+func normalizeIssuer(issuer string) string {
+	return strings.TrimRight(issuer, "/")
+}
+
+func (v *OIDCVerifier) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+
+	if iss, _ := claims["iss"].(string); normalizeIssuer(iss) != normalizeIssuer(v.cfg.Issuer) {
+		return fmt.Errorf("%w: issuer %q is not trusted", errInvalidToken, iss)
+	}
+
+	if v.cfg.Audience != "" {
+		found := false
+		for _, aud := range claimStrings(claims["aud"]) {
+			if aud == v.cfg.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: audience does not include %q", errInvalidToken, v.cfg.Audience)
+		}
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: missing exp", errInvalidToken)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return fmt.Errorf("%w: token expired", errInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("%w: token not valid yet", errInvalidToken)
+	}
+	return nil
+}
+
+// roleFor returns the highest role granted by the role claim.
+func (v *OIDCVerifier) roleFor(claims map[string]interface{}) Role {
+	var best Role
+	for _, value := range claimStrings(claims[v.cfg.RoleClaim]) {
+		role, ok := v.cfg.RoleMap[value]
+		if !ok {
+			role = Role(value)
+		}
+		if role.Valid() && !best.Allows(role) {
+			best = role
+		}
+	}
+	if best == "" {
+		best = v.cfg.DefaultRole
+	}
+	return best
+}
+
+// claimStrings reads a claim that may be a string, a space-separated
+// string (like "scope") or an array of strings.
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// key returns the public key for kid, refreshing the cache when it is stale
+// or does not know the key.
+func (v *OIDCVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fresh := time.Since(v.fetchedAt) < v.cfg.CacheTTL
+	if key, ok := v.lookupLocked(kid); ok && fresh {
+		return key, nil
+	}
+
+	if !fresh || time.Since(v.lastAttempt) >= jwksMinRefreshWait {
+		v.lastAttempt = time.Now()
+		keys, err := v.fetchKeys(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "JWKS refresh failed", "error", err)
+		} else {
+			v.keys = keys
+			v.fetchedAt = time.Now()
+		}
+	}
+
+	// A stale key is still better than rejecting every user while the IdP is down
+	if key, ok := v.lookupLocked(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", errInvalidToken, kid)
+}
+
+func (v *OIDCVerifier) lookupLocked(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// fetchJWKS downloads the key set, discovering its URL from the issuer's
+// openid-configuration on first use.
+func (v *OIDCVerifier) fetchJWKS(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		wellKnown := normalizeIssuer(v.cfg.Issuer) + "/.well-known/openid-configuration"
+		if err := v.getJSON(ctx, wellKnown, &discovery); err != nil {
+			return nil, fmt.Errorf("OIDC discovery: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("OIDC discovery at %s has no jwks_uri", wellKnown)
+		}
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	var set jwkSet
+	if err := v.getJSON(ctx, v.jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	return set.rsaKeys(), nil
+}
+
+func (v *OIDCVerifier) getJSON(ctx context.Context, target string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jwkSet is a JSON Web Key Set; only RSA signing keys are used
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (s jwkSet) rsaKeys() map[string]*rsa.PublicKey {
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range s.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// bearerToken returns the token from "Authorization: Bearer <token>"
+func bearerToken(r *http.Request) string {
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
+
+// parseRoleMap parses "group=role,other-group=role"
+func parseRoleMap(spec string) (map[string]Role, error) {
+	roles := make(map[string]Role)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		value, role, ok := strings.Cut(pair, "=")
+		if !ok || !Role(strings.TrimSpace(role)).Valid() {
+			return nil, fmt.Errorf("invalid role mapping %q (want claim-value=%s)", pair, strings.Join(roleNames(), "|"))
+		}
+		roles[strings.TrimSpace(value)] = Role(strings.TrimSpace(role))
+	}
+	return roles, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIssuerURL = "http://localhost:8080/dev-oidc"
+
+// testIssuer returns a dev issuer and a verifier that trusts it, configured
+// by cfg
+func testIssuer(t *testing.T, cfg OIDCConfig) (*DevIssuer, *OIDCVerifier) {
+	t.Helper()
+	issuer, err := NewDevIssuer(testIssuerURL, "boardsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = testIssuerURL
+	}
+	cfg.Audience = "boardsync"
+	v := NewOIDCVerifier(cfg)
+	if err := issuer.Attach(v); err != nil {
+		t.Fatal(err)
+	}
+	return issuer, v
+}
+
+func signTest(t *testing.T, issuer *DevIssuer, claims map[string]interface{}) string {
+	t.Helper()
+	token, err := issuer.Sign(claims, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestOIDCVerifierTenantClaim(t *testing.T) {
+	issuer, v := testIssuer(t, OIDCConfig{TenantClaim: "tenant", DefaultRole: roleViewer})
+
+	tests := []struct {
+		name   string
+		tenant interface{} // nil leaves the claim out
+		want   string
+	}{
+		{"bound", "acme", "acme"},
+		{"missing", nil, ""},
+		{"empty", "", ""},
+		{"not a string", []interface{}{"acme"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{"sub": "alice"}
+			if tt.tenant != nil {
+				claims["tenant"] = tt.tenant
+			}
+			token, err := issuer.Sign(claims, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			principal, err := v.Verify(context.Background(), token)
+			if tt.want == "" {
+				if !errors.Is(err, errInvalidToken) {
+					t.Fatalf("err = %v, want the token rejected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Tenant != tt.want {
+				t.Fatalf("tenant = %q, want %q", principal.Tenant, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCVerifierRejectsTamperedTokens(t *testing.T) {
+	issuer, v := testIssuer(t, OIDCConfig{DefaultRole: roleViewer})
+	token := signTest(t, issuer, map[string]interface{}{"sub": "alice", "roles": []string{"viewer"}})
+	parts := strings.Split(token, ".")
+
+	// Claims swapped for an admin's under the original signature
+	forged, _ := json.Marshal(map[string]interface{}{
+		"iss": testIssuerURL, "aud": "boardsync", "sub": "alice", "roles": []string{"admin"},
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	other, err := NewDevIssuer(testIssuerURL, "boardsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"forged claims":   parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2],
+		"no signature":    parts[0] + "." + parts[1] + ".",
+		"alg none":        encode(map[string]string{"alg": "none", "kid": devIssuerKeyID}) + "." + parts[1] + ".",
+		"alg HS256":       encode(map[string]string{"alg": "HS256", "kid": devIssuerKeyID}) + "." + parts[1] + "." + parts[2],
+		"other key":       signTest(t, other, map[string]interface{}{"sub": "alice"}),
+		"unknown kid":     encode(map[string]string{"alg": "RS256", "kid": "other"}) + "." + parts[1] + "." + parts[2],
+		"two parts":       parts[0] + "." + parts[1],
+		"garbage payload": parts[0] + ".!!!." + parts[2],
+	}
+	for name, token := range tests {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, errInvalidToken) {
+			t.Errorf("%s: err = %v, want the token rejected", name, err)
+		}
+	}
+
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("untouched token rejected: %v", err)
+	}
+}
+
+func TestOIDCVerifierRegisteredClaims(t *testing.T) {
+	issuer, v := testIssuer(t, OIDCConfig{DefaultRole: roleViewer})
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		valid  bool
+	}{
+		{"fresh", map[string]interface{}{}, true},
+		{"expired within leeway", map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}, true},
+		{"expired past leeway", map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()}, false},
+		{"no exp", map[string]interface{}{"exp": nil}, false},
+		{"nbf within leeway", map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()}, true},
+		{"nbf past leeway", map[string]interface{}{"nbf": now.Add(2 * time.Minute).Unix()}, false},
+		{"audience list", map[string]interface{}{"aud": []string{"other", "boardsync"}}, true},
+		{"wrong audience", map[string]interface{}{"aud": "other"}, false},
+		{"no audience", map[string]interface{}{"aud": nil}, false},
+		{"wrong issuer", map[string]interface{}{"iss": "https://evil.test"}, false},
+	}
+	for _, tt := range tests {
+		claims := map[string]interface{}{"sub": "alice"}
+		for k, value := range tt.claims {
+			claims[k] = value
+		}
+		_, err := v.Verify(context.Background(), signTest(t, issuer, claims))
+		if tt.valid && err != nil {
+			t.Errorf("%s: %v, want the token accepted", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, errInvalidToken) {
+			t.Errorf("%s: err = %v, want the token rejected", tt.name, err)
+		}
+	}
+}
+
+func TestOIDCVerifierRoleFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		claim       interface{} // value of "groups"; nil leaves it out
+		defaultRole Role
+		want        Role
+	}{
+		{"mapped group", []string{"eng"}, "", roleOperator},
+		{"highest wins", []string{"eng", "viewer", "sre"}, "", roleAdmin},
+		{"role names map to themselves", []string{"viewer"}, "", roleViewer},
+		{"space separated", "staff eng", "", roleOperator},
+		{"unknown values ignored", []string{"sales", "owner"}, "", ""},
+		{"default for unmapped", []string{"sales"}, roleViewer, roleViewer},
+		{"default without claim", nil, roleViewer, roleViewer},
+		{"no role without default", nil, "", ""},
+	}
+	for _, tt := range tests {
+		issuer, v := testIssuer(t, OIDCConfig{
+			RoleClaim:   "groups",
+			RoleMap:     map[string]Role{"eng": roleOperator, "sre": roleAdmin},
+			DefaultRole: tt.defaultRole,
+		})
+		claims := map[string]interface{}{"sub": "alice", "email": "alice@example.com"}
+		if tt.claim != nil {
+			claims["groups"] = tt.claim
+		}
+		principal, err := v.Verify(context.Background(), signTest(t, issuer, claims))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if principal.Role != tt.want {
+			t.Errorf("%s: role = %q, want %q", tt.name, principal.Role, tt.want)
+		}
+		if principal.Name != "alice@example.com" || principal.Subject != "alice" {
+			t.Errorf("%s: principal named %q (sub %q)", tt.name, principal.Name, principal.Subject)
+		}
+	}
+}
+
+func TestDevIssuerTokenCarriesTenantClaim(t *testing.T) {
+	issuer, v := testIssuer(t, OIDCConfig{TenantClaim: "org", DefaultRole: roleViewer})
+
+	mint := func(body string) (int, string) {
+		rec := httptest.NewRecorder()
+		issuer.ServeHTTP(rec, httptest.NewRequest("POST", "/dev-oidc/token", strings.NewReader(body)))
+		var resp struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp.AccessToken
+	}
+
+	code, token := mint(`{"sub":"alice","roles":["operator"],"tenant":"acme"}`)
+	if code != 200 {
+		t.Fatalf("/token status %d", code)
+	}
+	principal, err := v.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Tenant != "acme" || principal.Role != roleOperator {
+		t.Fatalf("principal tenant %q role %q, want acme operator", principal.Tenant, principal.Role)
+	}
+
+	if code, _ := mint(`{"sub":"alice"}`); code != 400 {
+		t.Fatalf("/token without tenant: status %d, want 400", code)
+	}
+}
+
+func TestDevIssuerOnlyRunsLocally(t *testing.T) {
+	tests := []struct {
+		issuer string
+		ok     bool
+	}{
+		{"http://localhost:8080/dev-oidc", true},
+		{"http://localhost:8080/dev-oidc/", true},
+		{"http://127.0.0.1:3000/dev-oidc", true},
+		{"http://[::1]:8080/dev-oidc", true},
+		{"https://login.example.com", false},
+		{"https://login.example.com/dev-oidc", false},
+		{"http://localhost:8080/oidc", false},
+		{"http://localhost.example.com/dev-oidc", false},
+		{"http://user@localhost:8080/dev-oidc", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if _, err := NewDevIssuer(tt.issuer, "boardsync"); (err == nil) != tt.ok {
+			t.Errorf("NewDevIssuer(%q) error = %v, want ok: %v", tt.issuer, err, tt.ok)
+		}
+	}
+
+	// A verifier for a real identity provider never trusts the dev key
+	issuer, err := NewDevIssuer(testIssuerURL, "boardsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewOIDCVerifier(OIDCConfig{Issuer: "https://login.example.com", Audience: "boardsync"})
+	if err := issuer.Attach(v); err == nil {
+		t.Error("the dev issuer attached to a verifier for another issuer")
+	}
+}
+
+func TestVerifyIgnoresTrailingSlashOfIssuer(t *testing.T) {
+	issuer, v := testIssuer(t, OIDCConfig{Issuer: testIssuerURL + "/", DefaultRole: roleViewer})
+	for _, iss := range []string{testIssuerURL, testIssuerURL + "/"} {
+		token := signTest(t, issuer, map[string]interface{}{"iss": iss, "sub": "alice"})
+		if _, err := v.Verify(context.Background(), token); err != nil {
+			t.Errorf("iss %q: %v", iss, err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ProjectPair is one Asana project kept in sync with one YouTrack project.
+// Each pair has its own engine: ignore lists, auto-sync/auto-create
+// schedules, tracker cache and sync cursors.
+type ProjectPair struct {
+	Name               string            `json:"name" yaml:"name"`
+	AsanaProjectID     string            `json:"asana_project_id" yaml:"asana_project_id"`
+	YouTrackProjectID  string            `json:"youtrack_project_id" yaml:"youtrack_project_id"`
+	TagMapping         map[string]string `json:"tag_mapping,omitempty" yaml:"tag_mapping,omitempty"`                   // Asana tag -> Subsystem; defaults to tag_mapping in config.yaml
+	AutoSyncInterval   int               `json:"auto_sync_interval,omitempty" yaml:"auto_sync_interval,omitempty"`     // seconds; 0 leaves auto-sync off at startup
+	AutoCreateInterval int               `json:"auto_create_interval,omitempty" yaml:"auto_create_interval,omitempty"` // seconds; 0 leaves auto-create off at startup
+}
+
+// defaultPairName is the pair built from ASANA_PROJECT_ID and
+// YOUTRACK_PROJECT_ID when no pairs file exists.
+const defaultPairName = "default"
+
+// slugPattern is what pair names and tenant IDs must look like
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func (p ProjectPair) tagMapping() map[string]string {
+	if len(p.TagMapping) > 0 {
+		return p.TagMapping
+	}
+	return currentConfig().TagMapping
+}
+
+// ignoreFile is relative to the tenant's data directory. The default pair
+// keeps the original file name so existing ignore lists carry over.
+func (p ProjectPair) ignoreFile() string {
+	if p.Name == defaultPairName {
+		return "ignored_tickets.json"
+	}
+	return "ignored_tickets." + p.Name + ".json"
+}
+
+// loadProjectPairs reads a JSON list of pairs. A missing file returns no
+// pairs and no error.
+func loadProjectPairs(file string) ([]ProjectPair, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []ProjectPair
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return pairs, validatePairs(pairs)
+}
+
+func validatePairs(pairs []ProjectPair) error {
+	if len(pairs) == 0 {
+		return fmt.Errorf("at least one project pair is required")
+	}
+	seen := make(map[string]bool)
+	for i, p := range pairs {
+		switch {
+		case !slugPattern.MatchString(p.Name):
+			return fmt.Errorf("pair %d: name %q must be lowercase letters, digits, - or _", i+1, p.Name)
+		case seen[p.Name]:
+			return fmt.Errorf("pair %q is defined twice", p.Name)
+		case p.AsanaProjectID == "" || p.YouTrackProjectID == "":
+			return fmt.Errorf("pair %q needs asana_project_id and youtrack_project_id", p.Name)
+		case p.AutoSyncInterval < 0 || p.AutoCreateInterval < 0:
+			return fmt.Errorf("pair %q: intervals cannot be negative", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return nil
+}
+
+// PairRegistry holds the engine of every configured pair. The first pair is
+// the default for requests that do not name one.
+type PairRegistry struct {
+	order   []string
+	engines map[string]*SyncEngine
+}
+
+// NewPairRegistry builds one engine per pair of the tenant. Engines share
+// the tenant's run coordinator, so pairs that target the same YouTrack
+// project never run at the same time.
+func NewPairRegistry(t *Tenant, pairs []ProjectPair) *PairRegistry {
+	reg := &PairRegistry{engines: make(map[string]*SyncEngine)}
+	for _, p := range pairs {
+		reg.engines[p.Name] = newPairEngine(t, p)
+		reg.order = append(reg.order, p.Name)
+	}
+	return reg
+}
+
+func newPairEngine(t *Tenant, p ProjectPair) *SyncEngine {
+	e := NewSyncEngine(p, filepath.Join(t.dir, p.ignoreFile()), t.cursors, t.runs)
+	e.tenant = t
+	e.cache = NewTrackerCache(
+		time.Duration(currentConfig().CacheTTLSeconds)*time.Second,
+		time.Duration(currentConfig().CacheFullRefreshMinutes)*time.Minute,
+	)
+	return e
+}
+
+// Rebuild returns a registry for pairs that keeps the engine of every pair
+// whose definition is unchanged, along with its running schedules, ignore
+// lists and proposals. The engines of changed or removed pairs are returned
+// as retired for the caller to stop; those of changed or new pairs as added,
+// for the caller to start.
+func (r *PairRegistry) Rebuild(t *Tenant, pairs []ProjectPair) (next *PairRegistry, retired, added []*SyncEngine) {
+	next = &PairRegistry{engines: make(map[string]*SyncEngine)}
+	for _, p := range pairs {
+		e, ok := r.engines[p.Name]
+		if !ok || !reflect.DeepEqual(e.pair, p) {
+			e = newPairEngine(t, p)
+			added = append(added, e)
+		}
+		next.engines[p.Name] = e
+		next.order = append(next.order, p.Name)
+	}
+	for _, name := range r.order {
+		if r.engines[name] != next.engines[name] {
+			retired = append(retired, r.engines[name])
+		}
+	}
+	return next, retired, added
+}
+
+// Get returns the named pair's engine; an empty name means the default.
+func (r *PairRegistry) Get(name string) (*SyncEngine, bool) {
+	if name == "" {
+		return r.Default(), true
+	}
+	e, ok := r.engines[name]
+	return e, ok
+}
+
+func (r *PairRegistry) Default() *SyncEngine {
+	return r.engines[r.order[0]]
+}
+
+func (r *PairRegistry) Names() []string {
+	return append([]string(nil), r.order...)
+}
+
+func (r *PairRegistry) All() []*SyncEngine {
+	engines := make([]*SyncEngine, 0, len(r.order))
+	for _, name := range r.order {
+		engines = append(engines, r.engines[name])
+	}
+	return engines
+}
+
+// StartSchedules starts the auto runs configured for each pair.
+func (r *PairRegistry) StartSchedules() {
+	for _, e := range r.All() {
+		e.StartSchedules()
+	}
+}
+
+func (r *PairRegistry) StopAll(reason string) {
+	for _, e := range r.All() {
+		e.StopAll(reason)
+	}
+}
+
+// MarkDirty invalidates tracker in every pair's cache. Tracker writes do not
+// say which project they touched, so all pairs refresh.
+func (r *PairRegistry) MarkDirty(tracker string) {
+	if r == nil {
+		return
+	}
+	for _, e := range r.engines {
+		e.cache.MarkDirty(tracker)
+	}
+}
+
+// RequestFullRefresh makes every pair refetch all of tracker on its next read
+func (r *PairRegistry) RequestFullRefresh(tracker string) {
+	if r == nil {
+		return
+	}
+	for _, e := range r.engines {
+		e.cache.RequestFullRefresh(tracker)
+	}
+}
+
+// ForgetAsanaTask drops a task from the caches of pairs on asanaProject, or
+// of every pair when asanaProject is empty.
+func (r *PairRegistry) ForgetAsanaTask(asanaProject, gid string) {
+	if r == nil {
+		return
+	}
+	for _, e := range r.engines {
+		if asanaProject == "" || e.pair.AsanaProjectID == asanaProject {
+			e.cache.ForgetAsanaTask(gid)
+		}
+	}
+}
+
+func (r *PairRegistry) ForgetYouTrackIssue(id string) {
+	if r == nil {
+		return
+	}
+	for _, e := range r.engines {
+		e.cache.ForgetYouTrackIssue(id)
+	}
+}
+
+// Pair context
+
+type pairContextKey struct{}
+
+// withPair scopes work in ctx to the engine's project pair, so the code that
+// calls the trackers picks the right projects without extra parameters.
+func withPair(ctx context.Context, e *SyncEngine) context.Context {
+	return context.WithValue(ctx, pairContextKey{}, e)
+}
+
+// engineFrom returns the engine of the pair in ctx, or the default pair of
+// the tenant in ctx.
+func engineFrom(ctx context.Context) *SyncEngine {
+	if e, ok := ctx.Value(pairContextKey{}).(*SyncEngine); ok {
+		return e
+	}
+	return tenantFrom(ctx).Pairs().Default()
+}
+
+func pairFrom(ctx context.Context) ProjectPair {
+	return engineFrom(ctx).pair
+}
+
+// forPair resolves the ?pair= parameter among the pairs of the request's
+// tenant and puts the pair's engine on the request context. It runs inside
+// forTenant.
+func (s *Server) forPair(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pairs := tenantFrom(r.Context()).Pairs()
+		name := r.URL.Query().Get("pair")
+		e, ok := pairs.Get(name)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "unknown_pair",
+				fmt.Sprintf("Unknown project pair %q. Known pairs: %s.", name, strings.Join(pairs.Names(), ", ")))
+			return
+		}
+		next(w, r.WithContext(withPair(r.Context(), e)))
+	}
+}
+
+// pairStatus is one pair's entry under "pairs" in /status
+func pairStatus(e *SyncEngine) map[string]interface{} {
+	autoSync := e.AutoSyncState()
+	autoCreate := e.AutoCreateState()
+
+	return map[string]interface{}{
+		"name":             e.pair.Name,
+		"asana_project":    e.pair.AsanaProjectID,
+		"youtrack_project": e.pair.YouTrackProjectID,
+		"last_sync":        e.LastSyncTime().Format(time.RFC3339),
+		"temp_ignored":     len(e.IgnoredTemp()),
+		"forever_ignored":  len(e.IgnoredForever()),
+		"tag_mappings":     len(e.pair.tagMapping()),
+		"auto_sync": map[string]interface{}{
+			"running":   autoSync.Running,
+			"interval":  autoSync.Interval,
+			"count":     autoSync.Count,
+			"skipped":   autoSync.Skipped,
+			"last_info": autoSync.LastInfo,
+		},
+		"auto_create": map[string]interface{}{
+			"running":   autoCreate.Running,
+			"interval":  autoCreate.Interval,
+			"count":     autoCreate.Count,
+			"skipped":   autoCreate.Skipped,
+			"last_info": autoCreate.LastInfo,
+		},
+		"runs":         e.RunStatus(),
+		"cache":        e.cache.Stats(),
+		"sync_cursors": e.SyncCursors(),
+	}
+}
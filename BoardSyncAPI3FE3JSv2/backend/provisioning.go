@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bundle value provisioning (opt-in with provisioning.bundle_values): analysis
+// notes State and Subsystem values that tickets need but the YouTrack project
+// lacks, and proposes adding them. Nothing changes in YouTrack until an admin
+// approves a proposal with POST /provisioning.
+
+// BundleProposal is a value to add to a YouTrack field's bundle
+type BundleProposal struct {
+	ID         string    `json:"id"` // "<field>:<value>"
+	Field      string    `json:"field"`
+	Value      string    `json:"value"`
+	Tickets    []string  `json:"asana_tickets"` // tasks that need the value
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// provisionableBundles maps a field's fieldType.id base onto its bundle
+// type in the admin API; only these get values added.
+var provisionableBundles = map[string]string{
+	"enum":       "enum",
+	"ownedField": "ownedField",
+	"state":      "state",
+	"version":    "version",
+	"build":      "build",
+}
+
+// bundleHasName is like HasValue, but counts archived values: those need
+// unarchiving in YouTrack, not a second value with the same name.
+func bundleHasName(f YouTrackProjectField, name string) bool {
+	if f.Bundle == nil {
+		return true
+	}
+	for _, v := range f.Bundle.Values {
+		if strings.EqualFold(v.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectMissingBundleValues records proposals for the values the analysed
+// creates and syncs need. It does nothing unless provisioning is enabled or
+// when the project's fields could not be read.
+func detectMissingBundleValues(ctx context.Context, engine *SyncEngine, analysis *TicketAnalysis) {
+	if !currentConfig().ProvisionBundleValues {
+		return
+	}
+	schema := pairFieldSchema(ctx)
+	if schema.assumed {
+		return
+	}
+
+	found := make(map[string]*BundleProposal)
+	need := func(field, value, taskID string) {
+		f, ok := schema.fields[field]
+		if value == "" || !ok || bundleHasName(f, value) {
+			return
+		}
+		if _, base, err := kindOf(f); err != nil || provisionableBundles[base] == "" {
+			return
+		}
+		id := field + ":" + value
+		if found[id] == nil {
+			found[id] = &BundleProposal{ID: id, Field: field, Value: value}
+		}
+		found[id].Tickets = append(found[id].Tickets, taskID)
+	}
+	check := func(task AsanaTask) {
+		need("State", mapAsanaStateToYouTrack(task), task.GID)
+		if tags := getAsanaTags(task); len(tags) > 0 {
+			need("Subsystem", mapTagToSubsystem(ctx, tags[0]), task.GID)
+		}
+	}
+	for _, task := range analysis.MissingYouTrack {
+		check(task)
+	}
+	for _, ticket := range analysis.Mismatched {
+		check(ticket.AsanaTask)
+	}
+
+	proposals := make([]BundleProposal, 0, len(found))
+	for _, p := range found {
+		proposals = append(proposals, *p)
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].ID < proposals[j].ID })
+	analysis.MissingBundleValues = engine.ProposeBundleValues(proposals)
+
+	if len(proposals) > 0 {
+		slog.InfoContext(ctx, "Missing YouTrack values proposed; approve with POST /provisioning", "proposals", len(proposals))
+	}
+}
+
+// ProposeBundleValues merges newly detected proposals into the pending ones,
+// keeping when each was first seen, and returns the detected ones.
+func (e *SyncEngine) ProposeBundleValues(detected []BundleProposal) []BundleProposal {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.proposals == nil {
+		e.proposals = make(map[string]BundleProposal)
+	}
+	now := time.Now().UTC()
+	merged := make([]BundleProposal, 0, len(detected))
+	for _, p := range detected {
+		p.DetectedAt = now
+		if existing, ok := e.proposals[p.ID]; ok {
+			p.DetectedAt = existing.DetectedAt
+		}
+		e.proposals[p.ID] = p
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// BundleProposals returns the pending proposals, oldest first
+func (e *SyncEngine) BundleProposals() []BundleProposal {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	proposals := make([]BundleProposal, 0, len(e.proposals))
+	for _, p := range e.proposals {
+		proposals = append(proposals, p)
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		if !proposals[i].DetectedAt.Equal(proposals[j].DetectedAt) {
+			return proposals[i].DetectedAt.Before(proposals[j].DetectedAt)
+		}
+		return proposals[i].ID < proposals[j].ID
+	})
+	return proposals
+}
+
+func (e *SyncEngine) dropBundleProposal(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.proposals, id)
+}
+
+// applyBundleProposal adds the proposed value to the field's bundle in the
+// pair's project. A value someone already added counts as done.
+func applyBundleProposal(ctx context.Context, p BundleProposal) (string, error) {
+	client := youTrackFrom(ctx)
+	project := pairFrom(ctx).YouTrackProjectID
+	client.InvalidateFieldSchema(project)
+	schema := client.FieldSchema(ctx, project)
+	if schema.assumed {
+		return "", fmt.Errorf("fields of project %s cannot be read", project)
+	}
+
+	f, ok := schema.fields[p.Field]
+	if !ok {
+		return "", fmt.Errorf("%s in project %s: %w", p.Field, project, errNoSuchField)
+	}
+	if bundleHasName(f, p.Value) {
+		return "exists", nil
+	}
+	kind, base, err := kindOf(f)
+	if err != nil {
+		return "", err
+	}
+	bundleType := provisionableBundles[base]
+	if bundleType == "" || f.Bundle.ID == "" {
+		return "", fmt.Errorf("values of %s (%s) cannot be added automatically", p.Field, f.Field.FieldType.ID)
+	}
+
+	err = client.AddBundleValue(ctx, bundleType, f.Bundle.ID, kind.valueType, p.Value)
+	client.InvalidateFieldSchema(project)
+
+	outcome, errText := auditOutcome(err)
+	recordAudit(ctx, AuditEntry{
+		Action:  auditProvision,
+		After:   map[string]interface{}{"project": project, "field": p.Field, "value": p.Value, "asana_tickets": p.Tickets},
+		Outcome: outcome,
+		Error:   errText,
+	})
+	if err != nil {
+		return "", err
+	}
+	return "added", nil
+}
+
+// Provisioning handler: GET /provisioning lists the pair's proposals; POST
+// {"approve": ["Subsystem:web", ...]} adds the approved values to YouTrack.
+func (s *Server) provisioningHandler(w http.ResponseWriter, r *http.Request) {
+	engine := engineFrom(r.Context())
+	enabled := currentConfig().ProvisionBundleValues
+
+	switch r.Method {
+	case "GET":
+		// Values added in YouTrack by hand need no approval any more
+		if proposals := engine.BundleProposals(); enabled && len(proposals) > 0 {
+			if schema := pairFieldSchema(r.Context()); !schema.assumed {
+				for _, p := range proposals {
+					if f, ok := schema.fields[p.Field]; ok && bundleHasName(f, p.Value) {
+						engine.dropBundleProposal(p.ID)
+					}
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    "success",
+			"pair":      engine.Pair().Name,
+			"enabled":   enabled,
+			"proposals": engine.BundleProposals(),
+		})
+
+	case "POST":
+		if !enabled {
+			writeJSONError(w, http.StatusConflict, "provisioning_disabled",
+				"Bundle value provisioning is off. Set provisioning.bundle_values: true in config.yaml (or PROVISION_BUNDLE_VALUES=true) and reload.")
+			return
+		}
+		var req struct {
+			Approve []string `json:"approve"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Approve) == 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", `Body must be an object like {"approve":["Subsystem:web"]}`)
+			return
+		}
+
+		pending := make(map[string]BundleProposal)
+		for _, p := range engine.BundleProposals() {
+			pending[p.ID] = p
+		}
+
+		var unknown []string
+		for _, id := range req.Approve {
+			if _, ok := pending[id]; !ok {
+				unknown = append(unknown, id)
+			}
+		}
+		if len(unknown) > 0 {
+			writeJSONError(w, http.StatusNotFound, "unknown_proposal",
+				fmt.Sprintf("No pending proposal %s; see GET /provisioning.", quoteList(unknown)))
+			return
+		}
+
+		results := make([]map[string]interface{}, 0, len(req.Approve))
+		failed := 0
+		for _, id := range req.Approve {
+			p := pending[id]
+			status, err := applyBundleProposal(r.Context(), p)
+			if err != nil {
+				failed++
+				results = append(results, map[string]interface{}{"id": id, "status": "failed", "error": err.Error()})
+				continue
+			}
+			engine.dropBundleProposal(id)
+			slog.InfoContext(r.Context(), "Provisioned YouTrack value", "field", p.Field, "value", p.Value, "actor", actorName(r.Context()))
+			results = append(results, map[string]interface{}{"id": id, "status": status})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if failed == len(req.Approve) {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "completed",
+			"pair":    engine.Pair().Name,
+			"applied": len(req.Approve) - failed,
+			"failed":  failed,
+			"results": results,
+		})
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use GET or POST.")
+	}
+}
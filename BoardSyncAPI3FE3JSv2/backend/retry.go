@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracker names used for rate limiting and retry metrics
+const (
+	trackerAsana    = "asana"
+	trackerYouTrack = "youtrack"
+)
+
+// RetryPolicy controls how failed tracker calls are retried. It comes from
+// the retry section of config.yaml.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// MaxRetryAfter caps how long a Retry-After or exhausted quota header
+	// may hold off calls to a tracker.
+	MaxRetryAfter time.Duration
+}
+
+// trackerLimiter pairs a token bucket with its retry counters. There is one
+// per tenant, tracker and base URL, so one tenant's quota or Retry-After
+// never holds back another's calls.
+type trackerLimiter struct {
+	bucket *tokenBucket
+	stats  *retryStats
+}
+
+type limiterKey struct {
+	tenant  string
+	tracker string
+	baseURL string
+}
+
+var (
+	trackerLimitersMu sync.Mutex
+	trackerLimiters   = map[limiterKey]*trackerLimiter{}
+	trackerRates      = map[string]int{} // calls per minute by tracker name
+)
+
+// configureTrackerLimits sets the per-minute rate of each tracker and
+// applies it to existing limiters in place, so their counters and any
+// Retry-After pause in progress carry over.
+func configureTrackerLimits(asanaPerMinute, youTrackPerMinute int) {
+	trackerLimitersMu.Lock()
+	defer trackerLimitersMu.Unlock()
+
+	trackerRates[trackerAsana] = asanaPerMinute
+	trackerRates[trackerYouTrack] = youTrackPerMinute
+	for key, l := range trackerLimiters {
+		l.bucket.SetRate(trackerRates[key.tracker])
+	}
+}
+
+func limiterFor(tenant, tracker, baseURL string) *trackerLimiter {
+	trackerLimitersMu.Lock()
+	defer trackerLimitersMu.Unlock()
+
+	key := limiterKey{tenant: tenant, tracker: tracker, baseURL: baseURL}
+	l, ok := trackerLimiters[key]
+	if !ok {
+		l = &trackerLimiter{bucket: newTokenBucket(trackerRates[tracker]), stats: &retryStats{}}
+		trackerLimiters[key] = l
+	}
+	return l
+}
+
+// trackerRetryStats sums the retry counters per tracker for /status. An
+// empty tenant sums over all tenants.
+func trackerRetryStats(tenant string) map[string]RetryStatsSnapshot {
+	trackerLimitersMu.Lock()
+	defer trackerLimitersMu.Unlock()
+
+	out := make(map[string]RetryStatsSnapshot)
+	for key, l := range trackerLimiters {
+		if tenant != "" && key.tenant != tenant {
+			continue
+		}
+		out[key.tracker] = out[key.tracker].add(l.stats.snapshot())
+	}
+	return out
+}
+
+// trackerRetryStatsByTenant sums the retry counters per tenant and tracker
+// for /metrics.
+func trackerRetryStatsByTenant() map[string]map[string]RetryStatsSnapshot {
+	trackerLimitersMu.Lock()
+	defer trackerLimitersMu.Unlock()
+
+	out := make(map[string]map[string]RetryStatsSnapshot)
+	for key, l := range trackerLimiters {
+		if out[key.tenant] == nil {
+			out[key.tenant] = make(map[string]RetryStatsSnapshot)
+		}
+		out[key.tenant][key.tracker] = out[key.tenant][key.tracker].add(l.stats.snapshot())
+	}
+	return out
+}
+
+// trackerDo sends req to the given tracker through its rate limiter and
+// retries transient failures with exponential backoff and jitter.
+//
+// Only idempotent requests (GET, HEAD, PUT, DELETE, OPTIONS, or callers that
+// pass idempotent=true) are retried on network errors and 5xx responses.
+// A 429 is retried for every method, since the tracker rejected the request
+// without processing it. Waiting stops as soon as the request's context ends.
+func trackerDo(tracker string, client *http.Client, req *http.Request, idempotent bool) (*http.Response, error) {
+	tenant := ""
+	if t := tenantFrom(req.Context()); t != nil {
+		tenant = t.ID()
+	}
+	limiter := limiterFor(tenant, tracker, req.URL.Scheme+"://"+req.URL.Host)
+	policy := currentConfig().Retry
+	idempotent = idempotent || isIdempotentMethod(req.Method)
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("%s request body cannot be replayed for retry", tracker)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		if err := limiter.bucket.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+		limiter.stats.addRequest()
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		observeTrackerRequest(req.Context(), tracker, req.Method, resp, time.Since(start))
+
+		var retryAfter time.Duration
+		retry := false
+		switch {
+		case err != nil && req.Context().Err() != nil:
+			return nil, err
+		case err != nil:
+			limiter.stats.addNetworkError()
+			retry = idempotent
+		case resp.StatusCode == http.StatusTooManyRequests:
+			limiter.stats.addRateLimited()
+			retryAfter = parseRetryAfter(resp.Header, policy.MaxRetryAfter)
+			retry = true
+		case resp.StatusCode >= 500:
+			limiter.stats.addServerError()
+			retryAfter = parseRetryAfter(resp.Header, policy.MaxRetryAfter)
+			retry = idempotent
+		}
+
+		if resp != nil {
+			if pause := rateLimitPause(resp.Header, policy.MaxRetryAfter); pause > 0 {
+				limiter.bucket.PauseFor(pause)
+			}
+		}
+
+		if !retry {
+			return resp, err
+		}
+		if attempt >= policy.MaxAttempts {
+			limiter.stats.addGaveUp()
+			return resp, err
+		}
+
+		// Drain so the connection can be reused for the next attempt
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := backoffDelay(policy, attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if retryAfter > 0 {
+			limiter.bucket.PauseFor(retryAfter)
+		}
+
+		reason := "network error"
+		if err == nil {
+			reason = fmt.Sprintf("status %d", resp.StatusCode)
+		}
+		slog.WarnContext(req.Context(), "Tracker call failed, retrying",
+			"tracker", tracker, "method", req.Method, "path", req.URL.Path, "reason", reason,
+			"retry", attempt, "max_retries", policy.MaxAttempts-1, "delay", delay.Round(time.Millisecond))
+
+		trace.SpanFromContext(req.Context()).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt), attribute.String("reason", reason),
+			attribute.Int64("delay_ms", delay.Milliseconds())))
+		limiter.stats.addRetry()
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// backoffDelay returns an exponential delay with full jitter for the given attempt
+func backoffDelay(policy RetryPolicy, attempt int) time.Duration {
+	max := float64(policy.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if max > float64(policy.MaxDelay) {
+		max = float64(policy.MaxDelay)
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, capped at max.
+func parseRetryAfter(h http.Header, max time.Duration) time.Duration {
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+		if max > 0 && seconds > int64(max/time.Second) {
+			return max
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return capDuration(d, max)
+		}
+	}
+	return 0
+}
+
+func capDuration(d, max time.Duration) time.Duration {
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// rateLimitPause returns how long to hold off when X-RateLimit-Remaining says
+// the quota is exhausted. X-RateLimit-Reset may be a delay in seconds or a
+// Unix timestamp. The pause is capped at max.
+func rateLimitPause(h http.Header, max time.Duration) time.Duration {
+	remaining := h.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(remaining); err != nil || n > 0 {
+		return 0
+	}
+
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset <= 0 {
+		return time.Second
+	}
+	if reset > 1_000_000_000 {
+		if d := time.Until(time.Unix(reset, 0)); d > 0 {
+			return capDuration(d, max)
+		}
+		return 0
+	}
+	if max > 0 && reset > int64(max/time.Second) {
+		return max
+	}
+	return time.Duration(reset) * time.Second
+}
+
+// tokenBucket is a simple per-tracker rate limiter. A zero rate disables it.
+type tokenBucket struct {
+	mu          sync.Mutex
+	capacity    float64
+	tokens      float64
+	perSecond   float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	capacity, perSecond := bucketRate(perMinute)
+	return &tokenBucket{
+		capacity:  capacity,
+		tokens:    capacity,
+		perSecond: perSecond,
+		last:      time.Now(),
+	}
+}
+
+func bucketRate(perMinute int) (capacity, perSecond float64) {
+	capacity = float64(perMinute) / 6 // allow short bursts of ~10s worth of calls
+	if capacity < 1 {
+		capacity = 1
+	}
+	return capacity, float64(perMinute) / 60
+}
+
+// SetRate changes the rate. Tokens earned so far are kept up to the new
+// capacity, and a pause in progress still holds.
+func (b *tokenBucket) SetRate(perMinute int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.perSecond > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.perSecond)
+	}
+	b.last = now
+	b.capacity, b.perSecond = bucketRate(perMinute)
+	b.tokens = math.Min(b.tokens, b.capacity)
+}
+
+// Wait blocks until a token is available and any pause has elapsed, or ctx ends
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+
+		if now.Before(b.pausedUntil) {
+			wait := b.pausedUntil.Sub(now)
+			b.mu.Unlock()
+			if err := sleepContext(ctx, wait); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if b.perSecond <= 0 {
+			b.mu.Unlock()
+			return nil
+		}
+
+		b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.perSecond)
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((1 - b.tokens) / b.perSecond * float64(time.Second))
+		b.mu.Unlock()
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// PauseFor stops handing out tokens for d (used for Retry-After and exhausted quotas)
+func (b *tokenBucket) PauseFor(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
+
+// retryStats counts tracker HTTP outcomes for /status
+type retryStats struct {
+	mu           sync.Mutex
+	requests     int64
+	retries      int64
+	rateLimited  int64
+	serverErrors int64
+	networkErrs  int64
+	gaveUp       int64
+	lastRetryAt  time.Time
+}
+
+type RetryStatsSnapshot struct {
+	Requests      int64     `json:"requests"`
+	Retries       int64     `json:"retries"`
+	RateLimited   int64     `json:"rate_limited"`
+	ServerErrors  int64     `json:"server_errors"`
+	NetworkErrors int64     `json:"network_errors"`
+	GaveUp        int64     `json:"gave_up"`
+	LastRetryAt   time.Time `json:"last_retry_at"`
+}
+
+func (s *retryStats) addRequest()      { s.inc(&s.requests) }
+func (s *retryStats) addRateLimited()  { s.inc(&s.rateLimited) }
+func (s *retryStats) addServerError()  { s.inc(&s.serverErrors) }
+func (s *retryStats) addNetworkError() { s.inc(&s.networkErrs) }
+func (s *retryStats) addGaveUp()       { s.inc(&s.gaveUp) }
+
+func (s *retryStats) addRetry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries++
+	s.lastRetryAt = time.Now()
+}
+
+func (s *retryStats) inc(counter *int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*counter++
+}
+
+func (a RetryStatsSnapshot) add(b RetryStatsSnapshot) RetryStatsSnapshot {
+	a.Requests += b.Requests
+	a.Retries += b.Retries
+	a.RateLimited += b.RateLimited
+	a.ServerErrors += b.ServerErrors
+	a.NetworkErrors += b.NetworkErrors
+	a.GaveUp += b.GaveUp
+	if b.LastRetryAt.After(a.LastRetryAt) {
+		a.LastRetryAt = b.LastRetryAt
+	}
+	return a
+}
+
+func (s *retryStats) snapshot() RetryStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return RetryStatsSnapshot{
+		Requests:      s.requests,
+		Retries:       s.retries,
+		RateLimited:   s.rateLimited,
+		ServerErrors:  s.serverErrors,
+		NetworkErrors: s.networkErrs,
+		GaveUp:        s.gaveUp,
+		LastRetryAt:   s.lastRetryAt,
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useFastRetries installs a config whose backoff is short enough for tests,
+// and fresh limiters so counters start at zero
+func useFastRetries(t *testing.T) {
+	t.Helper()
+	c := useTestConfig(t)
+	c.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, MaxRetryAfter: 50 * time.Millisecond}
+	setConfig(c)
+
+	trackerLimitersMu.Lock()
+	previous := trackerLimiters
+	trackerLimiters = map[limiterKey]*trackerLimiter{}
+	trackerLimitersMu.Unlock()
+	t.Cleanup(func() {
+		trackerLimitersMu.Lock()
+		trackerLimiters = previous
+		trackerLimitersMu.Unlock()
+	})
+}
+
+// statusSequence answers with each status in turn, then 200
+func statusSequence(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func trackerRequest(t *testing.T, ctx context.Context, tenant, method, url string) *http.Request {
+	t.Helper()
+	if tenant != "" {
+		ctx = withTenant(ctx, &Tenant{id: tenant})
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestTrackerDoRetriesServerErrors(t *testing.T) {
+	useFastRetries(t)
+	srv, calls := statusSequence(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway)
+
+	resp, err := trackerDo(trackerYouTrack, srv.Client(), trackerRequest(t, context.Background(), "retry-5xx", "GET", srv.URL), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(calls) != 3 {
+		t.Fatalf("got status %d after %d calls, want 200 after 3", resp.StatusCode, *calls)
+	}
+	if stats := trackerRetryStats("retry-5xx")[trackerYouTrack]; stats.Retries != 2 || stats.ServerErrors != 2 {
+		t.Fatalf("stats = %+v, want 2 retries and 2 server errors", stats)
+	}
+}
+
+func TestTrackerDoGivesUpAfterMaxAttempts(t *testing.T) {
+	useFastRetries(t)
+	srv, calls := statusSequence(t, nil, 503, 503, 503, 503)
+
+	resp, err := trackerDo(trackerYouTrack, srv.Client(), trackerRequest(t, context.Background(), "retry-give-up", "GET", srv.URL), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(calls) != 3 {
+		t.Fatalf("got status %d after %d calls, want 503 after 3", resp.StatusCode, *calls)
+	}
+	if stats := trackerRetryStats("retry-give-up")[trackerYouTrack]; stats.GaveUp != 1 {
+		t.Fatalf("stats = %+v, want 1 gave up", stats)
+	}
+}
+
+func TestTrackerDoRetriesPostOnlyWhenRateLimited(t *testing.T) {
+	useFastRetries(t)
+
+	srv, calls := statusSequence(t, nil, http.StatusServiceUnavailable)
+	resp, err := trackerDo(trackerAsana, srv.Client(), trackerRequest(t, context.Background(), "retry-post", "POST", srv.URL), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("POST got status %d after %d calls, want 503 after 1", resp.StatusCode, *calls)
+	}
+
+	srv, calls = statusSequence(t, nil, http.StatusTooManyRequests)
+	resp, err = trackerDo(trackerAsana, srv.Client(), trackerRequest(t, context.Background(), "retry-post", "POST", srv.URL), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(calls) != 2 {
+		t.Fatalf("POST got status %d after %d calls, want 200 after 2", resp.StatusCode, *calls)
+	}
+}
+
+func TestTrackerDoCapsRetryAfter(t *testing.T) {
+	useFastRetries(t)
+	srv, _ := statusSequence(t, http.Header{"Retry-After": {"3600"}}, http.StatusTooManyRequests)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	resp, err := trackerDo(trackerAsana, srv.Client(), trackerRequest(t, ctx, "retry-cap", "GET", srv.URL), false)
+	if err != nil {
+		t.Fatalf("Retry-After was not capped: %v", err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("retry waited %s, want about retry.max_retry_after", elapsed)
+	}
+}
+
+func TestParseRetryAfterCapsAtMax(t *testing.T) {
+	max := 2 * time.Minute
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{}, 0},
+		{http.Header{"Retry-After": {"30"}}, 30 * time.Second},
+		{http.Header{"Retry-After": {"86400"}}, max},
+		{http.Header{"Retry-After": {"99999999999999999"}}, max},
+		{http.Header{"Retry-After": {time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat)}}, max},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, max); got != tt.want {
+			t.Errorf("parseRetryAfter(%v) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+
+	exhausted := http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"7200"}}
+	if got := rateLimitPause(exhausted, max); got != max {
+		t.Errorf("rateLimitPause = %s, want %s", got, max)
+	}
+}
+
+func TestTrackerLimitersAreIsolatedPerTenant(t *testing.T) {
+	useFastRetries(t)
+	srv, _ := statusSequence(t, nil)
+
+	// Tenant a hit its quota on this tracker; tenant b must not wait for it
+	limiterFor("limit-a", trackerAsana, srv.URL).bucket.PauseFor(time.Hour)
+
+	resp, err := trackerDo(trackerAsana, srv.Client(), trackerRequest(t, context.Background(), "limit-b", "GET", srv.URL), false)
+	if err != nil {
+		t.Fatalf("tenant b was held back by tenant a's pause: %v", err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := trackerDo(trackerAsana, srv.Client(), trackerRequest(t, ctx, "limit-a", "GET", srv.URL), false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("tenant a err = %v, want it to wait out its pause", err)
+	}
+
+	// The same tenant on another base URL has its own limiter too
+	other, _ := statusSequence(t, nil)
+	resp, err = trackerDo(trackerAsana, other.Client(), trackerRequest(t, context.Background(), "limit-a", "GET", other.URL), false)
+	if err != nil {
+		t.Fatalf("another base URL was held back: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTrackerDoConcurrentlyWithReconfigure(t *testing.T) {
+	useFastRetries(t)
+	srv, calls := statusSequence(t, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tenant := []string{"race-a", "race-b"}[i%2]
+			for j := 0; j < 10; j++ {
+				resp, err := trackerDo(trackerYouTrack, srv.Client(), trackerRequest(t, context.Background(), tenant, "GET", srv.URL), false)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 10; j++ {
+			configureTrackerLimits(0, 0)
+			trackerRetryStats("")
+		}
+	}()
+	wg.Wait()
+
+	a, b := trackerRetryStats("race-a")[trackerYouTrack], trackerRetryStats("race-b")[trackerYouTrack]
+	if a.Requests != 40 || b.Requests != 40 || atomic.LoadInt32(calls) != 80 {
+		t.Fatalf("requests a=%d b=%d served=%d, want 40, 40 and 80", a.Requests, b.Requests, *calls)
+	}
+	if all := trackerRetryStats("")[trackerYouTrack]; all.Requests != 80 {
+		t.Fatalf("service-wide requests = %d, want 80", all.Requests)
+	}
+}
+
+func TestConfigureTrackerLimitsKeepsRetryAfterPause(t *testing.T) {
+	useFastRetries(t)
+	srv, calls := statusSequence(t, nil)
+
+	trackerLimitersMu.Lock()
+	asanaRate, youTrackRate := trackerRates[trackerAsana], trackerRates[trackerYouTrack]
+	trackerLimitersMu.Unlock()
+	t.Cleanup(func() { configureTrackerLimits(asanaRate, youTrackRate) })
+
+	limiterFor("reload", trackerAsana, srv.URL).bucket.PauseFor(time.Hour)
+	configureTrackerLimits(600, 600)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := trackerDo(trackerAsana, srv.Client(), trackerRequest(t, ctx, "reload", "GET", srv.URL), false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the call to wait out the pause", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 0 {
+		t.Fatalf("%d calls reached the tracker during the pause", n)
+	}
+	if b := limiterFor("reload", trackerAsana, srv.URL).bucket; b.perSecond != 10 {
+		t.Fatalf("rate = %v/s after reload, want 10/s", b.perSecond)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role is an access level. Each role includes everything the lower ones can do.
+type Role string
+
+const (
+	roleViewer   Role = "viewer"   // analyze, tickets, status
+	roleOperator Role = "operator" // sync, create, ignore, auto-* control
+	roleAdmin    Role = "admin"    // delete-tickets, keys, mapping/config changes
+)
+
+var roleRank = map[Role]int{
+	roleViewer:   1,
+	roleOperator: 2,
+	roleAdmin:    3,
+}
+
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// Allows reports whether r grants at least the required role.
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[required]
+}
+
+func roleNames() []string {
+	return []string{string(roleViewer), string(roleOperator), string(roleAdmin)}
+}
+
+// routeAccess is the role an endpoint needs for reads (GET/HEAD) and for
+// everything else. An empty role denies those methods. Public routes take
+// every method without authentication and check the caller themselves, if
+// at all.
+type routeAccess struct {
+	Read   Role
+	Write  Role
+	Public bool
+}
+
+// routeRoles is the single source of truth for endpoint permissions; guard
+// enforces it and /whoami reports it.
+var routeRoles = map[string]routeAccess{
+	"/health":                  {Public: true},
+	"/status":                  {Read: roleViewer},
+	"/whoami":                  {Read: roleViewer},
+	"/analyze":                 {Read: roleViewer},
+	"/tickets":                 {Read: roleViewer},
+	"/jobs":                    {Read: roleViewer, Write: roleOperator},
+	"/sync":                    {Read: roleViewer, Write: roleOperator},
+	"/ignore":                  {Read: roleViewer, Write: roleOperator},
+	"/auto-sync":               {Read: roleViewer, Write: roleOperator},
+	"/auto-create":             {Read: roleViewer, Write: roleOperator},
+	"/create":                  {Read: roleOperator, Write: roleOperator}, // GET also starts a create job
+	"/create-single":           {Write: roleOperator},
+	"/delete-tickets":          {Write: roleAdmin},
+	"/delete-tickets/preview":  {Write: roleAdmin},
+	"/admin/api-keys":          {Read: roleAdmin, Write: roleAdmin},
+	"/admin/tenants":           {Read: roleAdmin, Write: roleAdmin},
+	"/admin/secrets":           {Read: roleAdmin, Write: roleAdmin},
+	"/admin/config":            {Read: roleAdmin, Write: roleAdmin},
+	"/diagnostics":             {Read: roleOperator},
+	"/metrics":                 {Read: roleViewer}, // tenant-bound callers see their tenant only
+	"/alerts":                  {Read: roleOperator, Write: roleAdmin},
+	"/provisioning":            {Read: roleOperator, Write: roleAdmin},
+	"/audit":                   {Read: roleAdmin},
+	"/trash":                   {Read: roleAdmin},
+	"/restore":                 {Write: roleAdmin},
+	"/webhooks/asana":          {Public: true}, // signed by Asana; only invalidates the cache
+	"/webhooks/asana/register": {Write: roleAdmin},
+	"/webhooks/youtrack":       {Public: true}, // checks the per-tenant youtrack_webhook_token; only invalidates the cache
+}
+
+// requiredRole returns the role needed for the request's method on route.
+func (a routeAccess) requiredRole(method string) Role {
+	if method == http.MethodGet || method == http.MethodHead {
+		return a.Read
+	}
+	return a.Write
+}
+
+// guard authenticates and authorizes requests to route according to
+// routeRoles. CORS preflights never get here; corsMiddleware answers them.
+func (s *Server) guard(route string, next http.HandlerFunc) http.HandlerFunc {
+	access, ok := routeRoles[route]
+	if !ok {
+		panic(fmt.Sprintf("no access rule for route %s", route))
+	}
+	if !access.Public && access.Read == "" && access.Write == "" {
+		panic(fmt.Sprintf("route %s is neither public nor open to any role", route))
+	}
+
+	return traceHandler(route, func(w http.ResponseWriter, r *http.Request) {
+		if access.Public {
+			next(w, r)
+			return
+		}
+		required := access.requiredRole(r.Method)
+		if required == "" {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed",
+				fmt.Sprintf("%s is not allowed on %s.", r.Method, route))
+			return
+		}
+
+		principal := s.authenticate(w, r)
+		if principal == nil {
+			return
+		}
+		if !principal.Role.Allows(required) {
+			has := string(principal.Role)
+			if has == "" {
+				has = "no role"
+			}
+			writeJSONError(w, http.StatusForbidden, "forbidden",
+				fmt.Sprintf("%s %s requires the %s role; %q has %s.", r.Method, route, required, principal.Name, has))
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			slog.InfoContext(r.Context(), "Request", "method", r.Method, "path", r.URL.Path, "actor", principal.Name, "role", principal.Role)
+		}
+		ctx := withPrincipal(r.Context(), principal)
+		ctx = withAuditContext(ctx, AuditContext{
+			Actor:    principal.Name,
+			Trigger:  triggerManual,
+			Endpoint: route,
+		})
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// permissionsFor lists "METHOD route" entries the role may call.
+func permissionsFor(role Role) []string {
+	var permissions []string
+	for route, access := range routeRoles {
+		if access.Public || (access.Read != "" && role.Allows(access.Read)) {
+			permissions = append(permissions, "GET "+route)
+		}
+		if access.Public || (access.Write != "" && role.Allows(access.Write)) {
+			permissions = append(permissions, "POST/DELETE "+route)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// Who am I handler
+func (s *Server) whoamiHandler(w http.ResponseWriter, r *http.Request) {
+	principal := principalFrom(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key_id":      principal.KeyID,
+		"name":        principal.Name,
+		"role":        principal.Role,
+		"tenant":      principal.Tenant,
+		"auth_method": principal.AuthMethod,
+		"subject":     principal.Subject,
+		"expires_at":  principal.ExpiresAt,
+		"roles":       roleNames(),
+		"permissions": permissionsFor(principal.Role),
+	})
+}
+
+// Delete confirmation
+
+const confirmationTTL = 5 * time.Minute
+
+// ConfirmationStore issues single-use tokens that bind a destructive request
+// (the exact ticket IDs and source) to the caller who previewed it.
+type ConfirmationStore struct {
+	mu     sync.Mutex
+	tokens map[string]confirmation
+}
+
+type confirmation struct {
+	digest    string
+	keyID     string
+	expiresAt time.Time
+}
+
+func NewConfirmationStore() *ConfirmationStore {
+	return &ConfirmationStore{tokens: make(map[string]confirmation)}
+}
+
+// Issue returns a token valid for confirmationTTL. scope is everything else
+// about the request that must not change, such as source and strategy.
+func (cs *ConfirmationStore) Issue(keyID, scope string, ticketIDs []string) (string, time.Time, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := time.Now().Add(confirmationTTL)
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	now := time.Now()
+	for t, c := range cs.tokens {
+		if now.After(c.expiresAt) {
+			delete(cs.tokens, t)
+		}
+	}
+	cs.tokens[token] = confirmation{
+		digest:    deleteDigest(scope, ticketIDs),
+		keyID:     keyID,
+		expiresAt: expiresAt,
+	}
+	return token, expiresAt, nil
+}
+
+// Consume validates and invalidates a token. The request must match the
+// previewed one exactly and come from the same key.
+func (cs *ConfirmationStore) Consume(token, keyID, scope string, ticketIDs []string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	c, ok := cs.tokens[token]
+	if !ok {
+		return fmt.Errorf("unknown or already used confirmation token")
+	}
+	delete(cs.tokens, token)
+
+	switch {
+	case time.Now().After(c.expiresAt):
+		return fmt.Errorf("confirmation token expired; request a new preview")
+	case c.keyID != keyID:
+		return fmt.Errorf("confirmation token was issued to a different key")
+	case c.digest != deleteDigest(scope, ticketIDs):
+		return fmt.Errorf("ticket_ids, source or strategy differ from the previewed request")
+	}
+	return nil
+}
+
+func deleteDigest(scope string, ticketIDs []string) string {
+	ids := append([]string(nil), ticketIDs...)
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(scope + "\n" + strings.Join(ids, ",")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGuardDeniesMethodsWithoutRole(t *testing.T) {
+	s := &Server{}
+	called := false
+	h := s.guard("/create-single", func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	for _, method := range []string{"GET", "HEAD"} {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(method, "/create-single", nil))
+		if rec.Code != http.StatusMethodNotAllowed || called {
+			t.Fatalf("%s /create-single: status %d, handler called %v; want 405 and no call", method, rec.Code, called)
+		}
+	}
+}
+
+func TestGuardLetsPublicRoutesThrough(t *testing.T) {
+	s := &Server{}
+	for _, route := range []string{"/health", "/webhooks/asana", "/webhooks/youtrack"} {
+		for _, method := range []string{"GET", "POST"} {
+			called := false
+			h := s.guard(route, func(w http.ResponseWriter, r *http.Request) { called = true })
+			h(httptest.NewRecorder(), httptest.NewRequest(method, route, nil))
+			if !called {
+				t.Fatalf("%s %s did not reach its handler", method, route)
+			}
+		}
+	}
+}
+
+func TestPermissionsForListsOnlyGrantedMethods(t *testing.T) {
+	granted := map[string]bool{}
+	for _, p := range permissionsFor(roleViewer) {
+		granted[p] = true
+	}
+	for _, want := range []string{"GET /health", "POST/DELETE /webhooks/asana", "GET /analyze"} {
+		if !granted[want] {
+			t.Errorf("viewer permissions miss %q", want)
+		}
+	}
+	for _, unwanted := range []string{"GET /create-single", "GET /restore", "POST/DELETE /analyze", "POST/DELETE /sync"} {
+		if granted[unwanted] {
+			t.Errorf("viewer permissions include %q", unwanted)
+		}
+	}
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretsProvider stores tracker credentials by name, such as
+// "tenant/acme/asana_pat". tenants.json only holds references to them.
+type SecretsProvider interface {
+	Backend() string
+	// Persistent reports whether Set survives a restart
+	Persistent() bool
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// Secrets backends
+const (
+	secretsEnv     = "env"
+	secretsFile    = "file"
+	secretsKeyring = "keyring"
+)
+
+var errSecretNotFound = errors.New("secret not found")
+
+// newSecretsProvider builds the backend named by SECRETS_BACKEND. The file
+// and keyring backends encrypt with the master key from SECRETS_MASTER_KEY
+// or SECRETS_MASTER_KEY_FILE (32 bytes, base64 or hex).
+func newSecretsProvider(backend string) (SecretsProvider, error) {
+	switch backend {
+	case "", secretsEnv:
+		return newEnvSecrets(), nil
+	case secretsFile, secretsKeyring:
+		key, err := loadMasterKey()
+		if err != nil {
+			return nil, err
+		}
+		aead, err := newSealer(key)
+		if err != nil {
+			return nil, err
+		}
+		if backend == secretsFile {
+			return newFileSecrets(currentConfig().SecretsFile, aead)
+		}
+		return newKeyringSecrets(currentConfig().SecretsDir, aead)
+	default:
+		return nil, fmt.Errorf("unknown secrets backend %q (use env, file or keyring)", backend)
+	}
+}
+
+func loadMasterKey() ([]byte, error) {
+	encoded := strings.TrimSpace(os.Getenv("SECRETS_MASTER_KEY"))
+	if encoded == "" {
+		if file := os.Getenv("SECRETS_MASTER_KEY_FILE"); file != "" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("SECRETS_MASTER_KEY_FILE: %w", err)
+			}
+			encoded = strings.TrimSpace(string(data))
+		}
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("SECRETS_MASTER_KEY or SECRETS_MASTER_KEY_FILE is required for encrypted secrets")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		key, err = hex.DecodeString(encoded)
+	}
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, base64 or hex encoded (try: openssl rand -base64 32)")
+	}
+	return key, nil
+}
+
+// sealer encrypts with AES-256-GCM. Sealed values are nonce || ciphertext;
+// the secret's name is authenticated so values cannot be swapped between
+// names.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+func (s *sealer) seal(plaintext []byte, name string) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, []byte(name)), nil
+}
+
+func (s *sealer) open(sealed []byte, name string) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, fmt.Errorf("sealed value too short")
+	}
+	plaintext, err := s.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt (wrong master key?)")
+	}
+	return plaintext, nil
+}
+
+// writeFileAtomic writes data through a temp file so readers never see a
+// partial file.
+func writeFileAtomic(file string, data []byte) error {
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// envSecrets reads secrets from environment variables named after the
+// secret ("tenant/acme/asana_pat" is TENANT_ACME_ASANA_PAT). The default
+// tenant also answers to the variables used before tenants existed. Set only
+// lasts until the process exits.
+type envSecrets struct {
+	mu        sync.RWMutex
+	overrides map[string]string
+}
+
+func newEnvSecrets() *envSecrets {
+	return &envSecrets{overrides: make(map[string]string)}
+}
+
+// legacyEnvNames are the variables the default tenant was configured with
+var legacyEnvNames = map[string]string{
+	"asana_pat":              "ASANA_PAT",
+	"youtrack_token":         "YOUTRACK_TOKEN",
+	"asana_webhook_secret":   "ASANA_WEBHOOK_SECRET",
+	"youtrack_webhook_token": "YOUTRACK_WEBHOOK_TOKEN",
+}
+
+func envSecretName(name string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name))
+}
+
+func (e *envSecrets) Backend() string  { return secretsEnv }
+func (e *envSecrets) Persistent() bool { return false }
+
+func (e *envSecrets) Get(name string) (string, error) {
+	e.mu.RLock()
+	value, ok := e.overrides[name]
+	e.mu.RUnlock()
+	if ok {
+		return value, nil
+	}
+
+	if value := os.Getenv(envSecretName(name)); value != "" {
+		return value, nil
+	}
+	if strings.HasPrefix(name, tenantSecretPrefix(defaultTenantID)) {
+		if legacy, ok := legacyEnvNames[strings.TrimPrefix(name, tenantSecretPrefix(defaultTenantID))]; ok {
+			if value := os.Getenv(legacy); value != "" {
+				return value, nil
+			}
+		}
+	}
+	return "", errSecretNotFound
+}
+
+func (e *envSecrets) Set(name, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.overrides[name] = value
+	return nil
+}
+
+func (e *envSecrets) Delete(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.overrides[name] = ""
+	return nil
+}
+
+// fileSecrets keeps all secrets in one AES-GCM encrypted JSON file.
+type fileSecrets struct {
+	file   string
+	sealer *sealer
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// fileSecretsAD binds the file's ciphertext to its purpose
+const fileSecretsAD = "boardsync-secrets-v1"
+
+func newFileSecrets(file string, s *sealer) (*fileSecrets, error) {
+	fs := &fileSecrets{file: file, sealer: s, values: make(map[string]string)}
+
+	sealed, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.open(sealed, fileSecretsAD)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if err := json.Unmarshal(data, &fs.values); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return fs, nil
+}
+
+func (fs *fileSecrets) Backend() string  { return secretsFile }
+func (fs *fileSecrets) Persistent() bool { return true }
+
+func (fs *fileSecrets) Get(name string) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	value, ok := fs.values[name]
+	if !ok {
+		return "", errSecretNotFound
+	}
+	return value, nil
+}
+
+func (fs *fileSecrets) Set(name, value string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	previous, had := fs.values[name]
+	fs.values[name] = value
+	if err := fs.saveLocked(); err != nil {
+		if had {
+			fs.values[name] = previous
+		} else {
+			delete(fs.values, name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (fs *fileSecrets) Delete(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, ok := fs.values[name]; !ok {
+		return nil
+	}
+	delete(fs.values, name)
+	return fs.saveLocked()
+}
+
+func (fs *fileSecrets) saveLocked() error {
+	data, err := json.Marshal(fs.values)
+	if err != nil {
+		return err
+	}
+	sealed, err := fs.sealer.seal(data, fileSecretsAD)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fs.file, sealed)
+}
+
+// keyringSecrets keeps one encrypted file per secret in a private directory,
+// like a desktop keyring's file backend. Rotating one secret rewrites only
+// its own file.
+type keyringSecrets struct {
+	dir    string
+	sealer *sealer
+	mu     sync.Mutex
+}
+
+func newKeyringSecrets(dir string, s *sealer) (*keyringSecrets, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &keyringSecrets{dir: dir, sealer: s}, nil
+}
+
+func (k *keyringSecrets) Backend() string  { return secretsKeyring }
+func (k *keyringSecrets) Persistent() bool { return true }
+
+func (k *keyringSecrets) path(name string) string {
+	return filepath.Join(k.dir, url.PathEscape(name)+".secret")
+}
+
+func (k *keyringSecrets) Get(name string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	sealed, err := os.ReadFile(k.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	value, err := k.sealer.open(sealed, name)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func (k *keyringSecrets) Set(name, value string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	sealed, err := k.sealer.seal([]byte(value), name)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(k.path(name), sealed)
+}
+
+func (k *keyringSecrets) Delete(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	err := os.Remove(k.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Redaction
+
+// minRedactedLength keeps short values, which could match ordinary text,
+// out of the redactor.
+const minRedactedLength = 8
+
+const redactedText = "[REDACTED]"
+
+// secretRedactor replaces known secret values in log output and response
+// bodies. Values stay registered after rotation so old tokens are masked too.
+var secretRedactor = &redactor{values: make(map[string]bool)}
+
+type redactor struct {
+	mu     sync.RWMutex
+	values map[string]bool
+	sorted []string // longest first, so overlapping values mask fully
+}
+
+func (r *redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range values {
+		if len(v) < minRedactedLength || r.values[v] {
+			continue
+		}
+		r.values[v] = true
+		r.sorted = append(r.sorted, v)
+	}
+	sort.Slice(r.sorted, func(i, j int) bool { return len(r.sorted[i]) > len(r.sorted[j]) })
+}
+
+func (r *redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.redactLocked(s)
+}
+
+func (r *redactor) redactLocked(s string) string {
+	for _, v := range r.sorted {
+		if strings.Contains(s, v) {
+			s = strings.ReplaceAll(s, v, redactedText)
+		}
+	}
+	return s
+}
+
+// RedactStream is Redact for output written in pieces. It returns the
+// redacted text that can go out now and the tail to hold back until the
+// next piece, because it may be the start of a secret continued there.
+func (r *redactor) RedactStream(s string) (ready, held string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.sorted) == 0 {
+		return s, ""
+	}
+
+	// Hold back from the first position where the rest of s could still
+	// grow into a secret
+	cut := len(s)
+	for i := len(s) - len(r.sorted[0]) + 1; i < len(s); i++ {
+		if i >= 0 && r.startsSecretLocked(s[i:]) {
+			cut = i
+			break
+		}
+	}
+
+	// A whole secret across the cut is held back with it, so it is
+	// masked in one piece
+	for moved := true; moved; {
+		moved = false
+		for _, v := range r.sorted {
+			for start := cut - len(v) + 1; start < cut; start++ {
+				if start >= 0 && strings.HasPrefix(s[start:], v) {
+					cut, moved = start, true
+					break
+				}
+			}
+		}
+	}
+	return r.redactLocked(s[:cut]), s[cut:]
+}
+
+// startsSecretLocked reports whether tail is a proper prefix of a secret
+func (r *redactor) startsSecretLocked(tail string) bool {
+	for _, v := range r.sorted {
+		if len(tail) < len(v) && strings.HasPrefix(v, tail) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecrets masks every registered secret in s
+func redactSecrets(s string) string {
+	return secretRedactor.Redact(s)
+}
+
+// redactingWriter masks secrets in everything written through it
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, redactSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redactStandardLogger routes the standard library logger, used by
+// net/http for server errors, through the redactor. Everything else that
+// prints goes through the slog handler, the response writer or its own
+// redactingWriter.
+func redactStandardLogger() {
+	log.SetOutput(redactingWriter{os.Stderr})
+}
+
+// redactResponses masks secrets in response bodies, error messages
+// included. Nothing the API returns should contain a tracker credential.
+func redactResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &redactingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		rw.flushHeld()
+	})
+}
+
+// redactingResponseWriter holds back the end of each write that may be
+// the start of a secret, so a secret split across writes (say at a CSV
+// flush) is still masked.
+type redactingResponseWriter struct {
+	http.ResponseWriter
+	held string
+}
+
+func (rw *redactingResponseWriter) Write(p []byte) (int, error) {
+	ready, held := secretRedactor.RedactStream(rw.held + string(p))
+	rw.held = held
+	if _, err := io.WriteString(rw.ResponseWriter, ready); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flushHeld writes what is still held back once the handler is done.
+func (rw *redactingResponseWriter) flushHeld() {
+	if rw.held != "" {
+		io.WriteString(rw.ResponseWriter, redactSecrets(rw.held))
+		rw.held = ""
+	}
+}
+
+// Secrets handler: GET /admin/secrets shows which credentials of the tenant
+// are set; POST /admin/secrets {"name":"asana_pat","value":"..."} rotates
+// one without a restart. Values are never returned.
+func (s *Server) secretsHandler(w http.ResponseWriter, r *http.Request) {
+	t := tenantFrom(r.Context())
+	provider := s.tenants.Secrets()
+
+	switch r.Method {
+	case "GET":
+		rec := t.Record()
+		secrets := make([]map[string]interface{}, 0, len(tenantSecrets))
+		for _, sec := range tenantSecrets {
+			secrets = append(secrets, map[string]interface{}{
+				"name":     sec.name,
+				"required": sec.required,
+				"set":      *sec.field(&rec) != "",
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "success",
+			"tenant":     t.ID(),
+			"backend":    provider.Backend(),
+			"persistent": provider.Persistent(),
+			"secrets":    secrets,
+		})
+
+	case "POST":
+		var req struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", `Body must be an object like {"name":"asana_pat","value":"..."}`)
+			return
+		}
+		var rec TenantRecord
+		if _, ok := tenantSecretField(&rec, req.Name); !ok {
+			names := make([]string, 0, len(tenantSecrets))
+			for _, sec := range tenantSecrets {
+				names = append(names, sec.name)
+			}
+			writeJSONError(w, http.StatusBadRequest, "invalid_request",
+				fmt.Sprintf("Unknown secret %q. Secrets: %s.", req.Name, strings.Join(names, ", ")))
+			return
+		}
+
+		value := strings.TrimSpace(req.Value)
+		secretRedactor.Add(value)
+		if err := s.tenants.RotateSecret(t.ID(), req.Name, value); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		recordAudit(r.Context(), AuditEntry{
+			Action: auditRotateSecret,
+			After:  map[string]interface{}{"secret": req.Name, "backend": provider.Backend()},
+		})
+
+		slog.InfoContext(r.Context(), "Secret rotated", "secret", req.Name, "actor", actorName(r.Context()))
+
+		response := map[string]interface{}{
+			"status":    "rotated",
+			"tenant":    t.ID(),
+			"name":      req.Name,
+			"backend":   provider.Backend(),
+			"persisted": provider.Persistent(),
+		}
+		if !provider.Persistent() {
+			response["note"] = fmt.Sprintf("The env secrets backend keeps rotated values in memory only; also update %s before the next restart.",
+				envSecretName(tenantSecretPrefix(t.ID())+req.Name))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		http.Error(w, "Method not allowed. Use GET or POST.", http.StatusMethodNotAllowed)
+	}
+}
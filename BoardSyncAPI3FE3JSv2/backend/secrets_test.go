@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testSealer(t *testing.T) *sealer {
+	t.Helper()
+	key := make([]byte, 32)
+	rand.Read(key)
+	s, err := newSealer(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSealerRoundTripAndTamper(t *testing.T) {
+	s := testSealer(t)
+	sealed, err := s.seal([]byte("asana-token-value"), "tenant/acme/asana_pat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("asana-token-value")) {
+		t.Fatalf("sealed value contains the plaintext")
+	}
+	if again, _ := s.seal([]byte("asana-token-value"), "tenant/acme/asana_pat"); bytes.Equal(again, sealed) {
+		t.Fatalf("sealing twice gave the same ciphertext")
+	}
+
+	plain, err := s.open(sealed, "tenant/acme/asana_pat")
+	if err != nil || string(plain) != "asana-token-value" {
+		t.Fatalf("open = %q, %v", plain, err)
+	}
+
+	for i := range sealed {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 0x01
+		if _, err := s.open(tampered, "tenant/acme/asana_pat"); err == nil {
+			t.Fatalf("open succeeded with byte %d flipped", i)
+		}
+	}
+	if _, err := s.open(sealed, "tenant/other/asana_pat"); err == nil {
+		t.Fatalf("open succeeded under another secret name")
+	}
+	if _, err := testSealer(t).open(sealed, "tenant/acme/asana_pat"); err == nil {
+		t.Fatalf("open succeeded with another master key")
+	}
+	if _, err := s.open(sealed[:5], "tenant/acme/asana_pat"); err == nil {
+		t.Fatalf("open succeeded on a truncated value")
+	}
+}
+
+func TestFileSecretsPersistEncrypted(t *testing.T) {
+	s := testSealer(t)
+	file := filepath.Join(t.TempDir(), "secrets.enc")
+	fs, err := newFileSecrets(file, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Set("tenant/acme/youtrack_token", "perm:secret-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("perm:secret-token")) {
+		t.Fatalf("secrets file contains the plaintext")
+	}
+	if info, _ := os.Stat(file); info.Mode().Perm() != 0600 {
+		t.Fatalf("secrets file mode %v, want 0600", info.Mode().Perm())
+	}
+
+	reopened, err := newFileSecrets(file, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := reopened.Get("tenant/acme/youtrack_token"); err != nil || value != "perm:secret-token" {
+		t.Fatalf("Get after reopen = %q, %v", value, err)
+	}
+
+	data[len(data)-1] ^= 0x01
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFileSecrets(file, s); err == nil {
+		t.Fatalf("tampered secrets file was accepted")
+	}
+}
+
+func TestKeyringSecretsCannotBeSwapped(t *testing.T) {
+	k, err := newKeyringSecrets(t.TempDir(), testSealer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Set("tenant/acme/asana_pat", "acme-asana-pat"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := k.Get("tenant/acme/asana_pat"); err != nil || value != "acme-asana-pat" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+
+	// Copying one tenant's file over another's must not hand it the value
+	data, err := os.ReadFile(k.path("tenant/acme/asana_pat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(k.path("tenant/other/asana_pat"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Get("tenant/other/asana_pat"); err == nil {
+		t.Fatalf("a secret copied to another name decrypted")
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	secretRedactor.Add("redact-test-token-1", "redact-test-token-1-longer", "short")
+
+	tests := map[string]string{
+		"token redact-test-token-1 leaked":              "token [REDACTED] leaked",
+		"redact-test-token-1-longer":                    "[REDACTED]",
+		"twice redact-test-token-1 redact-test-token-1": "twice [REDACTED] [REDACTED]",
+		"short values are not secrets":                  "short values are not secrets",
+		"nothing here":                                  "nothing here",
+	}
+	for in, want := range tests {
+		if got := redactSecrets(in); got != want {
+			t.Errorf("redactSecrets(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRedactResponsesAcrossWrites(t *testing.T) {
+	const secret = "split-write-secret-value"
+	secretRedactor.Add(secret)
+	body := "name,token\nacme," + secret + "\nother,none\n"
+
+	// Every split of the body into two writes is masked the same way
+	for cut := 0; cut <= len(body); cut++ {
+		h := redactResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body[:cut]))
+			w.Write([]byte(body[cut:]))
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/audit?format=csv", nil))
+
+		if got := rec.Body.String(); got != strings.Replace(body, secret, redactedText, 1) {
+			t.Fatalf("split at %d: body = %q", cut, got)
+		}
+	}
+
+	// A secret whose end is the start of another is still masked whole
+	secretRedactor.Add("overlap-first-12345678", "12345678-overlap-second")
+	overlapping := "a overlap-first-12345678 b"
+	for cut := 0; cut <= len(overlapping); cut++ {
+		h := redactResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(overlapping[:cut]))
+			w.Write([]byte(overlapping[cut:]))
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if got := rec.Body.String(); got != "a "+redactedText+" b" {
+			t.Fatalf("overlapping split at %d: body = %q", cut, got)
+		}
+	}
+
+	// One byte at a time, with the secret ending the response
+	h := redactResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, b := range []byte("token=" + secret) {
+			w.Write([]byte{b})
+		}
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := rec.Body.String(); got != "token="+redactedText {
+		t.Fatalf("byte-wise body = %q", got)
+	}
+}
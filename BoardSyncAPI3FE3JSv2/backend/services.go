@@ -1,131 +1,104 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"log/slog"
 	"strings"
 	"time"
-)
 
-// ENHANCED: Asana API Functions with Tag Support
-func getAsanaTasks() ([]AsanaTask, error) {
-	url := fmt.Sprintf("https://app.asana.com/api/1.0/projects/%s/tasks?opt_fields=gid,name,notes,completed_at,created_at,modified_at,memberships.section.gid,memberships.section.name,tags.gid,tags.name", config.AsanaProjectID)
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+// ENHANCED: Asana API Functions with Tag Support. Served from the tracker
+// cache; see cache.go.
+func getAsanaTasks(ctx context.Context) ([]AsanaTask, error) {
+	return engineFrom(ctx).cache.AsanaTasks(ctx)
+}
 
-	req.Header.Set("Authorization", "Bearer "+config.AsanaPAT)
+func fetchAsanaTasks(ctx context.Context) ([]AsanaTask, error) {
+	return asanaFrom(ctx).ProjectTasks(ctx, pairFrom(ctx).AsanaProjectID)
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// NEW: Delete Asana Task. The task is snapshotted first; if that fails
+// nothing is deleted.
+func deleteAsanaTask(ctx context.Context, taskID string) (*AsanaTaskSnapshot, error) {
+	snap, err := snapshotAsanaTask(ctx, taskID)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("asana API error: %d - %s", resp.StatusCode, string(body))
-	}
-
-	var asanaResp AsanaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&asanaResp); err != nil {
+	if err := asanaFrom(ctx).DeleteTask(ctx, taskID); err != nil {
 		return nil, err
 	}
 
-	return asanaResp.Data, nil
+	tenantFrom(ctx).Pairs().ForgetAsanaTask("", taskID)
+	slog.InfoContext(ctx, "Deleted Asana task", "asana_id", taskID)
+	return snap, nil
 }
 
-// NEW: Delete Asana Task
-func deleteAsanaTask(taskID string) error {
-	url := fmt.Sprintf("https://app.asana.com/api/1.0/tasks/%s", taskID)
-
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create delete request: %v", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+config.AsanaPAT)
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("network error: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("asana delete error: %d - %s", resp.StatusCode, string(body))
-	}
-
-	fmt.Printf("Successfully deleted Asana task: %s\n", taskID)
-	return nil
+// ENHANCED: YouTrack API Functions with Subsystem Support. Served from the
+// tracker cache; see cache.go.
+func getYouTrackIssues(ctx context.Context) ([]YouTrackIssue, error) {
+	return engineFrom(ctx).cache.YouTrackIssues(ctx)
 }
 
-// ENHANCED: YouTrack API Functions with Subsystem Support
-func getYouTrackIssues() ([]YouTrackIssue, error) {
-	fmt.Printf("Connecting to YouTrack Cloud: %s\n", config.YouTrackBaseURL)
-	fmt.Printf("Looking for project: %s\n", config.YouTrackProjectID)
+func fetchYouTrackIssues(ctx context.Context) (issues []YouTrackIssue, err error) {
+	ctx, span := startSpan(ctx, "youtrack.fetch_issues")
+	defer func() { endSpan(span, err) }()
+	slog.DebugContext(ctx, "Fetching YouTrack issues", "base_url", youTrackFrom(ctx).baseURL, "project", pairFrom(ctx).YouTrackProjectID)
 
-	approaches := []func() ([]YouTrackIssue, error){
-		getYouTrackIssuesWithQuery,
-		getYouTrackIssuesSimpleCloud,
-		getYouTrackIssuesViaProjects,
+	approaches := []struct {
+		name  string
+		fetch func(context.Context) ([]YouTrackIssue, error)
+	}{
+		{"query", getYouTrackIssuesWithQuery},
+		{"simple", getYouTrackIssuesSimpleCloud},
+		{"project", getYouTrackIssuesViaProjects},
 	}
 
 	for i, approach := range approaches {
-		fmt.Printf("Attempting approach %d...\n", i+1)
-		issues, err := approach()
-		if err == nil && len(issues) >= 0 {
-			fmt.Printf("Approach %d succeeded! Found %d issues\n", i+1, len(issues))
+		approachCtx, approachSpan := startSpan(ctx, "youtrack.fetch_issues."+approach.name)
+		issues, err = approach.fetch(approachCtx)
+		approachSpan.SetAttributes(attribute.Int("issues", len(issues)))
+		endSpan(approachSpan, err)
+		if err == nil {
+			span.SetAttributes(attribute.String("approach", approach.name), attribute.Int("issues", len(issues)))
+			slog.DebugContext(ctx, "YouTrack issues fetched", "approach", i+1, "issues", len(issues))
 			return issues, nil
 		}
-		fmt.Printf("Approach %d failed: %v\n", i+1, err)
+		slog.WarnContext(ctx, "YouTrack issue fetch approach failed", "approach", i+1, "error", err)
+
+		// Credentials and cancellation fail every approach the same way
+		if errors.Is(err, ErrUnauthorized) || ctx.Err() != nil {
+			return nil, err
+		}
 	}
 
 	return nil, fmt.Errorf("all approaches failed to connect to YouTrack Cloud")
 }
 
-// NEW: Delete YouTrack Issue
-func deleteYouTrackIssue(issueID string) error {
-	url := fmt.Sprintf("%s/api/issues/%s", config.YouTrackBaseURL, issueID)
-
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create delete request: %v", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// NEW: Delete YouTrack Issue. The issue is snapshotted first; if that fails
+// nothing is deleted.
+func deleteYouTrackIssue(ctx context.Context, issueID string) (*YouTrackIssueSnapshot, error) {
+	snap, err := snapshotYouTrackIssue(ctx, issueID)
 	if err != nil {
-		return fmt.Errorf("network error: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("youTrack delete error: %d - %s", resp.StatusCode, string(body))
+	if err := youTrackFrom(ctx).DeleteIssue(ctx, issueID); err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Successfully deleted YouTrack issue: %s\n", issueID)
-	return nil
+	tenantFrom(ctx).Pairs().ForgetYouTrackIssue(issueID)
+	slog.InfoContext(ctx, "Deleted YouTrack issue", "youtrack_id", issueID)
+	return snap, nil
 }
 
 // NEW: Get ticket name for a given ID (for delete operations)
-func getTicketName(ticketID string) string {
-	// Try to get from current analysis or cache
-	allTasks, err := getAsanaTasks()
+func getTicketName(ctx context.Context, ticketID string) string {
+	// Both lists come from the tracker cache, so bulk deletes do not refetch per ID
+	allTasks, err := getAsanaTasks(ctx)
 	if err == nil {
 		for _, task := range allTasks {
 			if task.GID == ticketID {
@@ -134,7 +107,7 @@ func getTicketName(ticketID string) string {
 		}
 	}
 
-	youTrackIssues, err := getYouTrackIssues()
+	youTrackIssues, err := getYouTrackIssues(ctx)
 	if err == nil {
 		for _, issue := range youTrackIssues {
 			asanaID := extractAsanaID(issue)
@@ -151,10 +124,10 @@ func getTicketName(ticketID string) string {
 }
 
 // NEW: Find YouTrack issue ID by Asana task ID
-func findYouTrackIssueByAsanaID(asanaTaskID string) (string, error) {
-	youTrackIssues, err := getYouTrackIssues()
+func findYouTrackIssueByAsanaID(ctx context.Context, asanaTaskID string) (string, error) {
+	youTrackIssues, err := getYouTrackIssues(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to get YouTrack issues: %v", err)
+		return "", fmt.Errorf("failed to get YouTrack issues: %w", err)
 	}
 
 	for _, issue := range youTrackIssues {
@@ -164,128 +137,253 @@ func findYouTrackIssueByAsanaID(asanaTaskID string) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("no YouTrack issue found for Asana task %s", asanaTaskID)
+	return "", fmt.Errorf("no YouTrack issue found for Asana task %s: %w", asanaTaskID, ErrNotFound)
 }
 
-// NEW: Bulk delete tickets
-func performBulkDelete(ticketIDs []string, source string) DeleteResponse {
-	response := DeleteResponse{
-		Source:         source,
-		RequestedCount: len(ticketIDs),
-		Results:        make([]DeleteResult, 0, len(ticketIDs)),
+// previewDelete reports, without changing anything, what deleting the given
+// IDs from source would remove
+func previewDelete(ctx context.Context, ticketIDs []string, source string) ([]DeletePreviewItem, error) {
+	tasksByID := make(map[string]AsanaTask)
+	if source == "asana" || source == "both" {
+		tasks, err := getAsanaTasks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			tasksByID[task.GID] = task
+		}
 	}
 
-	for _, ticketID := range ticketIDs {
-		result := DeleteResult{
-			TicketID:   ticketID,
-			TicketName: getTicketName(ticketID),
+	var issues []YouTrackIssue
+	if source == "youtrack" || source == "both" {
+		var err error
+		issues, err = getYouTrackIssues(ctx)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		switch source {
-		case "asana":
-			err := deleteAsanaTask(ticketID)
-			if err != nil {
-				result.Status = "failed"
-				result.AsanaResult = "failed"
-				result.Error = err.Error()
-				response.FailureCount++
-			} else {
-				result.Status = "success"
-				result.AsanaResult = "deleted"
-				response.SuccessCount++
+	items := make([]DeletePreviewItem, 0, len(ticketIDs))
+	for _, id := range ticketIDs {
+		item := DeletePreviewItem{TicketID: id, TicketName: fmt.Sprintf("Ticket-%s", id)}
+
+		if task, ok := tasksByID[id]; ok {
+			item.AsanaFound = true
+			item.TicketName = task.Name
+		}
+		for _, issue := range issues {
+			if issue.ID == id || extractAsanaID(issue) == id {
+				item.YouTrackIssueID = issue.ID
+				if !item.AsanaFound {
+					item.TicketName = issue.Summary
+				}
+				break
 			}
+		}
 
-		case "youtrack":
-			// For YouTrack deletion, we need to check if ticketID is Asana ID or YouTrack ID
-			var youtrackIssueID string
-			var err error
+		items = append(items, item)
+	}
+	return items, nil
+}
 
-			// First try to use as direct YouTrack issue ID
-			youtrackIssueID = ticketID
-			err = deleteYouTrackIssue(youtrackIssueID)
+// deleteTicket removes a single ticket from the given source using strategy
+func deleteTicket(ctx context.Context, ticketID string, source string, strategy DeleteStrategy) DeleteResult {
+	result := DeleteResult{
+		TicketID:   ticketID,
+		TicketName: getTicketName(ctx, ticketID),
+	}
+
+	// IDs actually targeted on each side, for the audit entry
+	var auditAsanaID, auditYouTrackID string
+	// What was deleted, for the trash
+	var asanaSnap *AsanaTaskSnapshot
+	var youTrackSnap *YouTrackIssueSnapshot
+	// The last error, for the failure reason in metrics
+	var failure error
+	defer func() {
+		outcome := result.Status
+		if outcome == "" {
+			outcome = "failed"
+		}
+		observeTicketOperation(ctx, auditDelete, outcome, failure)
+		after := map[string]interface{}{}
+		if result.AsanaResult != "" {
+			after["asana"] = result.AsanaResult
+		}
+		if result.YouTrackResult != "" {
+			after["youtrack"] = result.YouTrackResult
+		}
+		if result.AsanaStrategy != "" {
+			after["asana_strategy"] = result.AsanaStrategy
+		}
+		if result.YouTrackStrategy != "" {
+			after["youtrack_strategy"] = result.YouTrackStrategy
+		}
+		if result.TrashID != "" {
+			after["trash_id"] = result.TrashID
+		}
+		recordAudit(ctx, AuditEntry{
+			Action:     auditDelete,
+			AsanaID:    auditAsanaID,
+			YouTrackID: auditYouTrackID,
+			Before:     map[string]interface{}{"name": result.TicketName, "source": source},
+			After:      after,
+			Outcome:    outcome,
+			Error:      result.Error,
+		})
+	}()
 
-			// If that fails, try to find YouTrack issue by Asana ID
-			if err != nil {
-				youtrackIssueID, findErr := findYouTrackIssueByAsanaID(ticketID)
-				if findErr != nil {
-					result.Status = "failed"
-					result.YouTrackResult = "failed"
-					result.Error = fmt.Sprintf("Issue not found: %v", findErr)
-					response.FailureCount++
-				} else {
-					err = deleteYouTrackIssue(youtrackIssueID)
-					if err != nil {
-						result.Status = "failed"
-						result.YouTrackResult = "failed"
-						result.Error = err.Error()
-						response.FailureCount++
-					} else {
-						result.Status = "success"
-						result.YouTrackResult = "deleted"
-						response.SuccessCount++
-					}
-				}
-			} else {
-				result.Status = "success"
-				result.YouTrackResult = "deleted"
-				response.SuccessCount++
-			}
+	switch source {
+	case "asana":
+		auditAsanaID = ticketID
+		result.AsanaStrategy = strategy.Asana
+		outcome, snap, err := removeAsanaTask(ctx, ticketID, strategy.Asana)
+		if err != nil {
+			failure = err
+			result.Status = "failed"
+			result.AsanaResult = "failed"
+			result.Error = err.Error()
+		} else {
+			result.Status = "success"
+			result.AsanaResult = outcome
+			asanaSnap = snap
+		}
 
-		case "both":
-			asanaSuccess := true
-			youtrackSuccess := true
-			var errors []string
+	case "youtrack":
+		// For YouTrack deletion, we need to check if ticketID is Asana ID or YouTrack ID
+		var youtrackIssueID string
+		result.YouTrackStrategy = strategy.YouTrack
 
-			// Delete from Asana
-			err := deleteAsanaTask(ticketID)
-			if err != nil {
-				asanaSuccess = false
-				result.AsanaResult = "failed"
-				errors = append(errors, fmt.Sprintf("Asana: %v", err))
-			} else {
-				result.AsanaResult = "deleted"
-			}
+		// First try to use as direct YouTrack issue ID
+		youtrackIssueID = ticketID
+		auditYouTrackID = ticketID
+		outcome, snap, err := removeYouTrackIssue(ctx, youtrackIssueID, strategy.YouTrack)
 
-			// Delete from YouTrack
-			youtrackIssueID, findErr := findYouTrackIssueByAsanaID(ticketID)
+		// If the ID is not a YouTrack issue ID, try to find the issue by Asana ID
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrValidation) {
+			youtrackIssueID, findErr := findYouTrackIssueByAsanaID(ctx, ticketID)
+			auditAsanaID, auditYouTrackID = ticketID, youtrackIssueID
 			if findErr != nil {
-				youtrackSuccess = false
-				result.YouTrackResult = "not_found"
-				errors = append(errors, fmt.Sprintf("YouTrack: %v", findErr))
+				failure = findErr
+				result.Status = "failed"
+				result.YouTrackResult = "failed"
+				result.Error = fmt.Sprintf("Issue not found: %v", findErr)
 			} else {
-				err = deleteYouTrackIssue(youtrackIssueID)
+				outcome, snap, err = removeYouTrackIssue(ctx, youtrackIssueID, strategy.YouTrack)
 				if err != nil {
-					youtrackSuccess = false
+					failure = err
+					result.Status = "failed"
 					result.YouTrackResult = "failed"
-					errors = append(errors, fmt.Sprintf("YouTrack: %v", err))
+					result.Error = err.Error()
 				} else {
-					result.YouTrackResult = "deleted"
+					result.Status = "success"
+					result.YouTrackResult = outcome
+					youTrackSnap = snap
 				}
 			}
+		} else if err != nil {
+			failure = err
+			result.Status = "failed"
+			result.YouTrackResult = "failed"
+			result.Error = err.Error()
+		} else {
+			result.Status = "success"
+			result.YouTrackResult = outcome
+			youTrackSnap = snap
+		}
 
-			// Determine overall status
-			if asanaSuccess && youtrackSuccess {
-				result.Status = "success"
-				response.SuccessCount++
-			} else if asanaSuccess || youtrackSuccess {
-				result.Status = "partial"
-				response.SuccessCount++
-			} else {
-				result.Status = "failed"
-				response.FailureCount++
-			}
+	case "both":
+		asanaSuccess := true
+		youtrackSuccess := true
+		var errors []string
+		result.AsanaStrategy = strategy.Asana
+		result.YouTrackStrategy = strategy.YouTrack
 
-			if len(errors) > 0 {
-				result.Error = strings.Join(errors, "; ")
+		// Delete from Asana
+		auditAsanaID = ticketID
+		outcome, snap, err := removeAsanaTask(ctx, ticketID, strategy.Asana)
+		if err != nil {
+			failure = err
+			asanaSuccess = false
+			result.AsanaResult = "failed"
+			errors = append(errors, fmt.Sprintf("Asana: %v", err))
+		} else {
+			result.AsanaResult = outcome
+			asanaSnap = snap
+		}
+
+		// Delete from YouTrack
+		youtrackIssueID, findErr := findYouTrackIssueByAsanaID(ctx, ticketID)
+		auditYouTrackID = youtrackIssueID
+		if findErr != nil {
+			failure = findErr
+			youtrackSuccess = false
+			result.YouTrackResult = "not_found"
+			errors = append(errors, fmt.Sprintf("YouTrack: %v", findErr))
+		} else {
+			outcome, ytSnap, err := removeYouTrackIssue(ctx, youtrackIssueID, strategy.YouTrack)
+			if err != nil {
+				failure = err
+				youtrackSuccess = false
+				result.YouTrackResult = "failed"
+				errors = append(errors, fmt.Sprintf("YouTrack: %v", err))
+			} else {
+				result.YouTrackResult = outcome
+				youTrackSnap = ytSnap
 			}
+		}
 
-		default:
+		// Determine overall status
+		if asanaSuccess && youtrackSuccess {
+			result.Status = "success"
+		} else if asanaSuccess || youtrackSuccess {
+			result.Status = "partial"
+		} else {
 			result.Status = "failed"
-			result.Error = "Invalid source specified"
-			response.FailureCount++
 		}
 
-		response.Results = append(response.Results, result)
+		if len(errors) > 0 {
+			result.Error = strings.Join(errors, "; ")
+		}
+
+	default:
+		failure = ErrValidation
+		result.Status = "failed"
+		result.Error = "Invalid source specified"
+	}
+
+	if t := tenantFrom(ctx); t != nil && (asanaSnap != nil || youTrackSnap != nil) {
+		trashID, err := t.trash.Add(ctx, TrashEntry{
+			TicketID:   ticketID,
+			TicketName: result.TicketName,
+			Source:     source,
+			Asana:      asanaSnap,
+			YouTrack:   youTrackSnap,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Could not save ticket to trash", "ticket_id", ticketID, "error", err)
+		} else {
+			result.TrashID = trashID
+		}
+	}
+
+	return result
+}
+
+// summarizeDeleteResults builds the bulk delete response from per-ticket results
+func summarizeDeleteResults(source string, requested int, results []DeleteResult) DeleteResponse {
+	response := DeleteResponse{
+		Source:         source,
+		RequestedCount: requested,
+		Results:        results,
+	}
+
+	for _, result := range results {
+		if result.Status == "failed" {
+			response.FailureCount++
+		} else {
+			response.SuccessCount++
+		}
 	}
 
 	// Set overall status
@@ -303,99 +401,81 @@ func performBulkDelete(ticketIDs []string, source string) DeleteResponse {
 	return response
 }
 
-func getYouTrackIssuesWithQuery() ([]YouTrackIssue, error) {
+func getYouTrackIssuesWithQuery(ctx context.Context) ([]YouTrackIssue, error) {
+	project := pairFrom(ctx).YouTrackProjectID
 	queries := []string{
-		fmt.Sprintf("project:%s", config.YouTrackProjectID),
-		fmt.Sprintf("project: %s", config.YouTrackProjectID),
-		fmt.Sprintf("#%s", config.YouTrackProjectID),
+		fmt.Sprintf("project:%s", project),
+		fmt.Sprintf("project: %s", project),
+		fmt.Sprintf("#%s", project),
 	}
 
 	fields := "id,summary,description,created,updated,customFields(name,value(name,localizedName,description,id,$type,color)),project(shortName)"
 
-	for i, query := range queries {
-		fmt.Printf("   Query format %d: %s\n", i+1, query)
-
-		encodedQuery := strings.ReplaceAll(query, " ", "%20")
-		url := fmt.Sprintf("%s/api/issues?fields=%s&query=%s&top=200",
-			config.YouTrackBaseURL, fields, encodedQuery)
+	var lastErr error
+	for _, query := range queries {
+		issues, err := allYouTrackPages(func(skip, top int) ([]YouTrackIssue, error) {
+			return youTrackFrom(ctx).IssuesPage(ctx, query, fields, skip, top)
+		})
+		if err == nil {
+			return issues, nil
+		}
 
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			continue
+		slog.DebugContext(ctx, "YouTrack issue query failed", "query", query, "error", err)
+		lastErr = err
+		if errors.Is(err, ErrUnauthorized) || ctx.Err() != nil {
+			break
 		}
+	}
 
-		req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("Cache-Control", "no-cache")
+	return nil, fmt.Errorf("query approach failed: %w", lastErr)
+}
 
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			fmt.Printf("   Network error: %v\n", err)
-			continue
-		}
+// fetchYouTrackIssuesUpdatedSince returns the project's issues updated since
+// the given time. YouTrack interprets query dates in the user's time zone, so
+// the query asks for whole days from a day earlier; extra issues are harmless.
+// The delta is paged to the end, since the cache treats it as complete.
+func fetchYouTrackIssuesUpdatedSince(ctx context.Context, since time.Time) ([]YouTrackIssue, error) {
+	fields := "id,summary,description,created,updated,customFields(name,value(name,localizedName,description,id,$type,color)),project(shortName)"
+	query := fmt.Sprintf("project: %s updated: %s .. *", pairFrom(ctx).YouTrackProjectID, since.Add(-24*time.Hour).UTC().Format("2006-01-02"))
 
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
+	return allYouTrackPages(func(skip, top int) ([]YouTrackIssue, error) {
+		return youTrackFrom(ctx).IssuesPage(ctx, query, fields, skip, top)
+	})
+}
 
-		fmt.Printf("   Status: %d\n", resp.StatusCode)
+// allYouTrackPages calls fetch with $skip/$top pages until a short page
+// shows the end was reached.
+func allYouTrackPages(fetch func(skip, top int) ([]YouTrackIssue, error)) ([]YouTrackIssue, error) {
+	const pageSize = 200
 
-		if resp.StatusCode == http.StatusOK {
-			var issues []YouTrackIssue
-			if err := json.Unmarshal(body, &issues); err != nil {
-				fmt.Printf("   JSON error: %v\n", err)
-				continue
-			}
+	var issues []YouTrackIssue
+	for {
+		page, err := fetch(len(issues), pageSize)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, page...)
+		if len(page) < pageSize {
 			return issues, nil
 		}
 	}
-
-	return nil, fmt.Errorf("query approach failed")
 }
 
-func getYouTrackIssuesSimpleCloud() ([]YouTrackIssue, error) {
-	fmt.Println("   Trying simple issues endpoint...")
-
-	url := fmt.Sprintf("%s/api/issues?fields=id,summary,description,created,updated,customFields(name,value(name,localizedName,description,id,$type)),project(shortName)&top=200",
-		config.YouTrackBaseURL)
-
-	req, err := http.NewRequest("GET", url, nil)
+func getYouTrackIssuesSimpleCloud(ctx context.Context) ([]YouTrackIssue, error) {
+	fields := "id,summary,description,created,updated,customFields(name,value(name,localizedName,description,id,$type)),project(shortName)"
+	allIssues, err := allYouTrackPages(func(skip, top int) ([]YouTrackIssue, error) {
+		return youTrackFrom(ctx).IssuesPage(ctx, "", fields, skip, top)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("network error: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("   Status: %d\n", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		bodyStr := string(body)
-		if len(bodyStr) > 300 {
-			bodyStr = bodyStr[:300] + "..."
-		}
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, bodyStr)
-	}
-
-	var allIssues []YouTrackIssue
-
-	if err := json.Unmarshal(body, &allIssues); err != nil {
-		return nil, fmt.Errorf("JSON parsing error: %v", err)
-	}
-
+	project := pairFrom(ctx).YouTrackProjectID
 	var projectIssues []YouTrackIssue
-	fmt.Printf("   Filtering %d total issues for project '%s'\n", len(allIssues), config.YouTrackProjectID)
+	slog.DebugContext(ctx, "Filtering YouTrack issues by project", "issues", len(allIssues), "project", project)
 
 	for _, issue := range allIssues {
-		if issue.Project.ShortName == config.YouTrackProjectID {
+		if issue.Project.ShortName == project {
 			projectIssues = append(projectIssues, issue)
 		}
 	}
@@ -403,212 +483,39 @@ func getYouTrackIssuesSimpleCloud() ([]YouTrackIssue, error) {
 	return projectIssues, nil
 }
 
-func getYouTrackIssuesViaProjects() ([]YouTrackIssue, error) {
-	fmt.Println("   Trying project-specific endpoint...")
-
-	url := fmt.Sprintf("%s/api/admin/projects/%s/issues?fields=id,summary,description,created,updated,customFields(name,value(name,localizedName)),project(shortName)&top=200",
-		config.YouTrackBaseURL, config.YouTrackProjectID)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("network error: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("   Status: %d\n", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("project endpoint failed with status %d", resp.StatusCode)
-	}
-
-	var issues []YouTrackIssue
-	if err := json.Unmarshal(body, &issues); err != nil {
-		return nil, fmt.Errorf("JSON parsing error: %v", err)
-	}
-
-	return issues, nil
+func getYouTrackIssuesViaProjects(ctx context.Context) ([]YouTrackIssue, error) {
+	fields := "id,summary,description,created,updated,customFields(name,value(name,localizedName)),project(shortName)"
+	return allYouTrackPages(func(skip, top int) ([]YouTrackIssue, error) {
+		return youTrackFrom(ctx).ProjectIssuesPage(ctx, pairFrom(ctx).YouTrackProjectID, fields, skip, top)
+	})
 }
 
-func findYouTrackProject() (string, error) {
-	fmt.Println("Testing YouTrack Cloud connection...")
-	fmt.Printf("URL: %s\n", config.YouTrackBaseURL)
-	fmt.Printf("Project: %s\n", config.YouTrackProjectID)
-
-	url := fmt.Sprintf("%s/api/admin/projects?fields=id,name,shortName&top=10", config.YouTrackBaseURL)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Cache-Control", "no-cache")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("connection failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	fmt.Printf("Response status: %d\n", resp.StatusCode)
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Trying alternative projects endpoint...")
-		return findYouTrackProjectAlternative()
-	}
-
-	var projects []struct {
-		ID        string `json:"id"`
-		Name      string `json:"name"`
-		ShortName string `json:"shortName"`
-	}
-
-	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&projects); err != nil {
-		fmt.Printf("JSON decode error: %v\n", err)
-		return "", err
-	}
-
-	fmt.Printf("Found %d projects\n", len(projects))
-
-	for _, proj := range projects {
-		if proj.ID == config.YouTrackProjectID || proj.ShortName == config.YouTrackProjectID {
-			fmt.Printf("Found matching project: %s (%s)\n", proj.Name, proj.ShortName)
-			return proj.ShortName, nil
-		}
-	}
-
-	return "", fmt.Errorf("project '%s' not found", config.YouTrackProjectID)
-}
-
-func findYouTrackProjectAlternative() (string, error) {
-	url := fmt.Sprintf("%s/api/projects?fields=id,name,shortName", config.YouTrackBaseURL)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("alternative connection failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Alternative endpoint status: %d\n", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("alternative endpoint failed: %d", resp.StatusCode)
-	}
-
-	var projects []struct {
-		ID        string `json:"id"`
-		Name      string `json:"name"`
-		ShortName string `json:"shortName"`
-	}
-
-	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&projects); err != nil {
-		return "", fmt.Errorf("JSON decode error: %v", err)
-	}
-
-	fmt.Printf("Alternative endpoint found %d projects\n", len(projects))
+// Reasons createYouTrackIssue refuses a task
+var (
+	errDuplicateTicket   = errors.New("already exists in YouTrack")
+	errDisplayOnlyColumn = errors.New("cannot create ticket for display-only column")
+)
 
-	for _, proj := range projects {
-		if proj.ID == config.YouTrackProjectID || proj.ShortName == config.YouTrackProjectID {
-			fmt.Printf("Found project: %s (%s)\n", proj.Name, proj.ShortName)
-			return proj.ShortName, nil
+// ENHANCED: Create YouTrack Issue with Tag/Subsystem Support. This is the
+// one place the duplicate check runs; callers tell a skipped duplicate from
+// a failure with errors.Is(err, errDuplicateTicket).
+func createYouTrackIssue(ctx context.Context, task AsanaTask) (err error) {
+	defer func() {
+		outcome, _ := auditOutcome(err)
+		if errors.Is(err, errDuplicateTicket) {
+			outcome = "skipped"
 		}
-	}
-
-	return "", fmt.Errorf("project '%s' not found in %d available projects", config.YouTrackProjectID, len(projects))
-}
-
-func listYouTrackProjects() {
-	fmt.Println("Let me list all available projects...")
-
-	url := fmt.Sprintf("%s/api/admin/projects?fields=id,name,shortName&top=20", config.YouTrackBaseURL)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		fmt.Printf("Error creating request: %v\n", err)
-		return
-	}
-
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Cache-Control", "no-cache")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("Error connecting to YouTrack: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Projects API Response Status: %d\n", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Raw response: %s\n", string(body))
-		return
-	}
-
-	var projects []struct {
-		ID        string `json:"id"`
-		Name      string `json:"name"`
-		ShortName string `json:"shortName"`
-	}
-
-	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&projects); err != nil {
-		fmt.Printf("Error parsing JSON: %v\n", err)
-		fmt.Printf("Response was: %s\n", string(body))
-		return
-	}
-
-	if len(projects) == 0 {
-		fmt.Println("No projects found - check your token permissions")
-		return
-	}
-
-	fmt.Printf("Found %d projects:\n\n", len(projects))
-	for i, proj := range projects {
-		fmt.Printf("   %d. Name: %s\n", i+1, proj.Name)
-		fmt.Printf("      Key: %s (use this in .env)\n", proj.ShortName)
-		fmt.Printf("      ID: %s\n\n", proj.ID)
-	}
-
-	fmt.Println("Copy one of the 'Key' values above and update your .env file:")
-	fmt.Printf("   YOUTRACK_PROJECT_ID=<paste_key_here>\n")
-}
+		observeTicketOperation(ctx, auditCreate, outcome, err)
+	}()
 
-// ENHANCED: Create YouTrack Issue with Tag/Subsystem Support
-func createYouTrackIssue(task AsanaTask) error {
-	if isDuplicateTicket(task.Name) {
-		return fmt.Errorf("ticket with title '%s' already exists in YouTrack", task.Name)
+	if isDuplicateTicket(ctx, task.Name) {
+		return fmt.Errorf("ticket with title '%s' %w", task.Name, errDuplicateTicket)
 	}
 
 	state := mapAsanaStateToYouTrack(task)
 
-	if state == "FINDINGS_NO_SYNC" || state == "READY_FOR_STAGE_NO_SYNC" {
-		return fmt.Errorf("cannot create ticket for display-only column")
+	if strings.HasSuffix(state, noSyncStateSuffix) {
+		return errDisplayOnlyColumn
 	}
 
 	payload := map[string]interface{}{
@@ -617,143 +524,206 @@ func createYouTrackIssue(task AsanaTask) error {
 		"description": fmt.Sprintf("%s\n\n[Synced from Asana ID: %s]", task.Notes, task.GID),
 		"project": map[string]interface{}{
 			"$type":     "Project",
-			"shortName": config.YouTrackProjectID,
+			"shortName": pairFrom(ctx).YouTrackProjectID,
 		},
 	}
 
-	customFields := []map[string]interface{}{}
-
-	if state != "" {
-		customFields = append(customFields, map[string]interface{}{
-			"$type": "StateIssueCustomField",
-			"name":  "State",
-			"value": map[string]interface{}{
-				"$type": "StateBundleElement",
-				"name":  state,
-			},
-		})
-	}
-
 	asanaTags := getAsanaTags(task)
+	subsystem := ""
 	if len(asanaTags) > 0 {
-		primaryTag := asanaTags[0]
-		subsystem := mapTagToSubsystem(primaryTag)
-		if subsystem != "" {
-			customFields = append(customFields, map[string]interface{}{
-				"$type": "MultiOwnedIssueCustomField",
-				"name":  "Subsystem",
-				"value": []map[string]interface{}{
-					{
-						"$type": "OwnedBundleElement",
-						"name":  subsystem,
-					},
-				},
-			})
-		}
+		subsystem = mapTagToSubsystem(ctx, asanaTags[0])
 	}
 
+	customFields, notes, err := syncedFields(ctx, state, subsystem)
+	if err != nil {
+		return fmt.Errorf("YouTrack create error: %w", err)
+	}
 	if len(customFields) > 0 {
 		payload["customFields"] = customFields
 	}
 
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
+	issueID, err := youTrackFrom(ctx).CreateIssue(ctx, payload)
+	forgetFieldSchemaOn(ctx, err)
 
-	url := fmt.Sprintf("%s/api/issues", config.YouTrackBaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return err
+	after := map[string]interface{}{"summary": task.Name, "state": state}
+	if subsystem != "" {
+		after["subsystem"] = subsystem
 	}
+	outcome, errText := auditOutcome(err)
+	recordAudit(ctx, AuditEntry{
+		Action:     auditCreate,
+		AsanaID:    task.GID,
+		YouTrackID: issueID,
+		After:      after,
+		Outcome:    outcome,
+		Error:      errText,
+	})
 
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return fmt.Errorf("YouTrack create error: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("YouTrack create error: %d - %s", resp.StatusCode, string(body))
-	}
-
-	// FIXED: Only log tags if we have them
-	if len(asanaTags) > 0 {
-		fmt.Printf("Created ticket with tags: %v\n", asanaTags)
+	slog.InfoContext(ctx, "Created YouTrack issue", "asana_id", task.GID, "youtrack_id", issueID, "tags", asanaTags)
+	for _, note := range notes {
+		slog.WarnContext(ctx, "Created YouTrack issue without Subsystem", "asana_id", task.GID, "youtrack_id", issueID, "reason", note)
 	}
 
 	return nil
 }
 
-func isDuplicateTicket(title string) bool {
-	query := fmt.Sprintf("project:%s summary:%s", config.YouTrackProjectID, title)
-	encodedQuery := strings.ReplaceAll(query, " ", "%20")
+func isDuplicateTicket(ctx context.Context, title string) (duplicate bool) {
+	ctx, span := startSpan(ctx, "youtrack.duplicate_check")
+	defer func() {
+		span.SetAttributes(attribute.Bool("duplicate", duplicate))
+		span.End()
+	}()
 
-	url := fmt.Sprintf("%s/api/issues?fields=id,summary&query=%s&top=5",
-		config.YouTrackBaseURL, encodedQuery)
+	query := fmt.Sprintf("project:%s summary:%s", pairFrom(ctx).YouTrackProjectID, title)
 
-	req, err := http.NewRequest("GET", url, nil)
+	issues, err := youTrackFrom(ctx).Issues(ctx, query, "id,summary", 5)
 	if err != nil {
 		return false
 	}
 
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Accept", "application/json")
+	for _, issue := range issues {
+		if strings.EqualFold(issue.Summary, title) {
+			return true
+		}
+	}
 
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return false
+	return false
+}
+
+// createMissingTask creates the YouTrack issue for one missing Asana task and
+// reports the outcome in the shape returned by /create
+func createMissingTask(ctx context.Context, engine *SyncEngine, task AsanaTask) map[string]interface{} {
+	asanaTags := getAsanaTags(task)
+
+	result := map[string]interface{}{
+		"task_id":    task.GID,
+		"task_name":  task.Name,
+		"asana_tags": asanaTags,
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return false
+	if engine.IsIgnored(task.GID) {
+		result["status"] = "skipped"
+		result["reason"] = "Ticket is ignored"
+	} else {
+		err := createYouTrackIssue(ctx, task)
+		if errors.Is(err, errDuplicateTicket) {
+			result["status"] = "skipped"
+			result["reason"] = "Duplicate ticket already exists"
+		} else if err != nil {
+			result["status"] = "failed"
+			result["error"] = err.Error()
+		} else {
+			result["status"] = "created"
+			if len(asanaTags) > 0 {
+				primaryTag := asanaTags[0]
+				mappedSubsystem := mapTagToSubsystem(ctx, primaryTag)
+				result["mapped_subsystem"] = mappedSubsystem
+			}
+		}
 	}
 
-	var issues []YouTrackIssue
-	if err := json.NewDecoder(resp.Body).Decode(&issues); err != nil {
-		return false
+	return result
+}
+
+// syncTicketRequest applies one /sync action and reports the outcome
+func syncTicketRequest(ctx context.Context, engine *SyncEngine, req SyncRequest, mismatchMap map[string]MismatchedTicket) map[string]interface{} {
+	result := map[string]interface{}{
+		"ticket_id": req.TicketID,
+		"action":    req.Action,
 	}
 
-	for _, issue := range issues {
-		if strings.EqualFold(issue.Summary, title) {
-			return true
+	ticket, exists := mismatchMap[req.TicketID]
+	if !exists {
+		result["status"] = "failed"
+		result["error"] = "Ticket not found in mismatched list"
+		return result
+	}
+
+	switch req.Action {
+	case "sync":
+		if engine.IsIgnored(req.TicketID) {
+			result["status"] = "skipped"
+			result["reason"] = "Ticket is ignored"
+		} else {
+			err := syncMismatchedTicket(ctx, ticket)
+			if err != nil {
+				result["status"] = "failed"
+				result["error"] = err.Error()
+			} else {
+				result["status"] = "synced"
+				result["status_change"] = map[string]string{
+					"from": ticket.YouTrackStatus,
+					"to":   ticket.AsanaStatus,
+				}
+
+				asanaTags := getAsanaTags(ticket.AsanaTask)
+				if len(asanaTags) > 0 {
+					primaryTag := asanaTags[0]
+					mappedSubsystem := mapTagToSubsystem(ctx, primaryTag)
+					result["tag_sync"] = map[string]interface{}{
+						"asana_tags":         asanaTags,
+						"mapped_subsystem":   mappedSubsystem,
+						"previous_subsystem": ticket.YouTrackSubsystem,
+					}
+				}
+			}
 		}
+
+	case "ignore_temp":
+		engine.Ignore(req.TicketID, false)
+		recordIgnore(ctx, auditIgnore, req.TicketID, false)
+		result["status"] = "ignored_temporarily"
+
+	case "ignore_forever":
+		engine.Ignore(req.TicketID, true)
+		recordIgnore(ctx, auditIgnore, req.TicketID, true)
+		result["status"] = "ignored_permanently"
+
+	default:
+		result["status"] = "failed"
+		result["error"] = "Invalid action"
 	}
 
-	return false
+	return result
 }
 
 // Analysis Functions
-func performTicketAnalysis(selectedColumns []string) (*TicketAnalysis, error) {
-	fmt.Printf("Starting analysis for columns: %v\n", selectedColumns) // DEBUG
-
-	allAsanaTasks, err := getAsanaTasks()
+func performTicketAnalysis(ctx context.Context, engine *SyncEngine, selectedColumns []string) (analysis *TicketAnalysis, err error) {
+	slog.DebugContext(ctx, "Starting analysis", "columns", selectedColumns)
+	started := time.Now()
+	defer func() { observeAnalysis(ctx, started, analysis, err) }()
+	ctx, span := startSpan(ctx, "analysis", trace.WithAttributes(attribute.StringSlice("columns", selectedColumns)))
+	defer func() { endSpan(span, err) }()
+
+	// Each phase gets a child span so slow trackers stand out
+	phaseCtx, phase := startSpan(ctx, "analysis.asana_tasks")
+	allAsanaTasks, err := getAsanaTasks(phaseCtx)
+	phase.SetAttributes(attribute.Int("tasks", len(allAsanaTasks)))
+	endSpan(phase, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get Asana tasks: %v", err)
+		return nil, fmt.Errorf("failed to get Asana tasks: %w", err)
 	}
 
-	fmt.Printf("Retrieved %d total Asana tasks\n", len(allAsanaTasks)) // DEBUG
-
 	// FIXED: Filter tasks by the specified columns
 	asanaTasks := filterAsanaTasksByColumns(allAsanaTasks, selectedColumns)
 
-	fmt.Printf("After filtering by columns %v: %d tasks remain\n", selectedColumns, len(asanaTasks)) // DEBUG
+	slog.DebugContext(ctx, "Asana tasks retrieved", "total", len(allAsanaTasks), "in_columns", len(asanaTasks))
 
-	youTrackIssues, err := getYouTrackIssues()
+	phaseCtx, phase = startSpan(ctx, "analysis.youtrack_issues")
+	youTrackIssues, err := getYouTrackIssues(phaseCtx)
+	phase.SetAttributes(attribute.Int("issues", len(youTrackIssues)))
+	endSpan(phase, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get YouTrack issues: %v", err)
+		return nil, fmt.Errorf("failed to get YouTrack issues: %w", err)
 	}
 
-	fmt.Printf("Retrieved %d YouTrack issues\n", len(youTrackIssues)) // DEBUG
+	slog.DebugContext(ctx, "YouTrack issues retrieved", "total", len(youTrackIssues))
+
+	_, phase = startSpan(ctx, "analysis.match")
 
 	youTrackMap := make(map[string]YouTrackIssue)
 	asanaMap := make(map[string]AsanaTask)
@@ -769,7 +739,7 @@ func performTicketAnalysis(selectedColumns []string) (*TicketAnalysis, error) {
 		asanaMap[task.GID] = task
 	}
 
-	analysis := &TicketAnalysis{
+	analysis = &TicketAnalysis{
 		SelectedColumn:   strings.Join(selectedColumns, ", "), // FIXED: Show actual selected columns
 		Matched:          []MatchedTicket{},
 		Mismatched:       []MismatchedTicket{},
@@ -779,12 +749,12 @@ func performTicketAnalysis(selectedColumns []string) (*TicketAnalysis, error) {
 		ReadyForStage:    []AsanaTask{},
 		BlockedTickets:   []MatchedTicket{},
 		OrphanedYouTrack: []YouTrackIssue{},
-		Ignored:          getMapKeys(ignoredTicketsForever),
+		Ignored:          engine.IgnoredForever(),
 	}
 
 	// Continue with the rest of the analysis logic...
 	for _, task := range asanaTasks {
-		if isIgnored(task.GID) {
+		if engine.IsIgnored(task.GID) {
 			continue
 		}
 
@@ -863,17 +833,67 @@ func performTicketAnalysis(selectedColumns []string) (*TicketAnalysis, error) {
 		}
 	}
 
-	fmt.Printf("Analysis complete: %d matched, %d mismatched, %d missing\n",
-		len(analysis.Matched), len(analysis.Mismatched), len(analysis.MissingYouTrack)) // DEBUG
+	phase.SetAttributes(attribute.Int("matched", len(analysis.Matched)), attribute.Int("mismatched", len(analysis.Mismatched)),
+		attribute.Int("missing_youtrack", len(analysis.MissingYouTrack)))
+	phase.End()
+
+	phaseCtx, phase = startSpan(ctx, "analysis.bundle_values")
+	detectMissingBundleValues(phaseCtx, engine, analysis)
+	phase.End()
+
+	notifyFindingsAlerts(ctx, analysis.FindingsAlerts)
+
+	slog.DebugContext(ctx, "Analysis complete",
+		"matched", len(analysis.Matched), "mismatched", len(analysis.Mismatched), "missing", len(analysis.MissingYouTrack))
 
 	return analysis, nil
 }
 
+// syncMismatchedTicket pushes the Asana state and tags of a mismatched ticket
+// to its YouTrack issue and records the change in the audit log
+func syncMismatchedTicket(ctx context.Context, ticket MismatchedTicket) error {
+	err := updateYouTrackIssue(ctx, ticket.YouTrackIssue.ID, ticket.AsanaTask)
+
+	after := map[string]interface{}{"state": ticket.AsanaStatus}
+	if tags := getAsanaTags(ticket.AsanaTask); len(tags) > 0 {
+		after["subsystem"] = mapTagToSubsystem(ctx, tags[0])
+	}
+	outcome, errText := auditOutcome(err)
+	observeTicketOperation(ctx, auditSync, outcome, err)
+	recordAudit(ctx, AuditEntry{
+		Action:     auditSync,
+		AsanaID:    ticket.AsanaTask.GID,
+		YouTrackID: ticket.YouTrackIssue.ID,
+		Before: map[string]interface{}{
+			"state":     ticket.YouTrackStatus,
+			"subsystem": ticket.YouTrackSubsystem,
+		},
+		After:   after,
+		Outcome: outcome,
+		Error:   errText,
+	})
+
+	return err
+}
+
+// recordIgnore audits a change to the ignore list
+func recordIgnore(ctx context.Context, action, ticketID string, forever bool) {
+	scope := "temporary"
+	if forever {
+		scope = "forever"
+	}
+	recordAudit(ctx, AuditEntry{
+		Action:  action,
+		AsanaID: ticketID,
+		After:   map[string]interface{}{"scope": scope},
+	})
+}
+
 // FIXED: Complete updateYouTrackIssue function
-func updateYouTrackIssue(issueID string, task AsanaTask) error {
+func updateYouTrackIssue(ctx context.Context, issueID string, task AsanaTask) error {
 	state := mapAsanaStateToYouTrack(task)
 
-	if state == "FINDINGS_NO_SYNC" || state == "READY_FOR_STAGE_NO_SYNC" {
+	if strings.HasSuffix(state, noSyncStateSuffix) {
 		return fmt.Errorf("cannot update ticket for display-only column")
 	}
 
@@ -883,131 +903,29 @@ func updateYouTrackIssue(issueID string, task AsanaTask) error {
 		"description": fmt.Sprintf("%s\n\n[Synced from Asana ID: %s]", task.Notes, task.GID),
 	}
 
-	customFields := []map[string]interface{}{}
-
-	if state != "" {
-		customFields = append(customFields, map[string]interface{}{
-			"$type": "StateIssueCustomField",
-			"name":  "State",
-			"value": map[string]interface{}{
-				"$type": "StateBundleElement",
-				"name":  state,
-			},
-		})
-	}
-
 	asanaTags := getAsanaTags(task)
+	subsystem := ""
 	if len(asanaTags) > 0 {
-		primaryTag := asanaTags[0]
-		subsystem := mapTagToSubsystem(primaryTag)
-		if subsystem != "" {
-			customFields = append(customFields, map[string]interface{}{
-				"$type": "MultiOwnedIssueCustomField",
-				"name":  "Subsystem",
-				"value": []map[string]interface{}{
-					{
-						"$type": "OwnedBundleElement",
-						"name":  subsystem,
-					},
-				},
-			})
-		}
-	}
-
-	if len(customFields) > 0 {
-		payload["customFields"] = customFields
-	}
-
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("%s/api/issues/%s", config.YouTrackBaseURL, issueID)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		bodyStr := string(body)
-		if strings.Contains(bodyStr, "incompatible-issue-custom-field-name-Subsystem") {
-			return updateYouTrackIssueWithoutSubsystem(issueID, task)
-		}
-		return fmt.Errorf("YouTrack update error: %d - %s", resp.StatusCode, bodyStr)
-	}
-
-	if len(asanaTags) > 0 {
-		fmt.Printf("Successfully updated ticket %s with tags: %v\n", issueID, asanaTags)
-	}
-
-	return nil
-}
-
-func updateYouTrackIssueWithoutSubsystem(issueID string, task AsanaTask) error {
-	state := mapAsanaStateToYouTrack(task)
-
-	payload := map[string]interface{}{
-		"$type":       "Issue",
-		"summary":     task.Name,
-		"description": fmt.Sprintf("%s\n\n[Synced from Asana ID: %s]", task.Notes, task.GID),
+		subsystem = mapTagToSubsystem(ctx, asanaTags[0])
 	}
 
-	if state != "" {
-		payload["customFields"] = []map[string]interface{}{
-			{
-				"$type": "StateIssueCustomField",
-				"name":  "State",
-				"value": map[string]interface{}{
-					"$type": "StateBundleElement",
-					"name":  state,
-				},
-			},
-		}
-	}
-
-	jsonPayload, err := json.Marshal(payload)
+	customFields, notes, err := syncedFields(ctx, state, subsystem)
 	if err != nil {
-		return err
+		return fmt.Errorf("YouTrack update error: %w", err)
 	}
-
-	url := fmt.Sprintf("%s/api/issues/%s", config.YouTrackBaseURL, issueID)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+config.YouTrackToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+	if len(customFields) > 0 {
+		payload["customFields"] = customFields
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("YouTrack update error: %d - %s", resp.StatusCode, string(body))
+	if err := youTrackFrom(ctx).UpdateIssue(ctx, issueID, payload); err != nil {
+		forgetFieldSchemaOn(ctx, err)
+		return fmt.Errorf("YouTrack update error: %w", err)
 	}
 
-	asanaTags := getAsanaTags(task)
-	if len(asanaTags) > 0 {
-		fmt.Printf("Updated ticket %s (status only - Subsystem field not available). Tags: %v\n", issueID, asanaTags)
+	if len(notes) > 0 {
+		slog.WarnContext(ctx, "Updated YouTrack issue without Subsystem", "asana_id", task.GID, "youtrack_id", issueID, "reason", strings.Join(notes, "; "), "tags", asanaTags)
+	} else {
+		slog.InfoContext(ctx, "Updated YouTrack issue", "asana_id", task.GID, "youtrack_id", issueID, "tags", asanaTags)
 	}
 
 	return nil
@@ -1024,13 +942,15 @@ func getAsanaTags(task AsanaTask) []string {
 	return tags
 }
 
-func mapTagToSubsystem(asanaTag string) string {
-	if subsystem, exists := defaultTagMapping[asanaTag]; exists {
+// mapTagToSubsystem uses the tag mapping of the pair in ctx
+func mapTagToSubsystem(ctx context.Context, asanaTag string) string {
+	mapping := pairFrom(ctx).tagMapping()
+	if subsystem, exists := mapping[asanaTag]; exists {
 		return subsystem
 	}
 
 	asanaTagLower := strings.ToLower(asanaTag)
-	if subsystem, exists := defaultTagMapping[asanaTagLower]; exists {
+	if subsystem, exists := mapping[asanaTagLower]; exists {
 		return subsystem
 	}
 
@@ -1039,29 +959,10 @@ func mapTagToSubsystem(asanaTag string) string {
 
 func mapAsanaStateToYouTrack(task AsanaTask) string {
 	if len(task.Memberships) == 0 {
-		return "Backlog"
-	}
-
-	sectionName := strings.ToLower(task.Memberships[0].Section.Name)
-
-	switch {
-	case strings.Contains(sectionName, "backlog"):
-		return "Backlog"
-	case strings.Contains(sectionName, "in progress"):
-		return "In Progress"
-	case strings.Contains(sectionName, "dev") && !strings.Contains(sectionName, "ready"):
-		return "DEV"
-	case strings.Contains(sectionName, "stage") && !strings.Contains(sectionName, "ready"):
-		return "STAGE"
-	case strings.Contains(sectionName, "blocked"):
-		return "Blocked"
-	case strings.Contains(sectionName, "findings"):
-		return "FINDINGS_NO_SYNC"
-	case strings.Contains(sectionName, "ready for stage"):
-		return "READY_FOR_STAGE_NO_SYNC"
-	default:
-		return "Backlog"
+		return currentConfig().Columns.DefaultState
 	}
+
+	return currentConfig().Columns.stateFor(strings.ToLower(task.Memberships[0].Section.Name))
 }
 
 func getYouTrackStatus(issue YouTrackIssue) string {
@@ -1111,7 +1012,7 @@ func getSectionName(task AsanaTask) string {
 
 func isSyncableColumn(sectionName string) bool {
 	sectionLower := strings.ToLower(sectionName)
-	for _, col := range syncableColumns {
+	for _, col := range currentConfig().Columns.Syncable {
 		if strings.Contains(sectionLower, strings.ToLower(col)) {
 			return true
 		}
@@ -1120,8 +1021,7 @@ func isSyncableColumn(sectionName string) bool {
 }
 
 func isActiveYouTrackStatus(status string) bool {
-	activeStatuses := []string{"Backlog", "In Progress", "DEV", "STAGE", "Blocked"}
-	for _, activeStatus := range activeStatuses {
+	for _, activeStatus := range currentConfig().Columns.activeStates() {
 		if strings.EqualFold(status, activeStatus) {
 			return true
 		}
@@ -1173,14 +1073,9 @@ func filterAsanaTasksByColumns(tasks []AsanaTask, selectedColumns []string) []As
 		}
 	}
 
-	fmt.Printf("Filtered %d tasks from %d total for columns: %v\n", len(filtered), len(tasks), selectedColumns)
 	return filtered
 }
 
-func isIgnored(ticketID string) bool {
-	return ignoredTicketsTemp[ticketID] || ignoredTicketsForever[ticketID]
-}
-
 func getMapKeys(m map[string]bool) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
@@ -1189,26 +1084,4 @@ func getMapKeys(m map[string]bool) []string {
 	return keys
 }
 
-func loadIgnoredTickets() {
-	data, err := os.ReadFile("ignored_tickets.json")
-	if err != nil {
-		return
-	}
-
-	var ignored []string
-	if err := json.Unmarshal(data, &ignored); err != nil {
-		return
-	}
-
-	for _, id := range ignored {
-		ignoredTicketsForever[id] = true
-	}
-}
-
-func saveIgnoredTickets() {
-	ignored := getMapKeys(ignoredTicketsForever)
-	data, _ := json.MarshalIndent(ignored, "", "  ")
-	os.WriteFile("ignored_tickets.json", data, 0644)
-}
-
 // simran
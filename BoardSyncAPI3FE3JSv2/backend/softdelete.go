@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Deletion strategies. "hard" removes the ticket (and keeps a snapshot in the
+// trash); the others leave it in the tracker, out of the way.
+const (
+	strategyHard           = "hard"
+	strategyComplete       = "complete"        // Asana: mark the task completed
+	strategyArchiveSection = "archive_section" // Asana: move the task to the archive section
+	strategyResolve        = "resolve"         // YouTrack: set the resolved state
+	strategyTag            = "tag"             // YouTrack: add the archive tag
+)
+
+var asanaStrategies = []string{strategyHard, strategyComplete, strategyArchiveSection}
+var youTrackStrategies = []string{strategyHard, strategyResolve, strategyTag}
+
+// DeleteStrategy says how each side of a ticket is removed. Empty sides
+// use the configured default.
+type DeleteStrategy struct {
+	Asana    string `json:"asana,omitempty"`
+	YouTrack string `json:"youtrack,omitempty"`
+}
+
+func (s DeleteStrategy) String() string {
+	return "asana=" + s.Asana + ",youtrack=" + s.YouTrack
+}
+
+// withDefaults fills empty sides from the configured default
+func (s DeleteStrategy) withDefaults() DeleteStrategy {
+	if s.Asana == "" {
+		s.Asana = currentConfig().DeleteStrategy.Asana
+	}
+	if s.YouTrack == "" {
+		s.YouTrack = currentConfig().DeleteStrategy.YouTrack
+	}
+	return s
+}
+
+// Validate checks both sides are known and that what they need is configured.
+func (s DeleteStrategy) Validate() error {
+	return s.validate(currentConfig().AsanaArchiveSectionID)
+}
+
+func (s DeleteStrategy) validate(archiveSectionID string) error {
+	if !containsString(asanaStrategies, s.Asana) {
+		return fmt.Errorf("unknown Asana strategy %q (valid: %v)", s.Asana, asanaStrategies)
+	}
+	if !containsString(youTrackStrategies, s.YouTrack) {
+		return fmt.Errorf("unknown YouTrack strategy %q (valid: %v)", s.YouTrack, youTrackStrategies)
+	}
+	if s.Asana == strategyArchiveSection && archiveSectionID == "" {
+		return fmt.Errorf("Asana strategy %q needs delete.asana_archive_section_id (ASANA_ARCHIVE_SECTION_ID)", strategyArchiveSection)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAsanaTask applies strategy to a task. It returns the value for
+// DeleteResult.AsanaResult and, for hard deletes, the trash snapshot.
+func removeAsanaTask(ctx context.Context, taskID, strategy string) (string, *AsanaTaskSnapshot, error) {
+	switch strategy {
+	case strategyComplete:
+		if err := asanaFrom(ctx).UpdateTask(ctx, taskID, map[string]interface{}{"completed": true}); err != nil {
+			return "", nil, err
+		}
+		slog.InfoContext(ctx, "Marked Asana task completed instead of deleting it", "asana_id", taskID)
+		return "completed", nil, nil
+
+	case strategyArchiveSection:
+		if err := asanaFrom(ctx).AddTaskToSection(ctx, currentConfig().AsanaArchiveSectionID, taskID); err != nil {
+			return "", nil, err
+		}
+		slog.InfoContext(ctx, "Moved Asana task to the archive section instead of deleting it", "asana_id", taskID)
+		return "archived", nil, nil
+
+	default:
+		snap, err := deleteAsanaTask(ctx, taskID)
+		if err != nil {
+			return "", nil, err
+		}
+		return "deleted", snap, nil
+	}
+}
+
+// removeYouTrackIssue applies strategy to an issue, like removeAsanaTask.
+// An unknown issue ID still surfaces as ErrNotFound.
+func removeYouTrackIssue(ctx context.Context, issueID, strategy string) (string, *YouTrackIssueSnapshot, error) {
+	switch strategy {
+	case strategyResolve:
+		state, err := pairFieldSchema(ctx).IssueField("State", currentConfig().YouTrackResolvedState)
+		if err != nil {
+			return "", nil, err
+		}
+		payload := map[string]interface{}{
+			"$type":        "Issue",
+			"customFields": []map[string]interface{}{state},
+		}
+		if err := youTrackFrom(ctx).UpdateIssue(ctx, issueID, payload); err != nil {
+			forgetFieldSchemaOn(ctx, err)
+			return "", nil, err
+		}
+		slog.InfoContext(ctx, "Resolved YouTrack issue instead of deleting it", "youtrack_id", issueID, "state", currentConfig().YouTrackResolvedState)
+		return "resolved", nil, nil
+
+	case strategyTag:
+		// Check the issue exists first so a bad ID is not mistaken for a tag problem
+		if _, err := youTrackFrom(ctx).Issue(ctx, issueID); err != nil {
+			return "", nil, err
+		}
+		tagID, err := youTrackFrom(ctx).TagID(ctx, currentConfig().YouTrackArchiveTag)
+		if err != nil {
+			return "", nil, fmt.Errorf("archive tag %q: %w", currentConfig().YouTrackArchiveTag, err)
+		}
+		if err := youTrackFrom(ctx).AddIssueTag(ctx, issueID, tagID); err != nil {
+			return "", nil, err
+		}
+		slog.InfoContext(ctx, "Tagged YouTrack issue instead of deleting it", "youtrack_id", issueID, "tag", currentConfig().YouTrackArchiveTag)
+		return "tagged", nil, nil
+
+	default:
+		snap, err := deleteYouTrackIssue(ctx, issueID)
+		if err != nil {
+			return "", nil, err
+		}
+		return "deleted", snap, nil
+	}
+}
@@ -0,0 +1,854 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TenantRecord is one client's tracker credentials and project pairs.
+// Everything the tenant writes (ignore lists, audit log, trash, cursors,
+// jobs) lives in DataDir and nowhere else. In memory the credential fields
+// hold the secrets; in tenants.json they hold references into the secrets
+// provider.
+type TenantRecord struct {
+	ID                   string        `json:"id"`
+	Name                 string        `json:"name"`
+	AsanaPAT             string        `json:"asana_pat"`
+	YouTrackBaseURL      string        `json:"youtrack_base_url"`
+	YouTrackToken        string        `json:"youtrack_token"`
+	AsanaWebhookSecret   string        `json:"asana_webhook_secret,omitempty"`
+	YouTrackWebhookToken string        `json:"youtrack_webhook_token,omitempty"`
+	Alerts               AlertChannels `json:"alerts"`
+	Pairs                []ProjectPair `json:"pairs"`
+	DataDir              string        `json:"data_dir"`
+	CreatedAt            time.Time     `json:"created_at"`
+	UpdatedAt            time.Time     `json:"updated_at"`
+}
+
+// TenantView is a tenant as returned by the admin API, without secrets
+type TenantView struct {
+	ID                      string        `json:"id"`
+	Name                    string        `json:"name"`
+	YouTrackBaseURL         string        `json:"youtrack_base_url"`
+	AsanaPATSet             bool          `json:"asana_pat_set"`
+	YouTrackTokenSet        bool          `json:"youtrack_token_set"`
+	AsanaWebhookSecretSet   bool          `json:"asana_webhook_secret_set"`
+	YouTrackWebhookTokenSet bool          `json:"youtrack_webhook_token_set"`
+	Alerts                  AlertChannels `json:"alerts"` // URLs and password masked
+	Pairs                   []ProjectPair `json:"pairs"`
+	DataDir                 string        `json:"data_dir"`
+	CreatedAt               time.Time     `json:"created_at"`
+	UpdatedAt               time.Time     `json:"updated_at"`
+}
+
+// defaultTenantID is the tenant seeded from the environment on first start.
+// It keeps its files in the working directory, where they were before
+// tenants existed.
+const defaultTenantID = "default"
+
+func (rec TenantRecord) Validate() error {
+	if err := rec.validateShape(); err != nil {
+		return err
+	}
+	if rec.AsanaPAT == "" || rec.YouTrackToken == "" {
+		return fmt.Errorf("asana_pat and youtrack_token are required")
+	}
+	return nil
+}
+
+// validateShape checks everything but the credentials, which may be missing
+// from the secrets provider when tenants are loaded.
+func (rec TenantRecord) validateShape() error {
+	switch {
+	case !slugPattern.MatchString(rec.ID):
+		return fmt.Errorf("id %q must be lowercase letters, digits, - or _", rec.ID)
+	case rec.YouTrackBaseURL == "":
+		return fmt.Errorf("youtrack_base_url is required")
+	}
+	if err := validatePairs(rec.Pairs); err != nil {
+		return fmt.Errorf("pairs: %w", err)
+	}
+	if err := rec.Alerts.validate(); err != nil {
+		return fmt.Errorf("alerts: %w", err)
+	}
+	return nil
+}
+
+// Tenant secrets
+
+// secretRefPrefix marks a credential field in tenants.json as a reference
+// to the secrets provider rather than a value.
+const secretRefPrefix = "secret:"
+
+// tenantSecrets are the credential fields of a tenant, by secret name
+var tenantSecrets = []struct {
+	name     string
+	required bool
+	field    func(*TenantRecord) *string
+}{
+	{"asana_pat", true, func(rec *TenantRecord) *string { return &rec.AsanaPAT }},
+	{"youtrack_token", true, func(rec *TenantRecord) *string { return &rec.YouTrackToken }},
+	{"asana_webhook_secret", false, func(rec *TenantRecord) *string { return &rec.AsanaWebhookSecret }},
+	{"youtrack_webhook_token", false, func(rec *TenantRecord) *string { return &rec.YouTrackWebhookToken }},
+	{"alert_webhook_url", false, func(rec *TenantRecord) *string { return &rec.Alerts.WebhookURL }},
+	{"alert_slack_webhook_url", false, func(rec *TenantRecord) *string { return &rec.Alerts.SlackWebhookURL }},
+	{"alert_smtp_password", false, func(rec *TenantRecord) *string { return &rec.Alerts.Email.Password }},
+}
+
+func tenantSecretPrefix(id string) string {
+	return "tenant/" + id + "/"
+}
+
+func tenantSecretField(rec *TenantRecord, name string) (*string, bool) {
+	for _, s := range tenantSecrets {
+		if s.name == name {
+			return s.field(rec), true
+		}
+	}
+	return nil, false
+}
+
+func (rec TenantRecord) View() TenantView {
+	return TenantView{
+		ID:                      rec.ID,
+		Name:                    rec.Name,
+		YouTrackBaseURL:         rec.YouTrackBaseURL,
+		AsanaPATSet:             rec.AsanaPAT != "",
+		YouTrackTokenSet:        rec.YouTrackToken != "",
+		AsanaWebhookSecretSet:   rec.AsanaWebhookSecret != "",
+		YouTrackWebhookTokenSet: rec.YouTrackWebhookToken != "",
+		Alerts:                  rec.Alerts.redacted(),
+		Pairs:                   rec.Pairs,
+		DataDir:                 rec.DataDir,
+		CreatedAt:               rec.CreatedAt,
+		UpdatedAt:               rec.UpdatedAt,
+	}
+}
+
+// Tenant is the runtime of one tenant: its tracker clients, pair engines and
+// stores. Requests and background runs reach it through their context.
+type Tenant struct {
+	id      string
+	dir     string
+	runs    *RunCoordinator
+	cursors *CursorStore
+	audit   *AuditLog
+	trash   *TrashStore
+	jobs    *JobQueue
+	alerts  *Notifier
+
+	mu            sync.RWMutex
+	record        TenantRecord
+	asana         *AsanaClient
+	youTrack      *YouTrackClient
+	pairs         *PairRegistry
+	webhookSecret string    // from Asana's handshake, or preset in the record
+	handshakeBy   time.Time // an Asana webhook registration awaits its handshake until then
+}
+
+func newTenant(rec TenantRecord) (*Tenant, error) {
+	if err := os.MkdirAll(rec.DataDir, 0700); err != nil {
+		return nil, err
+	}
+	trash, err := NewTrashStore(filepath.Join(rec.DataDir, "trash.json"), time.Duration(currentConfig().TrashRetentionDays)*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := NewAuditLog(filepath.Join(rec.DataDir, "audit.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tenant{
+		id:      rec.ID,
+		dir:     rec.DataDir,
+		runs:    NewRunCoordinator(),
+		cursors: NewCursorStore(filepath.Join(rec.DataDir, "sync_cursors.json"), time.Duration(currentConfig().FullReconcileMinutes)*time.Minute),
+		audit:   audit,
+		trash:   trash,
+	}
+	t.alerts = NewNotifier(func() AlertChannels { return t.Record().Alerts })
+	t.applyLocked(rec)
+	t.pairs = NewPairRegistry(t, rec.Pairs)
+	if t.jobs, err = NewJobQueue(t, filepath.Join(rec.DataDir, "jobs")); err != nil {
+		audit.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// applyLocked installs the record's credentials; t.mu must be held or t
+// not yet shared.
+func (t *Tenant) applyLocked(rec TenantRecord) {
+	t.record = rec
+	secretRedactor.Add(rec.AsanaPAT, rec.YouTrackToken, rec.AsanaWebhookSecret, rec.YouTrackWebhookToken)
+	secretRedactor.Add(rec.Alerts.secrets()...)
+	t.asana = NewAsanaClient(rec.AsanaPAT)
+	t.youTrack = NewYouTrackClient(rec.YouTrackBaseURL, rec.YouTrackToken)
+	t.webhookSecret = rec.AsanaWebhookSecret
+}
+
+// Start launches the job worker and the pairs' configured schedules.
+func (t *Tenant) Start() {
+	t.jobs.Start()
+	t.Pairs().StartSchedules()
+}
+
+// Stop halts all background work of the tenant.
+func (t *Tenant) Stop() {
+	t.Pairs().StopAll("tenant removed")
+	t.jobs.Stop()
+	t.alerts.Wait()
+	t.audit.Close()
+}
+
+// update swaps in a new record. Credentials apply to the next tracker call;
+// changed pairs get fresh engines, which restarts their schedules, while
+// unchanged pairs keep theirs.
+func (t *Tenant) update(rec TenantRecord) {
+	t.mu.Lock()
+	var retired, added []*SyncEngine
+	if !reflect.DeepEqual(t.record.Pairs, rec.Pairs) {
+		t.pairs, retired, added = t.pairs.Rebuild(t, rec.Pairs)
+	}
+	t.applyLocked(rec)
+	t.mu.Unlock()
+
+	for _, e := range retired {
+		e.StopAll("")
+	}
+	for _, e := range added {
+		e.StartSchedules()
+	}
+}
+
+func (t *Tenant) ID() string { return t.id }
+
+func (t *Tenant) Record() TenantRecord {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.record
+}
+
+func (t *Tenant) Asana() *AsanaClient {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.asana
+}
+
+func (t *Tenant) YouTrack() *YouTrackClient {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.youTrack
+}
+
+func (t *Tenant) Pairs() *PairRegistry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.pairs
+}
+
+func (t *Tenant) WebhookSecret() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.webhookSecret
+}
+
+// ExpectWebhookHandshake lets one Asana handshake through until deadline.
+// Registering the webhook is what triggers it.
+func (t *Tenant) ExpectWebhookHandshake(deadline time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handshakeBy = deadline
+}
+
+// claimWebhookHandshake reports whether a handshake may set the secret: a
+// registration is pending and no secret is known. Only one caller wins.
+func (t *Tenant) claimWebhookHandshake() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.webhookSecret != "" || time.Now().After(t.handshakeBy) {
+		return false
+	}
+	t.handshakeBy = time.Time{}
+	return true
+}
+
+// TenantRegistry holds all tenants and persists their records to a JSON
+// file readable only by the service user. Credentials go to the secrets
+// provider.
+type TenantRegistry struct {
+	file    string
+	secrets SecretsProvider
+
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+var (
+	errTenantExists   = errors.New("tenant already exists")
+	errTenantNotFound = errors.New("tenant not found")
+	errTenantBusy     = errors.New("tenant has queued or running jobs")
+)
+
+// NewTenantRegistry loads every stored tenant without starting it.
+// Credentials stored in plain text by older versions are moved into the
+// secrets provider.
+func NewTenantRegistry(file string, secrets SecretsProvider) (*TenantRegistry, error) {
+	reg := &TenantRegistry{file: file, secrets: secrets, tenants: make(map[string]*Tenant)}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []TenantRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	var migrated []TenantRecord
+	for _, rec := range records {
+		if err := rec.validateShape(); err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", rec.ID, err)
+		}
+		plaintext, err := reg.resolveSecrets(&rec)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", rec.ID, err)
+		}
+		if plaintext {
+			migrated = append(migrated, rec)
+		}
+		t, err := newTenant(rec)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", rec.ID, err)
+		}
+		reg.tenants[rec.ID] = t
+	}
+
+	for _, rec := range migrated {
+		if err := reg.storeSecrets(rec, TenantRecord{}); err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", rec.ID, err)
+		}
+		slog.Info("Moved tenant credentials into the secrets store", "tenant", rec.ID, "from", file, "backend", secrets.Backend())
+	}
+	if len(migrated) > 0 {
+		if err := reg.saveLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+// resolveSecrets replaces the references in rec with their values. It
+// reports whether any field still held a plain-text credential. Missing
+// secrets are left empty so the tenant can be repaired through the API.
+func (reg *TenantRegistry) resolveSecrets(rec *TenantRecord) (bool, error) {
+	plaintext := false
+	for _, s := range tenantSecrets {
+		field := s.field(rec)
+		if *field == "" {
+			continue
+		}
+		name, isRef := strings.CutPrefix(*field, secretRefPrefix)
+		if !isRef {
+			plaintext = true
+			continue
+		}
+
+		value, err := reg.secrets.Get(name)
+		switch {
+		case errors.Is(err, errSecretNotFound):
+			if s.required {
+				slog.Warn("Tenant secret is not in the secrets store; set it with POST /admin/secrets", "tenant", rec.ID, "secret", name, "backend", reg.secrets.Backend())
+			}
+			value = ""
+		case err != nil:
+			return false, fmt.Errorf("secret %s: %w", name, err)
+		}
+		*field = value
+	}
+	return plaintext, nil
+}
+
+// storeSecrets writes the credentials of rec that differ from previous to
+// the secrets provider.
+func (reg *TenantRegistry) storeSecrets(rec, previous TenantRecord) error {
+	prefix := tenantSecretPrefix(rec.ID)
+	for _, s := range tenantSecrets {
+		value := *s.field(&rec)
+		if value == *s.field(&previous) {
+			continue
+		}
+		var err error
+		if value == "" {
+			err = reg.secrets.Delete(prefix + s.name)
+		} else {
+			err = reg.secrets.Set(prefix+s.name, value)
+		}
+		if err != nil {
+			return fmt.Errorf("store %s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// Secrets returns the provider credentials are stored in
+func (reg *TenantRegistry) Secrets() SecretsProvider {
+	return reg.secrets
+}
+
+func (reg *TenantRegistry) Get(id string) (*Tenant, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	t, ok := reg.tenants[id]
+	return t, ok
+}
+
+// List returns the tenants ordered by ID.
+func (reg *TenantRegistry) List() []*Tenant {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	tenants := make([]*Tenant, 0, len(reg.tenants))
+	for _, t := range reg.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].id < tenants[j].id })
+	return tenants
+}
+
+func (reg *TenantRegistry) IDs() []string {
+	var ids []string
+	for _, t := range reg.List() {
+		ids = append(ids, t.id)
+	}
+	return ids
+}
+
+func (reg *TenantRegistry) StartAll() {
+	for _, t := range reg.List() {
+		t.Start()
+	}
+}
+
+// Create stores and builds a new tenant. The caller starts it.
+func (reg *TenantRegistry) Create(rec TenantRecord) (*Tenant, error) {
+	if rec.DataDir == "" {
+		rec.DataDir = filepath.Join("tenants", rec.ID)
+	}
+	if err := rec.Validate(); err != nil {
+		return nil, err
+	}
+	rec.CreatedAt = time.Now().UTC()
+	rec.UpdatedAt = rec.CreatedAt
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, ok := reg.tenants[rec.ID]; ok {
+		return nil, errTenantExists
+	}
+	if err := reg.storeSecrets(rec, TenantRecord{}); err != nil {
+		return nil, err
+	}
+	t, err := newTenant(rec)
+	if err != nil {
+		return nil, err
+	}
+	reg.tenants[rec.ID] = t
+	if err := reg.saveLocked(); err != nil {
+		delete(reg.tenants, rec.ID)
+		t.audit.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Update applies change to a copy of the tenant's record and installs it if
+// it is still valid. ID, DataDir and CreatedAt cannot change.
+func (reg *TenantRegistry) Update(id string, change func(*TenantRecord)) (TenantRecord, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	t, ok := reg.tenants[id]
+	if !ok {
+		return TenantRecord{}, errTenantNotFound
+	}
+	previous := t.Record()
+	rec := previous
+	rec.Pairs = append([]ProjectPair(nil), previous.Pairs...)
+	change(&rec)
+	rec.ID, rec.DataDir, rec.CreatedAt = previous.ID, previous.DataDir, previous.CreatedAt
+	rec.UpdatedAt = time.Now().UTC()
+	if err := rec.Validate(); err != nil {
+		return TenantRecord{}, err
+	}
+	if err := reg.storeSecrets(rec, previous); err != nil {
+		reg.storeSecrets(previous, rec)
+		return TenantRecord{}, err
+	}
+
+	t.update(rec)
+	if err := reg.saveLocked(); err != nil {
+		t.update(previous)
+		reg.storeSecrets(previous, rec)
+		return TenantRecord{}, err
+	}
+	return rec, nil
+}
+
+// RotateSecret replaces one credential of a tenant. The tenant's clients
+// use it from the next tracker call on.
+func (reg *TenantRegistry) RotateSecret(id, name, value string) error {
+	_, err := reg.Update(id, func(rec *TenantRecord) {
+		if field, ok := tenantSecretField(rec, name); ok {
+			*field = value
+		}
+	})
+	return err
+}
+
+// Delete stops and forgets a tenant and removes its credentials. Its data
+// directory is left on disk. Stopping waits for the tenant's runs and
+// alerts, so it happens after the registry lock is released.
+func (reg *TenantRegistry) Delete(id string) (TenantRecord, error) {
+	reg.mu.Lock()
+	t, ok := reg.tenants[id]
+	if !ok {
+		reg.mu.Unlock()
+		return TenantRecord{}, errTenantNotFound
+	}
+	if t.jobs.Active() > 0 {
+		reg.mu.Unlock()
+		return TenantRecord{}, errTenantBusy
+	}
+	delete(reg.tenants, id)
+	if err := reg.saveLocked(); err != nil {
+		reg.tenants[id] = t
+		reg.mu.Unlock()
+		return TenantRecord{}, err
+	}
+	reg.mu.Unlock()
+
+	t.Stop()
+	rec := t.Record()
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, recreated := reg.tenants[id]; recreated {
+		// A tenant with the same ID was created meanwhile and owns the credentials now
+		return rec, nil
+	}
+	if err := reg.storeSecrets(TenantRecord{ID: id}, rec); err != nil {
+		slog.Error("Could not remove credentials of deleted tenant", "tenant", id, "error", err)
+	}
+	return rec, nil
+}
+
+func (reg *TenantRegistry) saveLocked() error {
+	records := make([]TenantRecord, 0, len(reg.tenants))
+	for _, t := range reg.tenants {
+		rec := t.Record()
+		for _, s := range tenantSecrets {
+			if field := s.field(&rec); *field != "" || s.required {
+				*field = secretRefPrefix + tenantSecretPrefix(rec.ID) + s.name
+			}
+		}
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(reg.file, data)
+}
+
+// seedTenantRecord builds the default tenant from the environment. It is
+// only used when no tenants file exists yet; afterwards tenants are managed
+// through /admin/tenants and credentials live in the secrets provider.
+func seedTenantRecord() (TenantRecord, bool) {
+	rec := TenantRecord{
+		ID:                   defaultTenantID,
+		Name:                 "Default",
+		AsanaPAT:             getEnv("ASANA_PAT", ""),
+		YouTrackBaseURL:      currentConfig().YouTrackBaseURL,
+		YouTrackToken:        getEnv("YOUTRACK_TOKEN", ""),
+		AsanaWebhookSecret:   getEnv("ASANA_WEBHOOK_SECRET", ""),
+		YouTrackWebhookToken: getEnv("YOUTRACK_WEBHOOK_TOKEN", ""),
+		Pairs:                currentConfig().ProjectPairs,
+		DataDir:              ".",
+	}
+	if rec.AsanaPAT == "" || rec.YouTrackBaseURL == "" || rec.YouTrackToken == "" || len(rec.Pairs) == 0 {
+		return TenantRecord{}, false
+	}
+	return rec, true
+}
+
+// Tenant context
+
+type tenantContextKey struct{}
+
+func withTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, t)
+}
+
+// tenantFrom returns the tenant in ctx, or the tenant of the pair in ctx.
+func tenantFrom(ctx context.Context) *Tenant {
+	if t, ok := ctx.Value(tenantContextKey{}).(*Tenant); ok {
+		return t
+	}
+	if e, ok := ctx.Value(pairContextKey{}).(*SyncEngine); ok {
+		return e.tenant
+	}
+	return nil
+}
+
+func asanaFrom(ctx context.Context) *AsanaClient {
+	return tenantFrom(ctx).Asana()
+}
+
+func youTrackFrom(ctx context.Context) *YouTrackClient {
+	return tenantFrom(ctx).YouTrack()
+}
+
+// resolveTenant picks the tenant of a request. Keys and tokens bound to a
+// tenant always get that tenant; service-level callers name one with
+// ?tenant= or X-Tenant, which may be left out while only one tenant exists.
+func (s *Server) resolveTenant(r *http.Request) (*Tenant, int, string, string) {
+	requested := r.URL.Query().Get("tenant")
+	if requested == "" {
+		requested = r.Header.Get("X-Tenant")
+	}
+
+	id := requested
+	if p := principalFrom(r.Context()); p != nil && p.Tenant != "" {
+		if requested != "" && requested != p.Tenant {
+			return nil, http.StatusForbidden, "forbidden", fmt.Sprintf("%q is bound to tenant %q.", p.Name, p.Tenant)
+		}
+		id = p.Tenant
+	}
+
+	if id == "" {
+		ids := s.tenants.IDs()
+		switch len(ids) {
+		case 0:
+			return nil, http.StatusServiceUnavailable, "no_tenants", "No tenants are configured. Create one with POST /admin/tenants."
+		case 1:
+			id = ids[0]
+		default:
+			return nil, http.StatusBadRequest, "tenant_required",
+				fmt.Sprintf("Name a tenant with ?tenant= or X-Tenant. Tenants: %s.", strings.Join(ids, ", "))
+		}
+	}
+
+	t, ok := s.tenants.Get(id)
+	if !ok {
+		return nil, http.StatusNotFound, "unknown_tenant", fmt.Sprintf("Unknown tenant %q.", id)
+	}
+	return t, 0, "", ""
+}
+
+// forTenant puts the request's tenant on its context; see resolveTenant.
+func (s *Server) forTenant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, status, code, message := s.resolveTenant(r)
+		if t == nil {
+			writeJSONError(w, status, code, message)
+			return
+		}
+		next(w, r.WithContext(withTenant(r.Context(), t)))
+	}
+}
+
+// requireServiceAdmin rejects callers bound to a tenant, writing a 403.
+func requireServiceAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if p := principalFrom(r.Context()); p != nil && p.Tenant != "" {
+		writeJSONError(w, http.StatusForbidden, "forbidden",
+			fmt.Sprintf("%s %s needs a service-level key; %q is bound to tenant %q.", r.Method, r.URL.Path, p.Name, p.Tenant))
+		return false
+	}
+	return true
+}
+
+// tenantRequest is the body of POST and PUT /admin/tenants. On PUT, omitted
+// fields keep their current values.
+type tenantRequest struct {
+	ID                   string         `json:"id"`
+	Name                 *string        `json:"name"`
+	AsanaPAT             *string        `json:"asana_pat"`
+	YouTrackBaseURL      *string        `json:"youtrack_base_url"`
+	YouTrackToken        *string        `json:"youtrack_token"`
+	AsanaWebhookSecret   *string        `json:"asana_webhook_secret"`
+	YouTrackWebhookToken *string        `json:"youtrack_webhook_token"`
+	Alerts               *AlertChannels `json:"alerts"`
+	Pairs                *[]ProjectPair `json:"pairs"`
+}
+
+func (req tenantRequest) apply(rec *TenantRecord) {
+	setTrimmed(&rec.Name, req.Name)
+	setTrimmed(&rec.AsanaPAT, req.AsanaPAT)
+	setTrimmed(&rec.YouTrackBaseURL, req.YouTrackBaseURL)
+	setTrimmed(&rec.YouTrackToken, req.YouTrackToken)
+	setTrimmed(&rec.AsanaWebhookSecret, req.AsanaWebhookSecret)
+	setTrimmed(&rec.YouTrackWebhookToken, req.YouTrackWebhookToken)
+	if req.Alerts != nil {
+		// Masked values copied back from GET keep the stored secret
+		next := *req.Alerts
+		for _, kept := range []struct{ next, current *string }{
+			{&next.WebhookURL, &rec.Alerts.WebhookURL},
+			{&next.SlackWebhookURL, &rec.Alerts.SlackWebhookURL},
+			{&next.Email.Password, &rec.Alerts.Email.Password},
+		} {
+			if *kept.next == redactedText {
+				*kept.next = *kept.current
+			}
+		}
+		rec.Alerts = next
+	}
+	if req.Pairs != nil {
+		rec.Pairs = *req.Pairs
+	}
+}
+
+func setTrimmed(field, value *string) {
+	if value != nil {
+		*field = strings.TrimSpace(*value)
+	}
+}
+
+// Tenant administration handler (service-level admins only)
+func (s *Server) tenantsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireServiceAdmin(w, r) {
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/tenants"), "/")
+
+	switch {
+	case r.Method == "GET" && id == "":
+		views := []TenantView{}
+		for _, t := range s.tenants.List() {
+			views = append(views, t.Record().View())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "success",
+			"tenants": views,
+			"count":   len(views),
+		})
+
+	case r.Method == "GET":
+		t, ok := s.tenants.Get(id)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "unknown_tenant", fmt.Sprintf("Unknown tenant %q.", id))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"tenant": t.Record().View(),
+		})
+
+	case r.Method == "POST" && id == "":
+		var req tenantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request",
+				`Body must be an object like {"id":"acme","asana_pat":"...","youtrack_base_url":"...","youtrack_token":"...","pairs":[...]}`)
+			return
+		}
+		rec := TenantRecord{ID: strings.TrimSpace(req.ID)}
+		req.apply(&rec)
+
+		t, err := s.tenants.Create(rec)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errTenantExists) {
+				status = http.StatusConflict
+			}
+			writeJSONError(w, status, "invalid_request", err.Error())
+			return
+		}
+		t.Start()
+
+		slog.InfoContext(r.Context(), "Tenant created", "tenant", t.ID(), "actor", actorName(r.Context()))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "created",
+			"tenant": t.Record().View(),
+		})
+
+	case r.Method == "PUT" && id != "":
+		var req tenantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_request", "Body must be a JSON object with the fields to change.")
+			return
+		}
+
+		rec, err := s.tenants.Update(id, req.apply)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errTenantNotFound) {
+				status = http.StatusNotFound
+			}
+			writeJSONError(w, status, "invalid_request", err.Error())
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Tenant updated", "tenant", id, "actor", actorName(r.Context()))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "updated",
+			"tenant": rec.View(),
+		})
+
+	case r.Method == "DELETE" && id != "":
+		rec, err := s.tenants.Delete(id)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, errTenantNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, errTenantBusy):
+				status = http.StatusConflict
+			}
+			writeJSONError(w, status, "invalid_request", err.Error())
+			return
+		}
+		revoked, err := s.keys.RevokeTenant(id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Could not revoke API keys of deleted tenant", "tenant", id, "error", err)
+		}
+
+		slog.InfoContext(r.Context(), "Tenant deleted", "tenant", id, "actor", actorName(r.Context()), "api_keys_revoked", revoked)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":           "deleted",
+			"tenant":           rec.View(),
+			"api_keys_revoked": revoked,
+			"note":             fmt.Sprintf("Tenant data was kept in %s.", rec.DataDir),
+		})
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed",
+			"Use GET/POST /admin/tenants or GET/PUT/DELETE /admin/tenants/{id}.")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewTenantRefusesCorruptStores(t *testing.T) {
+	useTestConfig(t)
+	for _, file := range []string{"trash.json", filepath.Join("jobs", "job-1.json")} {
+		dir := t.TempDir()
+		corrupt := []byte(`{"id": "half-writ`)
+		if err := os.MkdirAll(filepath.Join(dir, "jobs"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), corrupt, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := newTenant(TenantRecord{ID: "acme", DataDir: dir})
+		if err == nil || !strings.Contains(err.Error(), "corrupt") {
+			t.Errorf("%s: newTenant error = %v, want a corrupt store error", file, err)
+		}
+		// The file is left for the operator to repair
+		if data, _ := os.ReadFile(filepath.Join(dir, file)); string(data) != string(corrupt) {
+			t.Errorf("%s was rewritten to %q", file, data)
+		}
+	}
+}
+
+func TestTenantUpdateRebuildsOnlyChangedPairs(t *testing.T) {
+	useTestConfig(t)
+	rec := TenantRecord{ID: "acme", DataDir: t.TempDir(), Pairs: []ProjectPair{
+		{Name: "web", AsanaProjectID: "A1", YouTrackProjectID: "WEB"},
+		{Name: "ops", AsanaProjectID: "A2", YouTrackProjectID: "OPS"},
+		{Name: "old", AsanaProjectID: "A3", YouTrackProjectID: "OLD", AutoSyncInterval: 3600},
+	}}
+	tn, err := newTenant(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tn.Stop)
+	tn.Pairs().StartSchedules()
+
+	web, _ := tn.Pairs().Get("web")
+	ops, _ := tn.Pairs().Get("ops")
+	old, _ := tn.Pairs().Get("old")
+	web.Ignore("T1", false)
+	web.StartAutoSync(3600)
+
+	rec.Pairs = []ProjectPair{
+		rec.Pairs[0],
+		{Name: "ops", AsanaProjectID: "A2", YouTrackProjectID: "OPS2"},
+		{Name: "new", AsanaProjectID: "A4", YouTrackProjectID: "NEW", AutoCreateInterval: 3600},
+	}
+	tn.update(rec)
+
+	if e, _ := tn.Pairs().Get("web"); e != web {
+		t.Error("unchanged pair got a new engine")
+	}
+	if !web.IsIgnored("T1") || !web.AutoSyncState().Running {
+		t.Error("unchanged pair lost its temporary ignores or its auto-sync")
+	}
+	if e, _ := tn.Pairs().Get("ops"); e == ops || e.pair.YouTrackProjectID != "OPS2" {
+		t.Error("changed pair kept its old engine")
+	}
+	if _, ok := tn.Pairs().Get("old"); ok {
+		t.Error("removed pair is still registered")
+	}
+	if old.AutoSyncState().Running {
+		t.Error("removed pair's auto-sync is still running")
+	}
+	if e, ok := tn.Pairs().Get("new"); !ok || !e.AutoCreateState().Running {
+		t.Error("added pair's configured auto-create was not started")
+	}
+}
+
+// testTenantRegistry is a registry with tenants "a" and "b", each in its
+// own data directory under root
+func testTenantRegistry(t *testing.T) (*TenantRegistry, string) {
+	t.Helper()
+	useTestConfig(t)
+	root := t.TempDir()
+	secrets, err := newFileSecrets(filepath.Join(root, "secrets.enc"), testSealer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := NewTenantRegistry(filepath.Join(root, "tenants.json"), secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		tn, err := reg.Create(TenantRecord{
+			ID:              id,
+			DataDir:         filepath.Join(root, "tenants", id),
+			AsanaPAT:        "pat-" + id,
+			YouTrackBaseURL: "https://yt.example.com",
+			YouTrackToken:   "yt-token-" + id,
+			Pairs:           []ProjectPair{{Name: defaultPairName, AsanaProjectID: "A-" + id, YouTrackProjectID: "YT"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(tn.Stop)
+	}
+	return reg, root
+}
+
+func TestResolveTenantHonoursKeyBinding(t *testing.T) {
+	reg, _ := testTenantRegistry(t)
+	s := &Server{tenants: reg}
+
+	bound := &Principal{Name: "ci-a", Role: roleOperator, Tenant: "a"}
+	service := &Principal{Name: "ops", Role: roleAdmin}
+	tests := []struct {
+		name      string
+		principal *Principal
+		query     string
+		header    string
+		tenant    string
+		status    int
+	}{
+		{"bound key, no tenant named", bound, "", "", "a", 0},
+		{"bound key, own tenant", bound, "a", "", "a", 0},
+		{"bound key, other tenant in query", bound, "b", "", "", http.StatusForbidden},
+		{"bound key, other tenant in header", bound, "", "b", "", http.StatusForbidden},
+		{"service key, tenant named", service, "b", "", "b", 0},
+		{"service key, no tenant named", service, "", "", "", http.StatusBadRequest},
+		{"service key, unknown tenant", service, "c", "", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/status", nil)
+		if tt.query != "" {
+			req.URL.RawQuery = "tenant=" + tt.query
+		}
+		if tt.header != "" {
+			req.Header.Set("X-Tenant", tt.header)
+		}
+		req = req.WithContext(withPrincipal(req.Context(), tt.principal))
+
+		tn, status, _, _ := s.resolveTenant(req)
+		switch {
+		case status != tt.status:
+			t.Errorf("%s: status %d, want %d", tt.name, status, tt.status)
+		case tt.tenant != "" && (tn == nil || tn.ID() != tt.tenant):
+			t.Errorf("%s: got tenant %v, want %q", tt.name, tn, tt.tenant)
+		}
+	}
+}
+
+func TestTenantFilesStayInDataDir(t *testing.T) {
+	reg, root := testTenantRegistry(t)
+	tn, _ := reg.Get("a")
+	ctx := withTenant(context.Background(), tn)
+
+	recordAudit(ctx, AuditEntry{Action: auditRestore, AsanaID: "T1"})
+	if _, err := tn.trash.Add(withPair(ctx, tn.Pairs().Default()), TrashEntry{TicketID: "T1"}); err != nil {
+		t.Fatal(err)
+	}
+	emptyDeleteJob(t, tn.jobs)
+	if err := tn.cursors.Commit(tn.cursors.Window(triggerAutoSync, "A-a", "YT")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "tenants", "a")
+	for _, file := range []string{"audit.jsonl", "trash.json", "jobs", "sync_cursors.json"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Errorf("%s not in tenant a's data dir: %v", file, err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(root, "tenants", "b", file)); strings.Contains(string(data), "T1") {
+			t.Errorf("tenant a's entry ended up in tenant b's %s", file)
+		}
+		if _, err := os.Stat(file); err == nil {
+			t.Errorf("%s was written to the working directory", file)
+		}
+	}
+	if jobs, _ := os.ReadDir(filepath.Join(dir, "jobs")); len(jobs) == 0 {
+		t.Error("tenant a's job is not in its jobs directory")
+	}
+	if jobs, _ := os.ReadDir(filepath.Join(root, "tenants", "b", "jobs")); len(jobs) != 0 {
+		t.Errorf("tenant b's jobs directory holds %d files", len(jobs))
+	}
+}
+
+func TestTenantUpdateRollsBackSecretsWhenSaveFails(t *testing.T) {
+	reg, root := testTenantRegistry(t)
+
+	// The parent of the registry file is a regular file, so saving fails
+	blocker := filepath.Join(root, "blocker")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	reg.file = filepath.Join(blocker, "tenants.json")
+
+	_, err := reg.Update("a", func(rec *TenantRecord) {
+		rec.YouTrackToken = "yt-token-rotated"
+		rec.YouTrackWebhookToken = "hook-token"
+	})
+	if err == nil {
+		t.Fatal("Update succeeded although the registry could not be saved")
+	}
+
+	tn, _ := reg.Get("a")
+	if rec := tn.Record(); rec.YouTrackToken != "yt-token-a" || rec.YouTrackWebhookToken != "" {
+		t.Errorf("tenant kept the failed update: token %q, webhook token %q", rec.YouTrackToken, rec.YouTrackWebhookToken)
+	}
+	if v, err := reg.Secrets().Get(tenantSecretPrefix("a") + "youtrack_token"); err != nil || v != "yt-token-a" {
+		t.Errorf("stored youtrack_token = %q, %v; want the previous token", v, err)
+	}
+	if _, err := reg.Secrets().Get(tenantSecretPrefix("a") + "youtrack_webhook_token"); !errors.Is(err, errSecretNotFound) {
+		t.Errorf("new youtrack_webhook_token was left in the secrets store (err = %v)", err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OpenTelemetry tracing: a span per handled request, tracker HTTP call,
+// analysis phase, job and auto run. Spans go to an OTLP/HTTP collector or,
+// for local use, to stdout. With the exporter set to none the global no-op
+// tracer is left in place and spans cost next to nothing.
+
+// Trace exporters
+const (
+	tracingNone   = "none"
+	tracingOTLP   = "otlp"
+	tracingStdout = "stdout"
+)
+
+// TracingConfig is the tracing section of config.yaml
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string // e.g. http://localhost:4318; empty uses the OTLP default
+	SampleRatio  float64
+	ServiceName  string
+}
+
+func (c TracingConfig) validate() error {
+	switch c.Exporter {
+	case tracingNone, tracingOTLP, tracingStdout:
+	default:
+		return fmt.Errorf("exporter must be none, otlp or stdout, got %q", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio must be between 0 and 1, got %v", c.SampleRatio)
+	}
+	if c.OTLPEndpoint != "" {
+		u, err := url.Parse(c.OTLPEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("otlp_endpoint must be an http(s) URL like http://localhost:4318, got %q", c.OTLPEndpoint)
+		}
+	}
+	if c.ServiceName == "" {
+		return fmt.Errorf("service_name is required")
+	}
+	return nil
+}
+
+var tracer = otel.Tracer("asana-youtrack-sync")
+
+// setupTracing installs the tracer provider for c. The returned function
+// flushes and stops the exporter.
+func setupTracing(c TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch c.Exporter {
+	case tracingOTLP:
+		var opts []otlptracehttp.Option
+		if c.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(c.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case tracingStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(redactingWriter{os.Stdout}), stdouttrace.WithPrettyPrint())
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(c.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// startSpan starts a child span of the span in ctx
+func startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, opts...)
+}
+
+// endSpan marks the span failed when err is set, with secrets and PII
+// masked like in the logs, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		msg := redactPII(redactSecrets(err.Error()))
+		span.RecordError(errors.New(msg))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// traceHandler wraps a route's handler in a server span, continuing a trace
+// the caller sent in traceparent.
+func traceHandler(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := startSpan(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request_id", requestIDFrom(r.Context())),
+			))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	}
+}
@@ -0,0 +1,754 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrashComment is a comment kept with a deleted ticket
+type TrashComment struct {
+	Author    string `json:"author"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at"`
+}
+
+// AsanaTaskSnapshot is a deleted Asana task. Raw holds the task exactly as
+// Asana returned it; the other fields are what restore uses.
+type AsanaTaskSnapshot struct {
+	GID         string `json:"gid"`
+	Name        string `json:"name"`
+	Notes       string `json:"notes"`
+	Completed   bool   `json:"completed"`
+	DueOn       string `json:"due_on,omitempty"`
+	AssigneeGID string `json:"assignee_gid,omitempty"`
+	SectionGID  string `json:"section_gid,omitempty"`
+	SectionName string `json:"section_name,omitempty"`
+	Tags        []struct {
+		GID  string `json:"gid"`
+		Name string `json:"name"`
+	} `json:"tags"`
+	CustomFields []asanaCustomFieldValue `json:"custom_fields,omitempty"`
+	Comments     []TrashComment          `json:"comments"`
+	Raw          json.RawMessage         `json:"raw,omitempty"`
+}
+
+type asanaCustomFieldValue struct {
+	GID             string   `json:"gid"`
+	Name            string   `json:"name"`
+	ResourceSubtype string   `json:"resource_subtype"`
+	TextValue       *string  `json:"text_value,omitempty"`
+	NumberValue     *float64 `json:"number_value,omitempty"`
+	EnumValue       *struct {
+		GID string `json:"gid"`
+	} `json:"enum_value,omitempty"`
+}
+
+// YouTrackIssueSnapshot is a deleted YouTrack issue
+type YouTrackIssueSnapshot struct {
+	ID           string `json:"id"`
+	IDReadable   string `json:"idReadable"`
+	Summary      string `json:"summary"`
+	Description  string `json:"description"`
+	Project      string `json:"project"`
+	CustomFields []struct {
+		Name  string          `json:"name"`
+		Type  string          `json:"$type"`
+		Value json.RawMessage `json:"value"`
+	} `json:"custom_fields"`
+	Tags     []string        `json:"tags,omitempty"`
+	Comments []TrashComment  `json:"comments"`
+	Raw      json.RawMessage `json:"raw,omitempty"`
+}
+
+func snapshotAsanaTask(ctx context.Context, taskID string) (*AsanaTaskSnapshot, error) {
+	raw, err := asanaFrom(ctx).Task(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	var task struct {
+		GID       string `json:"gid"`
+		Name      string `json:"name"`
+		Notes     string `json:"notes"`
+		Completed bool   `json:"completed"`
+		DueOn     string `json:"due_on"`
+		Assignee  *struct {
+			GID string `json:"gid"`
+		} `json:"assignee"`
+		Memberships []struct {
+			Project struct {
+				GID string `json:"gid"`
+			} `json:"project"`
+			Section struct {
+				GID  string `json:"gid"`
+				Name string `json:"name"`
+			} `json:"section"`
+		} `json:"memberships"`
+		Tags []struct {
+			GID  string `json:"gid"`
+			Name string `json:"name"`
+		} `json:"tags"`
+		CustomFields []asanaCustomFieldValue `json:"custom_fields"`
+	}
+	if err := json.Unmarshal(raw, &task); err != nil {
+		return nil, fmt.Errorf("decode Asana task %s: %w", taskID, err)
+	}
+
+	snap := &AsanaTaskSnapshot{
+		GID:          task.GID,
+		Name:         task.Name,
+		Notes:        task.Notes,
+		Completed:    task.Completed,
+		DueOn:        task.DueOn,
+		Tags:         task.Tags,
+		CustomFields: task.CustomFields,
+		Comments:     []TrashComment{},
+		Raw:          raw,
+	}
+	if task.Assignee != nil {
+		snap.AssigneeGID = task.Assignee.GID
+	}
+	for _, m := range task.Memberships {
+		if m.Project.GID == pairFrom(ctx).AsanaProjectID || snap.SectionGID == "" {
+			snap.SectionGID = m.Section.GID
+			snap.SectionName = m.Section.Name
+		}
+	}
+
+	stories, err := asanaFrom(ctx).TaskStories(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("read comments of Asana task %s: %w", taskID, err)
+	}
+	for _, story := range stories {
+		if story.Type == "comment" {
+			snap.Comments = append(snap.Comments, TrashComment{
+				Author:    story.CreatedBy.Name,
+				Text:      story.Text,
+				CreatedAt: story.CreatedAt,
+			})
+		}
+	}
+	return snap, nil
+}
+
+func snapshotYouTrackIssue(ctx context.Context, issueID string) (*YouTrackIssueSnapshot, error) {
+	raw, err := youTrackFrom(ctx).Issue(ctx, issueID)
+	if err != nil {
+		return nil, err
+	}
+
+	var issue struct {
+		ID          string `json:"id"`
+		IDReadable  string `json:"idReadable"`
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+		Project     struct {
+			ShortName string `json:"shortName"`
+		} `json:"project"`
+		Tags []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+		CustomFields []struct {
+			Name  string          `json:"name"`
+			Type  string          `json:"$type"`
+			Value json.RawMessage `json:"value"`
+		} `json:"customFields"`
+	}
+	if err := json.Unmarshal(raw, &issue); err != nil {
+		return nil, fmt.Errorf("decode YouTrack issue %s: %w", issueID, err)
+	}
+
+	snap := &YouTrackIssueSnapshot{
+		ID:           issue.ID,
+		IDReadable:   issue.IDReadable,
+		Summary:      issue.Summary,
+		Description:  issue.Description,
+		Project:      issue.Project.ShortName,
+		CustomFields: issue.CustomFields,
+		Comments:     []TrashComment{},
+		Raw:          raw,
+	}
+	for _, tag := range issue.Tags {
+		snap.Tags = append(snap.Tags, tag.Name)
+	}
+
+	comments, err := youTrackFrom(ctx).IssueComments(ctx, issueID)
+	if err != nil {
+		return nil, fmt.Errorf("read comments of YouTrack issue %s: %w", issueID, err)
+	}
+	for _, c := range comments {
+		snap.Comments = append(snap.Comments, TrashComment{
+			Author:    c.Author.Login,
+			Text:      c.Text,
+			CreatedAt: time.UnixMilli(c.Created).UTC().Format(time.RFC3339),
+		})
+	}
+	return snap, nil
+}
+
+// TrashEntry holds everything deleted for one ticket ID in one delete call
+type TrashEntry struct {
+	ID         string                 `json:"id"`
+	TicketID   string                 `json:"ticket_id"`
+	TicketName string                 `json:"ticket_name"`
+	Source     string                 `json:"source"`
+	Pair       string                 `json:"pair,omitempty"`
+	DeletedAt  time.Time              `json:"deleted_at"`
+	DeletedBy  string                 `json:"deleted_by"`
+	ExpiresAt  time.Time              `json:"expires_at"`
+	Asana      *AsanaTaskSnapshot     `json:"asana,omitempty"`
+	YouTrack   *YouTrackIssueSnapshot `json:"youtrack,omitempty"`
+	RestoredAt *time.Time             `json:"restored_at,omitempty"`
+	RestoredBy string                 `json:"restored_by,omitempty"`
+	Restore    *RestoreResult         `json:"restore,omitempty"`
+}
+
+// RestoreResult reports what a restore recreated
+type RestoreResult struct {
+	TrashID     string   `json:"trash_id"`
+	AsanaID     string   `json:"asana_id,omitempty"`
+	YouTrackID  string   `json:"youtrack_id,omitempty"`
+	LinkUpdated bool     `json:"link_updated"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// TrashStore keeps deleted tickets in a JSON file until they expire.
+// Expired entries are dropped whenever the store is read or written.
+type TrashStore struct {
+	file      string
+	retention time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*TrashEntry
+}
+
+// NewTrashStore loads the store from file. A missing file is an empty trash;
+// an unreadable one is an error, since starting empty would overwrite the
+// entries in it on the next delete.
+func NewTrashStore(file string, retention time.Duration) (*TrashStore, error) {
+	ts := &TrashStore{
+		file:      file,
+		retention: retention,
+		entries:   make(map[string]*TrashEntry),
+	}
+	if err := ts.load(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func (ts *TrashStore) Add(ctx context.Context, entry TrashEntry) (string, error) {
+	entry.ID = newJobID()
+	entry.DeletedAt = time.Now()
+	entry.DeletedBy = auditContextFrom(ctx).Actor
+	entry.Pair = pairFrom(ctx).Name
+	entry.ExpiresAt = entry.DeletedAt.Add(ts.retention)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.entries[entry.ID] = &entry
+	if err := ts.saveLocked(); err != nil {
+		delete(ts.entries, entry.ID)
+		return "", err
+	}
+	return entry.ID, nil
+}
+
+// List returns unexpired entries, newest first.
+func (ts *TrashStore) List() []TrashEntry {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.purgeLocked()
+
+	entries := make([]TrashEntry, 0, len(ts.entries))
+	for _, e := range ts.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries
+}
+
+func (ts *TrashStore) Get(id string) (TrashEntry, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.purgeLocked()
+
+	e, ok := ts.entries[id]
+	if !ok {
+		return TrashEntry{}, false
+	}
+	return *e, true
+}
+
+func (ts *TrashStore) MarkRestored(id, actor string, result RestoreResult) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	e, ok := ts.entries[id]
+	if !ok {
+		return fmt.Errorf("trash entry %s not found", id)
+	}
+	now := time.Now()
+	e.RestoredAt = &now
+	e.RestoredBy = actor
+	e.Restore = &result
+	return ts.saveLocked()
+}
+
+// SavePartialRestore records what a failed restore already recreated, so
+// a retry picks up from there instead of creating it a second time.
+func (ts *TrashStore) SavePartialRestore(id string, result RestoreResult) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	e, ok := ts.entries[id]
+	if !ok {
+		return fmt.Errorf("trash entry %s not found", id)
+	}
+	e.Restore = &result
+	return ts.saveLocked()
+}
+
+func (ts *TrashStore) purgeLocked() {
+	now := time.Now()
+	purged := 0
+	for id, e := range ts.entries {
+		if now.After(e.ExpiresAt) {
+			delete(ts.entries, id)
+			purged++
+		}
+	}
+	if purged > 0 {
+		slog.Info("Purged expired trash entries", "entries", purged)
+		ts.saveLocked()
+	}
+}
+
+func (ts *TrashStore) load() error {
+	data, err := os.ReadFile(ts.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read trash store: %w", err)
+	}
+
+	var entries []*TrashEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("trash store %s is corrupt (repair it or move it aside to start with an empty trash): %w", ts.file, err)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, e := range entries {
+		ts.entries[e.ID] = e
+	}
+	ts.purgeLocked()
+	return nil
+}
+
+func (ts *TrashStore) saveLocked() error {
+	entries := make([]*TrashEntry, 0, len(ts.entries))
+	for _, e := range ts.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.Before(entries[j].DeletedAt)
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ts.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ts.file)
+}
+
+// Restore
+
+// restoreTrashEntry recreates what the entry holds and repoints the
+// "[Synced from Asana ID: ...]" link at the new Asana task. An Asana task
+// recreated by an earlier, failed attempt (entry.Restore) is reused.
+func restoreTrashEntry(ctx context.Context, entry TrashEntry) (RestoreResult, error) {
+	result := RestoreResult{TrashID: entry.ID}
+	var partial RestoreResult
+	if entry.Restore != nil {
+		partial = *entry.Restore
+	}
+
+	if entry.Asana != nil {
+		if partial.AsanaID != "" {
+			result.AsanaID = partial.AsanaID
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Asana task %s was recreated by an earlier attempt", partial.AsanaID))
+		} else {
+			gid, warnings, err := restoreAsanaTask(ctx, entry.Asana)
+			result.Warnings = append(result.Warnings, warnings...)
+			if err != nil {
+				return result, fmt.Errorf("restore Asana task: %w", err)
+			}
+			result.AsanaID = gid
+		}
+	}
+
+	if entry.YouTrack != nil {
+		description := entry.YouTrack.Description
+		if entry.Asana != nil {
+			description = rewriteAsanaLink(description, entry.Asana.GID, result.AsanaID)
+			result.LinkUpdated = true
+		}
+		id, warnings, err := restoreYouTrackIssue(ctx, entry.YouTrack, description)
+		result.Warnings = append(result.Warnings, warnings...)
+		if err != nil {
+			return result, fmt.Errorf("restore YouTrack issue: %w", err)
+		}
+		result.YouTrackID = id
+	} else if entry.Asana != nil {
+		// Only the Asana side was deleted: point the surviving issue at the new task
+		issueID, err := findYouTrackIssueByAsanaID(ctx, entry.Asana.GID)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			// Never linked; nothing to rewrite
+		case err != nil:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("could not look up linked YouTrack issue: %v", err))
+		default:
+			if err := relinkYouTrackIssue(ctx, issueID, entry.Asana.GID, result.AsanaID); err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("could not update link on %s: %v", issueID, err))
+			} else {
+				result.YouTrackID = issueID
+				result.LinkUpdated = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func rewriteAsanaLink(description, oldGID, newGID string) string {
+	return strings.ReplaceAll(description, "Asana ID: "+oldGID, "Asana ID: "+newGID)
+}
+
+func relinkYouTrackIssue(ctx context.Context, issueID, oldGID, newGID string) error {
+	raw, err := youTrackFrom(ctx).Issue(ctx, issueID)
+	if err != nil {
+		return err
+	}
+	var issue struct {
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(raw, &issue); err != nil {
+		return err
+	}
+	return youTrackFrom(ctx).UpdateIssue(ctx, issueID, map[string]interface{}{
+		"$type":       "Issue",
+		"description": rewriteAsanaLink(issue.Description, oldGID, newGID),
+	})
+}
+
+func restoreAsanaTask(ctx context.Context, snap *AsanaTaskSnapshot) (string, []string, error) {
+	var warnings []string
+
+	data := map[string]interface{}{
+		"name":      snap.Name,
+		"notes":     snap.Notes,
+		"completed": snap.Completed,
+		"projects":  []string{pairFrom(ctx).AsanaProjectID},
+	}
+	if snap.DueOn != "" {
+		data["due_on"] = snap.DueOn
+	}
+	if snap.AssigneeGID != "" {
+		data["assignee"] = snap.AssigneeGID
+	}
+	if len(snap.Tags) > 0 {
+		tags := make([]string, 0, len(snap.Tags))
+		for _, tag := range snap.Tags {
+			tags = append(tags, tag.GID)
+		}
+		data["tags"] = tags
+	}
+	if fields := asanaCustomFieldPayload(snap.CustomFields); len(fields) > 0 {
+		data["custom_fields"] = fields
+	}
+
+	gid, err := asanaFrom(ctx).CreateTask(ctx, data)
+	if errors.Is(err, ErrValidation) && data["custom_fields"] != nil {
+		// Custom fields may have been removed from the project since
+		delete(data, "custom_fields")
+		warnings = append(warnings, "Asana custom fields could not be restored")
+		gid, err = asanaFrom(ctx).CreateTask(ctx, data)
+	}
+	if err != nil {
+		return "", warnings, err
+	}
+
+	if snap.SectionGID != "" {
+		if err := asanaFrom(ctx).AddTaskToSection(ctx, snap.SectionGID, gid); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not move task back to section %s: %v", snap.SectionName, err))
+		}
+	}
+
+	for _, c := range snap.Comments {
+		text := fmt.Sprintf("[Restored comment by %s, %s]\n%s", c.Author, c.CreatedAt, c.Text)
+		if err := asanaFrom(ctx).AddComment(ctx, gid, text); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not restore an Asana comment: %v", err))
+		}
+	}
+
+	return gid, warnings, nil
+}
+
+func asanaCustomFieldPayload(fields []asanaCustomFieldValue) map[string]interface{} {
+	payload := make(map[string]interface{})
+	for _, f := range fields {
+		switch {
+		case f.EnumValue != nil:
+			payload[f.GID] = f.EnumValue.GID
+		case f.NumberValue != nil:
+			payload[f.GID] = *f.NumberValue
+		case f.TextValue != nil:
+			payload[f.GID] = *f.TextValue
+		}
+	}
+	return payload
+}
+
+func restoreYouTrackIssue(ctx context.Context, snap *YouTrackIssueSnapshot, description string) (string, []string, error) {
+	var warnings []string
+
+	project := snap.Project
+	if project == "" {
+		project = pairFrom(ctx).YouTrackProjectID
+	}
+	payload := map[string]interface{}{
+		"$type":       "Issue",
+		"summary":     snap.Summary,
+		"description": description,
+		"project": map[string]interface{}{
+			"$type":     "Project",
+			"shortName": project,
+		},
+	}
+
+	// Only fields the target project has; the snapshot keeps each field's
+	// issue $type, so the values go back as they were read
+	schema := youTrackFrom(ctx).FieldSchema(ctx, project)
+	var customFields []map[string]interface{}
+	var dropped []string
+	for _, f := range snap.CustomFields {
+		if len(f.Value) == 0 || string(f.Value) == "null" {
+			continue
+		}
+		if !schema.assumed && !schema.Has(f.Name) {
+			dropped = append(dropped, f.Name)
+			continue
+		}
+		customFields = append(customFields, map[string]interface{}{
+			"$type": f.Type,
+			"name":  f.Name,
+			"value": f.Value,
+		})
+	}
+	if len(customFields) > 0 {
+		payload["customFields"] = customFields
+	}
+	if len(dropped) > 0 {
+		warnings = append(warnings, fmt.Sprintf("YouTrack project %s has no field(s) %s; not restored", project, strings.Join(dropped, ", ")))
+	}
+
+	id, err := youTrackFrom(ctx).CreateIssue(ctx, payload)
+	if errors.Is(err, ErrValidation) && len(customFields) > 0 {
+		delete(payload, "customFields")
+		warnings = append(warnings, "YouTrack custom fields could not be restored")
+		id, err = youTrackFrom(ctx).CreateIssue(ctx, payload)
+	}
+	if err != nil {
+		return "", warnings, err
+	}
+
+	// Tags are re-added only if they still exist; restoring must not
+	// recreate tags someone removed in the meantime
+	var missingTags []string
+	for _, name := range snap.Tags {
+		tagID, err := youTrackFrom(ctx).FindTagID(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			missingTags = append(missingTags, name)
+			continue
+		}
+		if err == nil {
+			err = youTrackFrom(ctx).AddIssueTag(ctx, id, tagID)
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not restore YouTrack tag %s: %v", name, err))
+		}
+	}
+	if len(missingTags) > 0 {
+		warnings = append(warnings, fmt.Sprintf("YouTrack tags no longer exist and were not restored: %s", strings.Join(missingTags, ", ")))
+	}
+
+	for _, c := range snap.Comments {
+		text := fmt.Sprintf("[Restored comment by %s, %s]\n%s", c.Author, c.CreatedAt, c.Text)
+		if err := youTrackFrom(ctx).AddComment(ctx, id, text); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not restore a YouTrack comment: %v", err))
+		}
+	}
+
+	return id, warnings, nil
+}
+
+// Trash handler: GET /trash lists entries, GET /trash/{id} shows one in full
+func (s *Server) trashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/trash"), "/")
+	w.Header().Set("Content-Type", "application/json")
+
+	store := tenantFrom(r.Context()).trash
+	if id != "" {
+		entry, ok := store.Get(id)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "not_found", fmt.Sprintf("Trash entry %s not found or expired", id))
+			return
+		}
+		json.NewEncoder(w).Encode(entry)
+		return
+	}
+
+	entries := store.List()
+	summaries := make([]map[string]interface{}, 0, len(entries))
+	for _, e := range entries {
+		summary := map[string]interface{}{
+			"id":           e.ID,
+			"ticket_id":    e.TicketID,
+			"ticket_name":  e.TicketName,
+			"source":       e.Source,
+			"pair":         e.Pair,
+			"deleted_at":   e.DeletedAt,
+			"deleted_by":   e.DeletedBy,
+			"expires_at":   e.ExpiresAt,
+			"has_asana":    e.Asana != nil,
+			"has_youtrack": e.YouTrack != nil,
+			"restored_at":  e.RestoredAt,
+		}
+		summaries = append(summaries, summary)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "success",
+		"entries":        summaries,
+		"count":          len(summaries),
+		"retention_days": currentConfig().TrashRetentionDays,
+	})
+}
+
+// Restore handler: POST /restore {"trash_id": "..."}
+func (s *Server) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		TrashID string `json:"trash_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TrashID == "" {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", `Body must be an object like {"trash_id":"..."}`)
+		return
+	}
+
+	// Restore into the pair the ticket was deleted from. Entries written
+	// before pairs existed have no pair and go to the default one.
+	t := tenantFrom(r.Context())
+	engine := t.Pairs().Default()
+	if entry, ok := t.trash.Get(req.TrashID); ok {
+		if engine, ok = t.Pairs().Get(entry.Pair); !ok {
+			writeJSONError(w, http.StatusConflict, "unknown_pair",
+				fmt.Sprintf("Trash entry %s belongs to project pair %q, which is no longer configured.", req.TrashID, entry.Pair))
+			return
+		}
+	}
+	ctx := withPair(r.Context(), engine)
+
+	var (
+		result     RestoreResult
+		restoreErr error
+		status     = http.StatusOK
+	)
+
+	if qerr := engine.RunExclusive(r.Context(), triggerManual, func() {
+		entry, ok := t.trash.Get(req.TrashID)
+		switch {
+		case !ok:
+			status, restoreErr = http.StatusNotFound, fmt.Errorf("trash entry %s not found or expired", req.TrashID)
+			return
+		case entry.RestoredAt != nil:
+			status, restoreErr = http.StatusConflict, fmt.Errorf("trash entry %s was already restored at %s", req.TrashID, entry.RestoredAt.Format(time.RFC3339))
+			return
+		}
+
+		result, restoreErr = restoreTrashEntry(ctx, entry)
+
+		outcome, errText := auditOutcome(restoreErr)
+		recordAudit(ctx, AuditEntry{
+			Action:     auditRestore,
+			AsanaID:    result.AsanaID,
+			YouTrackID: result.YouTrackID,
+			Before:     map[string]interface{}{"trash_id": entry.ID, "ticket_id": entry.TicketID},
+			After:      map[string]interface{}{"link_updated": result.LinkUpdated, "warnings": result.Warnings},
+			Outcome:    outcome,
+			Error:      errText,
+		})
+
+		if restoreErr != nil {
+			status = trackerErrorStatus(restoreErr)
+			// Keep the recreated Asana task so a retry does not make another
+			if result.AsanaID != "" {
+				if err := t.trash.SavePartialRestore(entry.ID, result); err != nil {
+					slog.ErrorContext(ctx, "Could not record partial restore", "trash_id", entry.ID, "error", err)
+				}
+			}
+			return
+		}
+		if err := t.trash.MarkRestored(entry.ID, actorName(ctx), result); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("restored, but could not update trash entry: %v", err))
+		}
+	}); qerr != nil {
+		writeRunQueueError(w, engine, qerr)
+		return
+	}
+
+	if restoreErr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "failed",
+			"error":  restoreErr.Error(),
+			"result": result,
+		})
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Restored trash entry", "trash_id", req.TrashID, "asana_id", result.AsanaID, "youtrack_id", result.YouTrackID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "restored",
+		"result": result,
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTrashStoreRefusesCorruptFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trash.json")
+	corrupt := []byte(`[{"id": "half-writ`)
+	if err := os.WriteFile(file, corrupt, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewTrashStore(file, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("NewTrashStore error = %v, want a corrupt store error", err)
+	}
+	// The file is left for the operator to repair
+	if data, _ := os.ReadFile(file); string(data) != string(corrupt) {
+		t.Errorf("trash.json was rewritten to %q", data)
+	}
+
+	if ts, err := NewTrashStore(filepath.Join(t.TempDir(), "missing.json"), time.Hour); err != nil || len(ts.List()) != 0 {
+		t.Errorf("missing file: %v; want an empty trash", err)
+	}
+}
@@ -6,12 +6,71 @@ import "time"
 type Config struct {
 	Port              string
 	SyncServiceAPIKey string
-	AsanaPAT          string
 	AsanaProjectID    string
 	YouTrackBaseURL   string
-	YouTrackToken     string
 	YouTrackProjectID string
 	PollIntervalMS    int
+
+	// Where tracker credentials are stored: env, file or keyring
+	SecretsBackend string
+	SecretsFile    string
+	SecretsDir     string
+
+	// Tracker HTTP behaviour
+	AsanaRateLimitPerMin    int
+	YouTrackRateLimitPerMin int
+	Retry                   RetryPolicy
+
+	// Column/state mapping and the default Asana tag -> Subsystem mapping
+	Columns    ColumnConfig
+	TagMapping map[string]string
+
+	// Propose adding missing State/Subsystem values to YouTrack
+	ProvisionBundleValues bool
+
+	// log/slog level (debug, info, warn, error) and format (text, json)
+	LogLevel  string
+	LogFormat string
+
+	// OpenTelemetry trace export; see tracing.go
+	Tracing TracingConfig
+
+	// Where alerts go and how often; see alerts.go
+	Alerts AlertsConfig
+
+	// OIDC bearer token login; disabled when OIDCIssuer is empty
+	OIDCIssuer      string
+	OIDCAudience    string
+	OIDCJWKSURL     string
+	OIDCRoleClaim   string
+	OIDCUserClaim   string
+	OIDCTenantClaim string // binds tokens to a tenant; empty disables
+	OIDCRoleMap     string // "claim-value=role,..."
+	OIDCDefaultRole string
+	OIDCDevIssuer   bool
+
+	CORS CORSConfig
+
+	TrashRetentionDays int // how long deleted tickets stay restorable
+
+	// Soft delete: default strategy and what the soft strategies need
+	DeleteStrategy        DeleteStrategy
+	AsanaArchiveSectionID string
+	YouTrackResolvedState string
+	YouTrackArchiveTag    string
+
+	// Tracker cache; webhook secrets are per tenant
+	CacheTTLSeconds         int
+	CacheFullRefreshMinutes int
+
+	// Auto runs only handle changes since their cursor, with a full pass this often
+	FullReconcileMinutes int
+
+	// Asana/YouTrack project pairs that seed the default tenant; the first is
+	// the default. ProjectPairsFile is empty when the single pair comes from
+	// ASANA_PROJECT_ID/YOUTRACK_PROJECT_ID.
+	ProjectPairs     []ProjectPair
+	ProjectPairsFile string
 }
 
 // Asana data structures
@@ -66,6 +125,9 @@ type TicketAnalysis struct {
 	BlockedTickets   []MatchedTicket    `json:"blocked_tickets"`
 	OrphanedYouTrack []YouTrackIssue    `json:"orphaned_youtrack"`
 	Ignored          []string           `json:"ignored"`
+
+	// Only when provisioning.bundle_values is on
+	MissingBundleValues []BundleProposal `json:"missing_bundle_values,omitempty"`
 }
 
 type MatchedTicket struct {
@@ -112,8 +174,18 @@ type IgnoreRequest struct {
 
 // NEW: Delete request structures
 type DeleteTicketsRequest struct {
-	TicketIDs []string `json:"ticket_ids"`
-	Source    string   `json:"source"` // "asana", "youtrack", "both"
+	TicketIDs         []string       `json:"ticket_ids"`
+	Source            string         `json:"source"`                       // "asana", "youtrack", "both"
+	Strategy          DeleteStrategy `json:"strategy,omitempty"`           // defaults to DELETE_STRATEGY_*
+	ConfirmationToken string         `json:"confirmation_token,omitempty"` // required for "both"
+}
+
+// DeletePreviewItem describes what a delete would remove for one ticket ID
+type DeletePreviewItem struct {
+	TicketID        string `json:"ticket_id"`
+	TicketName      string `json:"ticket_name"`
+	AsanaFound      bool   `json:"asana_found"`
+	YouTrackIssueID string `json:"youtrack_issue_id,omitempty"`
 }
 
 type DeleteResult struct {
@@ -123,6 +195,11 @@ type DeleteResult struct {
 	AsanaResult    string `json:"asana_result,omitempty"`
 	YouTrackResult string `json:"youtrack_result,omitempty"`
 	Error          string `json:"error,omitempty"`
+	TrashID        string `json:"trash_id,omitempty"` // restorable via POST /restore
+
+	// Strategy applied to each side that was touched
+	AsanaStrategy    string `json:"asana_strategy,omitempty"`
+	YouTrackStrategy string `json:"youtrack_strategy,omitempty"`
 }
 
 type DeleteResponse struct {
@@ -148,6 +225,9 @@ type AutoSyncStatus struct {
 	NextSync     time.Time `json:"next_sync"`
 	SyncCount    int       `json:"sync_count"`
 	LastSyncInfo string    `json:"last_sync_info"`
+	InProgress   bool      `json:"in_progress"`
+	SkippedRuns  int       `json:"skipped_runs"` // ticker fires skipped because another run held the project
+	QueuedRuns   int       `json:"queued_runs"`  // manual runs currently waiting for the project
 }
 
 // Auto-create control structures
@@ -163,6 +243,9 @@ type AutoCreateStatus struct {
 	NextCreate     time.Time `json:"next_create"`
 	CreateCount    int       `json:"create_count"`
 	LastCreateInfo string    `json:"last_create_info"`
+	InProgress     bool      `json:"in_progress"`
+	SkippedRuns    int       `json:"skipped_runs"`
+	QueuedRuns     int       `json:"queued_runs"`
 }
 
 // Ticket details request
@@ -177,34 +260,7 @@ type TagMapping struct {
 	YouTrackSubsystem string `json:"youtrack_subsystem"`
 }
 
-// Global variables
-var config Config
-var lastSyncTime time.Time
-var ignoredTicketsTemp = make(map[string]bool)
-var ignoredTicketsForever = make(map[string]bool)
-
-// Auto-sync global variables
-var autoSyncRunning = false
-var autoSyncInterval = 15 // default to 15 seconds
-var autoSyncTicker *time.Ticker
-var autoSyncDone chan bool
-var autoSyncCount = 0
-var autoSyncLastInfo = ""
-
-// Auto-create global variables
-var autoCreateRunning = false
-var autoCreateInterval = 15 // default to 15 seconds
-var autoCreateTicker *time.Ticker
-var autoCreateDone chan bool
-var autoCreateCount = 0
-var autoCreateLastInfo = ""
-
-// Column definitions
-var syncableColumns = []string{"backlog", "in progress", "dev", "stage", "blocked"}
-var displayOnlyColumns = []string{"ready for stage", "findings"}
-var allColumns = append(syncableColumns, displayOnlyColumns...)
-
-// Default tag-to-subsystem mapping
+// Default tag-to-subsystem mapping; config.yaml tag_mapping replaces it
 var defaultTagMapping = map[string]string{
 	"Mobile":      "mobile",
 	"Web":         "web",
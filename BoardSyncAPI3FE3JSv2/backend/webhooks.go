@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Webhooks only invalidate the tracker caches of the tenant's pairs; they never
+// change a tracker or evict a ticket. Deletions make the next read fetch the
+// whole project, so the tracker decides what is gone. Asana events must be
+// signed with the secret from the registration handshake.
+
+const maxWebhookBody = 1 << 20
+
+// webhookHandshakeWindow is how long a registration waits for Asana's
+// handshake
+const webhookHandshakeWindow = time.Minute
+
+// Asana webhook handler: answers the handshake of a registration started
+// with POST /webhooks/asana/register and applies signed task events. The
+// secret can also be preset in the tenant's asana_webhook_secret; a known
+// secret is never replaced by a handshake.
+func (s *Server) asanaWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	t := tenantFrom(r.Context())
+	if secret := r.Header.Get("X-Hook-Secret"); secret != "" {
+		if !t.claimWebhookHandshake() {
+			slog.WarnContext(r.Context(), "Asana webhook handshake refused: no registration pending or a secret is already set")
+			writeJSONError(w, http.StatusForbidden, "handshake_not_expected",
+				"No webhook registration is pending. Register with POST /webhooks/asana/register.")
+			return
+		}
+		if err := s.tenants.RotateSecret(t.ID(), "asana_webhook_secret", secret); err != nil {
+			slog.ErrorContext(r.Context(), "Could not store the Asana webhook secret", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Could not store the webhook secret.")
+			return
+		}
+		slog.InfoContext(r.Context(), "Asana webhook handshake completed")
+		w.Header().Set("X-Hook-Secret", secret)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	secret := t.WebhookSecret()
+	if secret == "" {
+		writeJSONError(w, http.StatusUnauthorized, "no_webhook_secret",
+			"No webhook secret is set for this tenant, so events cannot be verified.")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Could not read body.")
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Hook-Signature"))) {
+		writeJSONError(w, http.StatusUnauthorized, "invalid_signature", "X-Hook-Signature does not match.")
+		return
+	}
+
+	var payload struct {
+		Events []struct {
+			Action   string `json:"action"`
+			Resource struct {
+				GID          string `json:"gid"`
+				ResourceType string `json:"resource_type"`
+			} `json:"resource"`
+			Parent *struct {
+				GID          string `json:"gid"`
+				ResourceType string `json:"resource_type"`
+			} `json:"parent"`
+		} `json:"events"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request", "Body must be an Asana webhook event batch.")
+		return
+	}
+
+	pairs := t.Pairs()
+	removed := 0
+	for _, event := range payload.Events {
+		if event.Resource.ResourceType != "task" {
+			continue
+		}
+		if event.Action == "deleted" ||
+			(event.Action == "removed" && event.Parent != nil && event.Parent.ResourceType == "project") {
+			removed++
+		}
+	}
+	if removed > 0 {
+		pairs.RequestFullRefresh(trackerAsana)
+	} else {
+		pairs.MarkDirty(trackerAsana)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"events":  len(payload.Events),
+		"removed": removed,
+	})
+}
+
+// Asana webhook registration: POST /webhooks/asana/register with
+// {"base_url": "https://sync.example.com"} subscribes the pair's Asana
+// project to <base_url>/webhooks/asana?tenant=<id>. The handshake Asana
+// sends meanwhile is the only one accepted.
+func (s *Server) asanaWebhookRegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Use POST.")
+		return
+	}
+
+	var req struct {
+		BaseURL string `json:"base_url"`
+	}
+	json.NewDecoder(io.LimitReader(r.Body, maxWebhookBody)).Decode(&req)
+	base, err := url.Parse(strings.TrimRight(req.BaseURL, "/"))
+	if err != nil || base.Scheme != "https" || base.Host == "" {
+		writeJSONError(w, http.StatusBadRequest, "invalid_request",
+			"base_url must be the public https URL of this service, e.g. https://sync.example.com.")
+		return
+	}
+
+	t := tenantFrom(r.Context())
+	if t.WebhookSecret() != "" {
+		writeJSONError(w, http.StatusConflict, "already_registered",
+			"This tenant already has an Asana webhook secret. Clear asana_webhook_secret with POST /admin/secrets to register again.")
+		return
+	}
+
+	target := base.String() + "/webhooks/asana?tenant=" + url.QueryEscape(t.ID())
+	t.ExpectWebhookHandshake(time.Now().Add(webhookHandshakeWindow))
+	gid, err := asanaFrom(r.Context()).CreateWebhook(r.Context(), pairFrom(r.Context()).AsanaProjectID, target)
+	t.ExpectWebhookHandshake(time.Time{})
+	if err != nil {
+		writeJSONError(w, trackerErrorStatus(err), "webhook_registration_failed", fmt.Sprintf("Asana did not register the webhook: %v", err))
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Asana webhook registered", "webhook_id", gid, "target", target)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "registered",
+		"webhook_id": gid,
+		"target":     target,
+	})
+}
+
+// YouTrack webhook handler. YouTrack has no built-in webhooks; a workflow can
+// POST {"issue_id":"2-15","deleted":true} here, or an empty body to just
+// invalidate. The tenant's youtrack_webhook_token must be sent as
+// X-Webhook-Token; without one set, events are refused.
+func (s *Server) youTrackWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	t := tenantFrom(r.Context())
+	token := t.Record().YouTrackWebhookToken
+	if token == "" {
+		writeJSONError(w, http.StatusUnauthorized, "no_webhook_token",
+			"No youtrack_webhook_token is set for this tenant, so events cannot be verified.")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Webhook-Token")), []byte(token)) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, "invalid_token", "X-Webhook-Token is missing or wrong.")
+		return
+	}
+
+	var event struct {
+		IssueID string `json:"issue_id"`
+		Deleted bool   `json:"deleted"`
+	}
+	json.NewDecoder(io.LimitReader(r.Body, maxWebhookBody)).Decode(&event)
+
+	if event.Deleted {
+		t.Pairs().RequestFullRefresh(trackerYouTrack)
+	} else {
+		t.Pairs().MarkDirty(trackerYouTrack)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok"})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestYouTrackWebhookRequiresToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string // the tenant's youtrack_webhook_token
+		header string
+		want   int
+	}{
+		{"no token set", "", "", 401},
+		{"no token set, any header", "", "guess", 401},
+		{"missing header", "hook-token-1", "", 401},
+		{"wrong header", "hook-token-1", "hook-token-2", 401},
+		{"right header", "hook-token-1", "hook-token-1", 200},
+	}
+	for _, tt := range tests {
+		tenant := &Tenant{id: "hooks", record: TenantRecord{ID: "hooks", YouTrackWebhookToken: tt.token}}
+		req := httptest.NewRequest("POST", "/webhooks/youtrack", strings.NewReader(`{"deleted":true}`))
+		if tt.header != "" {
+			req.Header.Set("X-Webhook-Token", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		(&Server{}).youTrackWebhookHandler(rec, req.WithContext(withTenant(req.Context(), tenant)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
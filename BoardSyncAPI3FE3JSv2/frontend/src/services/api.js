@@ -4,17 +4,143 @@ const API_BASE =
     ? process.env.REACT_APP_API_URL || 'https://boardsyncapi.onrender.com'
     : 'http://localhost:8080';
 
+// Every endpoint except /health requires credentials: an OIDC token from the
+// dashboard login (sent as a bearer token) or an API key the user enters at
+// runtime (setApiKey). Keys are never baked into the build, where anyone who
+// loads the bundle could read them. The role decides what the caller may do
+// (see GET /whoami).
+const getApiKey = () =>
+  (typeof localStorage !== 'undefined' && localStorage.getItem('boardsync_api_key')) || '';
+
+export const setApiKey = (key) => {
+  if (key) {
+    localStorage.setItem('boardsync_api_key', key);
+  } else {
+    localStorage.removeItem('boardsync_api_key');
+  }
+};
+
+const getAuthToken = () =>
+  (typeof localStorage !== 'undefined' && localStorage.getItem('boardsync_id_token')) || '';
+
+export const setAuthToken = (token) => {
+  if (token) {
+    localStorage.setItem('boardsync_id_token', token);
+  } else {
+    localStorage.removeItem('boardsync_id_token');
+  }
+};
+
+// Tenant to act on. Keys bound to a tenant ignore it; service-level keys
+// need it once the backend has more than one tenant (GET /status lists them).
+const getActiveTenant = () =>
+  (typeof localStorage !== 'undefined' && localStorage.getItem('boardsync_tenant')) || '';
+
+export const setActiveTenant = (tenant) => {
+  if (tenant) {
+    localStorage.setItem('boardsync_tenant', tenant);
+  } else {
+    localStorage.removeItem('boardsync_tenant');
+  }
+};
+
+const authHeaders = (headers = {}) => {
+  const tenant = getActiveTenant();
+  if (tenant) {
+    headers = { ...headers, 'X-Tenant': tenant };
+  }
+  const token = getAuthToken();
+  if (token) {
+    return { ...headers, Authorization: `Bearer ${token}` };
+  }
+  const apiKey = getApiKey();
+  return apiKey ? { ...headers, 'X-API-Key': apiKey } : headers;
+};
+
+// Project pair for pair-scoped endpoints (/analyze, /sync, /create, ...).
+// Unset means the backend's default pair; GET /status lists the pairs.
+const getActivePair = () =>
+  (typeof localStorage !== 'undefined' && localStorage.getItem('boardsync_pair')) || '';
+
+export const setActivePair = (pair) => {
+  if (pair) {
+    localStorage.setItem('boardsync_pair', pair);
+  } else {
+    localStorage.removeItem('boardsync_pair');
+  }
+};
+
+const pairURL = (path) => {
+  const pair = getActivePair();
+  if (!pair) {
+    return `${API_BASE}${path}`;
+  }
+  const separator = path.includes('?') ? '&' : '?';
+  return `${API_BASE}${path}${separator}pair=${encodeURIComponent(pair)}`;
+};
+
+// Local development login against the backend's dev issuer
+// (OIDC_DEV_ISSUER=true). Stores the token for subsequent calls.
+export const devLogin = async (sub, roles = ['viewer']) => {
+  const response = await fetch(`${API_BASE}/dev-oidc/token`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ sub, email: sub, roles }),
+  });
+  if (!response.ok) {
+    throw new Error(`Dev login failed: ${response.status}`);
+  }
+  const { access_token } = await response.json();
+  setAuthToken(access_token);
+  return access_token;
+};
+
+export const getJob = async (jobId) => {
+  const response = await fetch(`${API_BASE}/jobs/${jobId}`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Get job failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+export const cancelJob = async (jobId) => {
+  const response = await fetch(`${API_BASE}/jobs/${jobId}`, {
+    method: 'DELETE',
+    headers: authHeaders(),
+  });
+  if (!response.ok) {
+    throw new Error(`Cancel job failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+export const waitForJob = async (jobId, { intervalMs = 1000, onProgress } = {}) => {
+  for (;;) {
+    const job = await getJob(jobId);
+    if (onProgress) onProgress(job);
+
+    if (job.status === 'completed' || job.status === 'cancelled') {
+      return job.result;
+    }
+    if (job.status === 'failed') {
+      throw new Error(job.error || 'Job failed');
+    }
+    await new Promise((resolve) => setTimeout(resolve, intervalMs));
+  }
+};
+
 export const analyzeTickets = async (columnFilter = '') => {
   // Build the URL with column parameter if provided
-  let url = `${API_BASE}/analyze`;
+  let path = '/analyze';
   if (columnFilter) {
-    url += `?column=${encodeURIComponent(columnFilter)}`;
+    path += `?column=${encodeURIComponent(columnFilter)}`;
   }
+  const url = pairURL(path);
   
   console.log('Analyzing tickets with column filter:', columnFilter); // DEBUG
   console.log('API URL:', url); // DEBUG
   
-  const response = await fetch(url);
+  const response = await fetch(url, { headers: authHeaders() });
   if (!response.ok) {
     throw new Error(`Analysis failed: ${response.status}`);
   }
@@ -25,15 +151,16 @@ export const analyzeTickets = async (columnFilter = '') => {
 };
 
 export const syncTickets = async (tickets) => {
-  const response = await fetch(`${API_BASE}/sync`, {
+  const response = await fetch(pairURL('/sync'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify(tickets),
   });
   if (!response.ok) {
     throw new Error(`Sync failed: ${response.status}`);
   }
-  return response.json();
+  const { job_id } = await response.json();
+  return waitForJob(job_id);
 };
 
 // Individual ticket sync
@@ -42,21 +169,22 @@ export const syncSingleTicket = async (ticketId) => {
 };
 
 export const createMissingTickets = async () => {
-  const response = await fetch(`${API_BASE}/create`, {
+  const response = await fetch(pairURL('/create'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
   });
   if (!response.ok) {
     throw new Error(`Create failed: ${response.status}`);
   }
-  return response.json();
+  const { job_id } = await response.json();
+  return waitForJob(job_id);
 };
 
 // Individual ticket creation
 export const createSingleTicket = async (taskId) => {
-  const response = await fetch(`${API_BASE}/create-single`, {
+  const response = await fetch(pairURL('/create-single'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({ task_id: taskId }),
   });
   if (!response.ok) {
@@ -65,8 +193,25 @@ export const createSingleTicket = async (taskId) => {
   return response.json();
 };
 
-// NEW: Delete tickets functionality
-export const deleteTickets = async (ticketIds, source) => {
+// Preview a delete. The response lists what would be removed and carries the
+// confirmation_token required to delete from both trackers.
+export const previewDeleteTickets = async (ticketIds, source, strategy) => {
+  const response = await fetch(pairURL('/delete-tickets/preview'), {
+    method: 'POST',
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
+    body: JSON.stringify({ ticket_ids: ticketIds, source, ...(strategy ? { strategy } : {}) }),
+  });
+  if (!response.ok) {
+    const errorData = await response.json().catch(() => null);
+    throw new Error(errorData?.message || `Delete preview failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+// NEW: Delete tickets functionality (requires an admin key). strategy is
+// optional, e.g. { asana: 'complete', youtrack: 'resolve' }; omitted sides
+// use the server default.
+export const deleteTickets = async (ticketIds, source, confirmationToken, strategy) => {
   // Validate parameters
   if (!Array.isArray(ticketIds) || ticketIds.length === 0) {
     throw new Error('ticketIds must be a non-empty array');
@@ -76,28 +221,39 @@ export const deleteTickets = async (ticketIds, source) => {
     throw new Error('source must be one of: asana, youtrack, both');
   }
 
-  const response = await fetch(`${API_BASE}/delete-tickets`, {
+  // Deleting from both trackers needs a token from a preview; callers that
+  // already confirmed with the user may omit it and one is fetched here.
+  let token = confirmationToken;
+  if (source === 'both' && !token) {
+    const preview = await previewDeleteTickets(ticketIds, source, strategy);
+    token = preview.confirmation_token;
+  }
+
+  const response = await fetch(pairURL('/delete-tickets'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({
       ticket_ids: ticketIds,
-      source: source
+      source: source,
+      ...(strategy ? { strategy } : {}),
+      ...(token ? { confirmation_token: token } : {}),
     }),
   });
   
   if (!response.ok) {
     const errorData = await response.json().catch(() => null);
     throw new Error(
-      errorData?.error || `Delete failed with status: ${response.status}`
+      errorData?.message || errorData?.error || `Delete failed with status: ${response.status}`
     );
   }
   
-  return response.json();
+  const { job_id } = await response.json();
+  return waitForJob(job_id);
 };
 
 // Auto-sync control
 export const getAutoSyncStatus = async () => {
-  const response = await fetch(`${API_BASE}/auto-sync`);
+  const response = await fetch(pairURL('/auto-sync'), { headers: authHeaders() });
   if (!response.ok) {
     throw new Error(`Auto-sync status failed: ${response.status}`);
   }
@@ -105,9 +261,9 @@ export const getAutoSyncStatus = async () => {
 };
 
 export const startAutoSync = async (interval = 15) => {
-  const response = await fetch(`${API_BASE}/auto-sync`, {
+  const response = await fetch(pairURL('/auto-sync'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({ action: 'start', interval }),
   });
   if (!response.ok) {
@@ -117,9 +273,9 @@ export const startAutoSync = async (interval = 15) => {
 };
 
 export const stopAutoSync = async () => {
-  const response = await fetch(`${API_BASE}/auto-sync`, {
+  const response = await fetch(pairURL('/auto-sync'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({ action: 'stop' }),
   });
   if (!response.ok) {
@@ -130,7 +286,7 @@ export const stopAutoSync = async () => {
 
 // Auto-create control
 export const getAutoCreateStatus = async () => {
-  const response = await fetch(`${API_BASE}/auto-create`);
+  const response = await fetch(pairURL('/auto-create'), { headers: authHeaders() });
   if (!response.ok) {
     throw new Error(`Auto-create status failed: ${response.status}`);
   }
@@ -138,9 +294,9 @@ export const getAutoCreateStatus = async () => {
 };
 
 export const startAutoCreate = async (interval = 15) => {
-  const response = await fetch(`${API_BASE}/auto-create`, {
+  const response = await fetch(pairURL('/auto-create'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({ action: 'start', interval }),
   });
   if (!response.ok) {
@@ -150,9 +306,9 @@ export const startAutoCreate = async (interval = 15) => {
 };
 
 export const stopAutoCreate = async () => {
-  const response = await fetch(`${API_BASE}/auto-create`, {
+  const response = await fetch(pairURL('/auto-create'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({ action: 'stop' }),
   });
   if (!response.ok) {
@@ -166,7 +322,7 @@ export const getTicketsByType = async (type, column = '') => {
   const params = new URLSearchParams({ type });
   if (column) params.append('column', column);
   
-  const response = await fetch(`${API_BASE}/tickets?${params}`);
+  const response = await fetch(pairURL(`/tickets?${params}`), { headers: authHeaders() });
   if (!response.ok) {
     throw new Error(`Get tickets failed: ${response.status}`);
   }
@@ -175,9 +331,9 @@ export const getTicketsByType = async (type, column = '') => {
 
 // Ignore ticket management
 export const ignoreTicket = async (ticketId, type = 'forever') => {
-  const response = await fetch(`${API_BASE}/ignore`, {
+  const response = await fetch(pairURL('/ignore'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({ 
       ticket_id: ticketId, 
       action: 'add', 
@@ -191,9 +347,9 @@ export const ignoreTicket = async (ticketId, type = 'forever') => {
 };
 
 export const unignoreTicket = async (ticketId, type = 'forever') => {
-  const response = await fetch(`${API_BASE}/ignore`, {
+  const response = await fetch(pairURL('/ignore'), {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
     body: JSON.stringify({ 
       ticket_id: ticketId, 
       action: 'remove', 
@@ -207,7 +363,7 @@ export const unignoreTicket = async (ticketId, type = 'forever') => {
 };
 
 export const getIgnoredTickets = async () => {
-  const response = await fetch(`${API_BASE}/ignore`);
+  const response = await fetch(pairURL('/ignore'), { headers: authHeaders() });
   if (!response.ok) {
     throw new Error(`Get ignored tickets failed: ${response.status}`);
   }
@@ -222,8 +378,198 @@ export const getHealth = async () => {
   return response.json();
 };
 
+// Audit log (admin). filters: actor, trigger, action, outcome, endpoint,
+// ticket_id, job_id, since, until, limit, offset; total=true also counts
+// every match, which reads the whole log
+export const getAuditLog = async (filters = {}) => {
+  const params = new URLSearchParams(filters);
+  const response = await fetch(`${API_BASE}/audit?${params}`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Get audit log failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+// Download the filtered audit log as 'jsonl' or 'csv'
+export const exportAuditLog = async (format = 'csv', filters = {}) => {
+  const params = new URLSearchParams({ ...filters, format });
+  const response = await fetch(`${API_BASE}/audit?${params}`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Export audit log failed: ${response.status}`);
+  }
+  return response.blob();
+};
+
+// Deleted tickets that can still be restored (admin)
+export const getTrash = async () => {
+  const response = await fetch(`${API_BASE}/trash`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Get trash failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+// Full snapshot of one trash entry (admin)
+export const getTrashEntry = async (trashId) => {
+  const response = await fetch(`${API_BASE}/trash/${encodeURIComponent(trashId)}`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Get trash entry failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+// Recreate a deleted ticket and relink Asana and YouTrack (admin)
+export const restoreTicket = async (trashId) => {
+  const response = await fetch(`${API_BASE}/restore`, {
+    method: 'POST',
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
+    body: JSON.stringify({ trash_id: trashId }),
+  });
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error(data.error || `Restore failed: ${response.status}`);
+  }
+  return data;
+};
+
+// Tenants (service-level admin). Responses never include credentials.
+export const getTenants = async () => {
+  const response = await fetch(`${API_BASE}/admin/tenants`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Get tenants failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+export const createTenant = async (tenant) => {
+  const response = await fetch(`${API_BASE}/admin/tenants`, {
+    method: 'POST',
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
+    body: JSON.stringify(tenant),
+  });
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error(data.message || `Create tenant failed: ${response.status}`);
+  }
+  return data;
+};
+
+// Only the fields present in changes are updated
+export const updateTenant = async (tenantId, changes) => {
+  const response = await fetch(`${API_BASE}/admin/tenants/${encodeURIComponent(tenantId)}`, {
+    method: 'PUT',
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
+    body: JSON.stringify(changes),
+  });
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error(data.message || `Update tenant failed: ${response.status}`);
+  }
+  return data;
+};
+
+// Removes the tenant and revokes its API keys; its data stays on disk
+export const deleteTenant = async (tenantId) => {
+  const response = await fetch(`${API_BASE}/admin/tenants/${encodeURIComponent(tenantId)}`, {
+    method: 'DELETE',
+    headers: authHeaders(),
+  });
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error(data.message || `Delete tenant failed: ${response.status}`);
+  }
+  return data;
+};
+
+// Which credentials of the active tenant are set (admin). Values are never returned.
+export const getSecrets = async () => {
+  const response = await fetch(`${API_BASE}/admin/secrets`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Get secrets failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+// Replace one tracker credential of the active tenant without a restart (admin)
+export const rotateSecret = async (name, value) => {
+  const response = await fetch(`${API_BASE}/admin/secrets`, {
+    method: 'POST',
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
+    body: JSON.stringify({ name, value }),
+  });
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error(data.message || `Rotate secret failed: ${response.status}`);
+  }
+  return data;
+};
+
+// Running configuration and the outcome of the last reload (service admin)
+export const getConfig = async () => {
+  const response = await fetch(`${API_BASE}/admin/config`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Get config failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+// Re-read config.yaml and the environment; problems lists validation errors
+export const reloadConfig = async () => {
+  const response = await fetch(`${API_BASE}/admin/config/reload`, {
+    method: 'POST',
+    headers: authHeaders(),
+  });
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error((data.problems || []).join('\n') || data.message || `Reload config failed: ${response.status}`);
+  }
+  return data;
+};
+
+// Check tokens, project access and YouTrack fields of the active tenant (operator)
+export const getDiagnostics = async (pair) => {
+  const query = pair ? `?pair=${encodeURIComponent(pair)}` : '';
+  const response = await fetch(`${API_BASE}/diagnostics${query}`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Diagnostics failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+// Missing YouTrack State/Subsystem values proposed by analysis for the active pair
+export const getProvisioning = async () => {
+  const response = await fetch(pairURL('/provisioning'), { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Get provisioning failed: ${response.status}`);
+  }
+  return response.json();
+};
+
+// Add approved values (ids like "Subsystem:web") to YouTrack (admin)
+export const approveProvisioning = async (ids) => {
+  const response = await fetch(pairURL('/provisioning'), {
+    method: 'POST',
+    headers: authHeaders({ 'Content-Type': 'application/json' }),
+    body: JSON.stringify({ approve: ids }),
+  });
+  const data = await response.json();
+  if (!response.ok) {
+    throw new Error(data.message || `Approve provisioning failed: ${response.status}`);
+  }
+  return data;
+};
+
+// Identity, role and permissions of the configured API key
+export const getWhoami = async () => {
+  const response = await fetch(`${API_BASE}/whoami`, { headers: authHeaders() });
+  if (!response.ok) {
+    throw new Error(`Whoami failed: ${response.status}`);
+  }
+  return response.json();
+};
+
 export const getStatus = async () => {
-  const response = await fetch(`${API_BASE}/status`);
+  const response = await fetch(`${API_BASE}/status`, { headers: authHeaders() });
   if (!response.ok) {
     throw new Error(`Status check failed: ${response.status}`);
   }